@@ -0,0 +1,572 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/client"
+)
+
+// command is a client subcommand
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(app *app, args []string) error
+}
+
+var commands = []command{
+	{"register", "ID", "Register a participant and print its API key", runRegister},
+	{"token", "", "Exchange the API key for a token", runToken},
+	{"create", "-name NAME -min-bid AMOUNT [flags]", "Create an auction", runCreate},
+	{"list", "[-status S] [-category C] [-tag T] [-sort ORDER] [-cursor C] [flags]", "List auctions, one page at a time", runList},
+	{"search", "TEXT [-category C] [-tag T] [-status S] [-limit N]", "Search auction names, descriptions and labels", runSearch},
+	{"get", "ID", "Show an auction", runGet},
+	{"edit", "ID [-description TEXT] [-category C] [-tags T,...] [-expiry TIME | -extend DURATION]", "Edit your auction before its first bid", runEdit},
+	{"cancel", "ID -reason TEXT", "Cancel your auction", runCancel},
+	{"status", "ID", "Show the current status of an auction", runStatus},
+	{"bid", "ID -price AMOUNT [-max AMOUNT] [-idempotency-key K]", "Place a bid", runBid},
+	{"history", "ID [-bid BID]", "Show the bid history of an auction, or one of its bids", runHistory},
+	{"accept", "ID", "Accept the current price of a Dutch auction", runAccept},
+	{"buy", "ID", "Buy an auction at its buy-now price", runBuy},
+	{"result", "ID", "Show the result of a closed auction", runResult},
+	{"watch", "ID [-after N]", "Follow the events of an auction until interrupted", runWatch},
+	{"cluster", "", "List the live servers of the cluster", runCluster},
+}
+
+// app is the state shared by all commands
+type app struct {
+	api     *client.Client
+	printer printer
+}
+
+// parseArgs parses flags that may appear before or after positional
+// arguments, and returns the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseID parses a command that takes a single auction ID
+func parseID(fs *flag.FlagSet, args []string) (string, error) {
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return "", err
+	}
+	if len(positional) != 1 {
+		return "", errors.New("expected a single auction ID")
+	}
+	return positional[0], nil
+}
+
+// parseMoney reads an amount given in major units, such as 12.50
+func parseMoney(value, currency string) (auction.Money, error) {
+	amount, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return auction.Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+	return auction.FromMajor(amount, currency), nil
+}
+
+// optionalMoney is like parseMoney, but an empty value is zero
+func optionalMoney(value, currency string) (auction.Money, error) {
+	if value == "" {
+		return auction.Money{}, nil
+	}
+	return parseMoney(value, currency)
+}
+
+func runRegister(app *app, args []string) error {
+	fs := flag.NewFlagSet("register", flag.ContinueOnError)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("expected a single participant ID")
+	}
+
+	registration, err := app.api.Register(context.Background(), positional[0])
+	if err != nil {
+		return err
+	}
+	return app.printer.print(registration, registrationTable(registration))
+}
+
+func runToken(app *app, args []string) error {
+	fs := flag.NewFlagSet("token", flag.ContinueOnError)
+	if positional, err := parseArgs(fs, args); err != nil {
+		return err
+	} else if len(positional) > 0 {
+		return fmt.Errorf("unexpected argument %q", positional[0])
+	}
+
+	token, err := app.api.Token(context.Background())
+	if err != nil {
+		return err
+	}
+	return app.printer.print(token, tokenTable(token))
+}
+
+func runCreate(app *app, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	name := fs.String("name", "", "Auction name")
+	description := fs.String("description", "", "Auction description")
+	category := fs.String("category", "", "Auction category")
+	tags := fs.String("tags", "", "Comma-separated tags")
+	auctionType := fs.String("type", "english", "Auction type: english, sealed_first_price, sealed_second_price or dutch")
+	currency := fs.String("currency", auction.DefaultCurrency, "ISO 4217 currency of all amounts")
+	minBid := fs.String("min-bid", "", "Minimum bid")
+	reserve := fs.String("reserve", "", "Hidden reserve price")
+	buyNow := fs.String("buy-now", "", "Buy-now price")
+	increment := fs.String("increment", "", "Fixed bid increment")
+	incrementPercent := fs.Float64("increment-percent", 0, "Bid increment as a percentage of the highest bid")
+	expiry := fs.String("expiry", "", "Expiry time in RFC 3339 format")
+	duration := fs.Duration("duration", 24*time.Hour, "Time until expiry, used when -expiry is not set")
+	extensionWindow := fs.Duration("extension-window", 0, "Bids this close to expiry extend the auction")
+	extensionDuration := fs.Duration("extension-duration", 0, "How far past a late bid the auction is extended")
+	dutchStart := fs.String("dutch-start", "", "Dutch auction start price")
+	dutchFloor := fs.String("dutch-floor", "", "Dutch auction floor price")
+	dutchDecrement := fs.String("dutch-decrement", "", "Dutch auction price decrement")
+	dutchInterval := fs.Duration("dutch-interval", 0, "Dutch auction time between decrements")
+	idempotencyKey := fs.String("idempotency-key", "", "Key that makes repeating this command create the auction once")
+	if positional, err := parseArgs(fs, args); err != nil {
+		return err
+	} else if len(positional) > 0 {
+		return fmt.Errorf("unexpected argument %q", positional[0])
+	}
+
+	cur := strings.ToUpper(*currency)
+	item := auction.AuctionItem{
+		Name:              *name,
+		Description:       *description,
+		Category:          *category,
+		Tags:              splitTags(*tags),
+		AuctionType:       auction.AuctionType(*auctionType),
+		ExpiryTime:        time.Now().Add(*duration),
+		ExtensionWindow:   auction.Duration(*extensionWindow),
+		ExtensionDuration: auction.Duration(*extensionDuration),
+	}
+	if *expiry != "" {
+		t, err := time.Parse(time.RFC3339, *expiry)
+		if err != nil {
+			return fmt.Errorf("invalid -expiry: %v", err)
+		}
+		item.ExpiryTime = t
+	}
+
+	var err error
+	if item.MinimumBid, err = optionalMoney(*minBid, cur); err != nil {
+		return err
+	}
+	if item.ReservePrice, err = optionalMoney(*reserve, cur); err != nil {
+		return err
+	}
+	if item.BuyNowPrice, err = optionalMoney(*buyNow, cur); err != nil {
+		return err
+	}
+
+	switch {
+	case *increment != "" && *incrementPercent != 0:
+		return errors.New("use only one of -increment and -increment-percent")
+	case *increment != "":
+		amount, err := parseMoney(*increment, cur)
+		if err != nil {
+			return err
+		}
+		item.IncrementRule = &auction.IncrementRule{Type: auction.IncrementFixed, Amount: amount}
+	case *incrementPercent != 0:
+		item.IncrementRule = &auction.IncrementRule{Type: auction.IncrementPercent, Percent: *incrementPercent}
+	}
+
+	if item.AuctionType == auction.TypeDutch {
+		schedule := &auction.DutchSchedule{Interval: auction.Duration(*dutchInterval)}
+		for _, field := range []struct {
+			value string
+			money *auction.Money
+		}{
+			{*dutchStart, &schedule.StartPrice},
+			{*dutchFloor, &schedule.FloorPrice},
+			{*dutchDecrement, &schedule.Decrement},
+		} {
+			if *field.money, err = optionalMoney(field.value, cur); err != nil {
+				return err
+			}
+		}
+		item.Dutch = schedule
+	}
+
+	created, err := app.api.CreateAuction(idempotent(*idempotencyKey), item)
+	if err != nil {
+		return err
+	}
+	return app.printer.print(created, auctionTable(created))
+}
+
+func runList(app *app, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	status := fs.String("status", "", "Only list active, expired or closed auctions")
+	seller := fs.String("seller", "", "Only list auctions of this seller")
+	category := fs.String("category", "", "Only list auctions in this category")
+	tag := fs.String("tag", "", "Only list auctions with this tag")
+	currency := fs.String("currency", auction.DefaultCurrency, "Currency of -min-price and -max-price")
+	minPrice := fs.String("min-price", "", "Lowest current bid, or minimum bid before the first bid")
+	maxPrice := fs.String("max-price", "", "Highest current bid, or minimum bid before the first bid")
+	createdAfter := fs.String("created-after", "", "Only list auctions created after this RFC 3339 time")
+	createdBefore := fs.String("created-before", "", "Only list auctions created before this RFC 3339 time")
+	expiresAfter := fs.String("expires-after", "", "Only list auctions expiring after this RFC 3339 time")
+	expiresBefore := fs.String("expires-before", "", "Only list auctions expiring before this RFC 3339 time")
+	sort := fs.String("sort", "", "Sort order: newest, ending_soonest or highest_bid")
+	limit := fs.Int("limit", 0, "Number of auctions per page")
+	cursor := fs.String("cursor", "", "Cursor of the page to show, as printed after the previous page")
+	if positional, err := parseArgs(fs, args); err != nil {
+		return err
+	} else if len(positional) > 0 {
+		return fmt.Errorf("unexpected argument %q", positional[0])
+	}
+
+	query := auction.ListQuery{
+		Status:   *status,
+		SellerID: *seller,
+		Category: *category,
+		Tag:      *tag,
+		Sort:     auction.SortOrder(*sort),
+		Limit:    *limit,
+	}
+
+	cur := strings.ToUpper(*currency)
+	var err error
+	if query.MinPrice, err = optionalMoney(*minPrice, cur); err != nil {
+		return err
+	}
+	if query.MaxPrice, err = optionalMoney(*maxPrice, cur); err != nil {
+		return err
+	}
+
+	for _, field := range []struct {
+		name  string
+		value string
+		time  *time.Time
+	}{
+		{"-created-after", *createdAfter, &query.CreatedAfter},
+		{"-created-before", *createdBefore, &query.CreatedBefore},
+		{"-expires-after", *expiresAfter, &query.ExpiresAfter},
+		{"-expires-before", *expiresBefore, &query.ExpiresBefore},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if *field.time, err = time.Parse(time.RFC3339, field.value); err != nil {
+			return fmt.Errorf("invalid %s: %v", field.name, err)
+		}
+	}
+
+	if *cursor != "" {
+		after, err := auction.ParseCursor(*cursor)
+		if err != nil {
+			return err
+		}
+		query.After = &after
+	}
+
+	page, err := app.api.ListAuctions(context.Background(), query)
+	if err != nil {
+		return err
+	}
+	return app.printer.print(page, auctionsTable(page))
+}
+
+func runSearch(app *app, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	category := fs.String("category", "", "Only return auctions in this category")
+	tag := fs.String("tag", "", "Only return auctions with this tag")
+	status := fs.String("status", "", "Only return active, expired or closed auctions")
+	limit := fs.Int("limit", 0, "Number of auctions to return")
+	words, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return errors.New("expected the text to search for")
+	}
+
+	query := auction.ListQuery{Status: *status, Category: *category, Tag: *tag, Limit: *limit}
+	auctions, err := app.api.Search(context.Background(), strings.Join(words, " "), query)
+	if err != nil {
+		return err
+	}
+	return app.printer.print(auctions, auctionsTable(auction.ListPage{Auctions: auctions}))
+}
+
+// splitTags splits a comma-separated list of tags
+func splitTags(list string) []string {
+	var tags []string
+	for _, tag := range strings.Split(list, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func runGet(app *app, args []string) error {
+	id, err := parseID(flag.NewFlagSet("get", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	item, err := app.api.GetAuction(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	return app.printer.print(item, auctionTable(item))
+}
+
+func runEdit(app *app, args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	description := fs.String("description", "", "New description")
+	category := fs.String("category", "", "New category")
+	tags := fs.String("tags", "", "New comma-separated tags, replacing the current ones")
+	expiry := fs.String("expiry", "", "New expiry time in RFC 3339 format, later than the current one")
+	extend := fs.Duration("extend", 0, "Move the expiry time later by this much")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	// Only the flags given are changed, so an empty description, category
+	// or list of tags can be set
+	var update auction.AuctionUpdate
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "description":
+			update.Description = description
+		case "category":
+			update.Category = category
+		case "tags":
+			list := splitTags(*tags)
+			update.Tags = &list
+		}
+	})
+
+	switch {
+	case *expiry != "" && *extend != 0:
+		return errors.New("use only one of -expiry and -extend")
+	case *expiry != "":
+		t, err := time.Parse(time.RFC3339, *expiry)
+		if err != nil {
+			return fmt.Errorf("invalid -expiry: %v", err)
+		}
+		update.ExpiryTime = &t
+	case *extend != 0:
+		item, err := app.api.GetAuction(context.Background(), id)
+		if err != nil {
+			return err
+		}
+		t := item.ExpiryTime.Add(*extend)
+		update.ExpiryTime = &t
+	}
+	if update.IsEmpty() {
+		return errors.New("nothing to change, give -description, -category, -tags, -expiry or -extend")
+	}
+
+	updated, err := app.api.UpdateAuction(context.Background(), id, update)
+	if err != nil {
+		return err
+	}
+	return app.printer.print(updated, auctionTable(updated))
+}
+
+func runCancel(app *app, args []string) error {
+	fs := flag.NewFlagSet("cancel", flag.ContinueOnError)
+	reason := fs.String("reason", "", "Reason for cancelling, shown to bidders")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	if *reason == "" {
+		return errors.New("-reason is required")
+	}
+
+	settlement, err := app.api.CancelAuction(context.Background(), id, *reason)
+	if err != nil {
+		return err
+	}
+	return app.printer.print(settlement, settlementTable(settlement))
+}
+
+func runStatus(app *app, args []string) error {
+	id, err := parseID(flag.NewFlagSet("status", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	status, err := app.api.Status(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	return app.printer.print(status, statusTable(status))
+}
+
+func runBid(app *app, args []string) error {
+	fs := flag.NewFlagSet("bid", flag.ContinueOnError)
+	participant := fs.String("participant", "", "Participant ID, the authenticated participant by default")
+	price := fs.String("price", "", "Bid price")
+	max := fs.String("max", "", "Hidden maximum for proxy bidding")
+	currency := fs.String("currency", "", "Currency of the amounts, the auction currency by default")
+	idempotencyKey := fs.String("idempotency-key", "", "Key that makes repeating this command place the bid once")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	if *price == "" {
+		return errors.New("-price is required")
+	}
+
+	cur := strings.ToUpper(*currency)
+	if cur == "" {
+		item, err := app.api.GetAuction(context.Background(), id)
+		if err != nil {
+			return err
+		}
+		cur = item.Currency()
+	}
+
+	bid := auction.Bid{ParticipantID: *participant, AuctionItemID: id}
+	if bid.BidPrice, err = parseMoney(*price, cur); err != nil {
+		return err
+	}
+	if bid.MaxBid, err = optionalMoney(*max, cur); err != nil {
+		return err
+	}
+
+	placed, err := app.api.PlaceBid(idempotent(*idempotencyKey), bid)
+	if err != nil {
+		return err
+	}
+	return app.printer.print(placed, bidTable(placed))
+}
+
+// idempotent returns the context of a write, sending key as its idempotency
+// key when set
+func idempotent(key string) context.Context {
+	if key == "" {
+		return context.Background()
+	}
+	return client.WithIdempotencyKey(context.Background(), key)
+}
+
+func runHistory(app *app, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	bidID := fs.String("bid", "", "Only show the bid with this ID")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if *bidID != "" {
+		bid, err := app.api.GetBid(context.Background(), id, *bidID)
+		if err != nil {
+			return err
+		}
+		return app.printer.print(bid, bidTable(bid))
+	}
+
+	bids, err := app.api.BidHistory(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	return app.printer.print(bids, bidsTable(bids))
+}
+
+// runSettle runs a command that closes an auction for a participant
+func runSettle(name string, settle func(api *client.Client, ctx context.Context, id, participantID string) (auction.Settlement, error)) func(app *app, args []string) error {
+	return func(app *app, args []string) error {
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		participant := fs.String("participant", "", "Participant ID, the authenticated participant by default")
+		id, err := parseID(fs, args)
+		if err != nil {
+			return err
+		}
+
+		settlement, err := settle(app.api, context.Background(), id, *participant)
+		if err != nil {
+			return err
+		}
+		return app.printer.print(settlement, settlementTable(settlement))
+	}
+}
+
+var (
+	runAccept = runSettle("accept", (*client.Client).AcceptPrice)
+	runBuy    = runSettle("buy", (*client.Client).BuyNow)
+)
+
+func runResult(app *app, args []string) error {
+	id, err := parseID(flag.NewFlagSet("result", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	settlement, err := app.api.Result(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	return app.printer.print(settlement, settlementTable(settlement))
+}
+
+func runWatch(app *app, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	after := fs.Uint64("after", 0, "Only show events after this sequence number")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	events, err := app.api.Events(ctx, id, *after)
+	if err != nil {
+		return err
+	}
+	for event := range events {
+		if err := app.printer.printEvent(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runCluster(app *app, args []string) error {
+	fs := flag.NewFlagSet("cluster", flag.ContinueOnError)
+	if positional, err := parseArgs(fs, args); err != nil {
+		return err
+	} else if len(positional) > 0 {
+		return fmt.Errorf("unexpected argument %q", positional[0])
+	}
+
+	members, err := app.api.Cluster(context.Background())
+	if err != nil {
+		return err
+	}
+	return app.printer.print(members, membersTable(members))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/client"
+)
+
+// config holds the client settings. Values are taken from the config file,
+// then the environment, then command line flags, each overriding the last.
+type config struct {
+	Servers []string `json:"servers"`
+	Output  string   `json:"output"`
+	Timeout string   `json:"timeout"`
+	// APIKey or Token authenticate requests, Token wins if both are set
+	APIKey string `json:"api_key,omitempty"`
+	Token  string `json:"token,omitempty"`
+}
+
+// defaultConfigPath returns where the config file is looked for when
+// neither -config nor AUCTION_CONFIG is set
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "auction", "client.json")
+}
+
+// defaultConfig points at a single local server, on $PORT if it is set
+func defaultConfig() config {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	return config{
+		Servers: []string{"http://localhost:" + port},
+		Output:  "table",
+		Timeout: "10s",
+	}
+}
+
+// loadConfigFile merges the config file at path into c. A missing file is
+// only an error if the path was given explicitly.
+func loadConfigFile(c *config, path string, explicit bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var file config
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	c.merge(file)
+	return nil
+}
+
+// loadEnv merges the AUCTION_* environment variables into c
+func loadEnv(c *config) {
+	c.merge(config{
+		Servers: splitList(os.Getenv("AUCTION_SERVERS")),
+		Output:  os.Getenv("AUCTION_OUTPUT"),
+		Timeout: os.Getenv("AUCTION_TIMEOUT"),
+		APIKey:  os.Getenv("AUCTION_API_KEY"),
+		Token:   os.Getenv("AUCTION_TOKEN"),
+	})
+}
+
+// merge overrides the settings of c that are set in other
+func (c *config) merge(other config) {
+	if len(other.Servers) > 0 {
+		c.Servers = other.Servers
+	}
+	if other.Output != "" {
+		c.Output = other.Output
+	}
+	if other.Timeout != "" {
+		c.Timeout = other.Timeout
+	}
+	if other.APIKey != "" {
+		c.APIKey = other.APIKey
+	}
+	if other.Token != "" {
+		c.Token = other.Token
+	}
+}
+
+// clientOptions returns the options for a client with these settings
+func (c config) clientOptions(timeout time.Duration) []client.Option {
+	opts := []client.Option{client.WithTimeout(timeout)}
+	switch {
+	case c.Token != "":
+		opts = append(opts, client.WithToken(c.Token))
+	case c.APIKey != "":
+		opts = append(opts, client.WithAPIKey(c.APIKey))
+	}
+	return opts
+}
+
+// validate checks the merged settings
+func (c config) validate() (time.Duration, error) {
+	if len(c.Servers) == 0 {
+		return 0, errors.New("no servers configured")
+	}
+	if c.Output != "table" && c.Output != "json" {
+		return 0, fmt.Errorf("unknown output format %q, use table or json", c.Output)
+	}
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", c.Timeout)
+	}
+	return timeout, nil
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, strings.TrimRight(item, "/"))
+		}
+	}
+	return items
+}
@@ -1,14 +1,91 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"os"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/client"
 )
 
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: client [flags] <command> [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-8s %s\n           %s\n", cmd.name, cmd.summary, cmd.args)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(out, "\nSettings are read from the config file, then AUCTION_SERVERS, AUCTION_OUTPUT,\n"+
+		"AUCTION_TIMEOUT, AUCTION_API_KEY and AUCTION_TOKEN, then the flags above, each\n"+
+		"overriding the last.\n")
+}
+
 func main() {
-	// Get port from environment variable or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Command line flags
+	configPath := flag.String("config", "", "Config file, by default $AUCTION_CONFIG or "+defaultConfigPath())
+	servers := flag.String("servers", "", "Server URLs to try in order, comma separated")
+	output := flag.String("output", "", "Output format: table or json")
+	timeout := flag.String("timeout", "", "Timeout of each request, such as 10s")
+	apiKey := flag.String("api-key", "", "API key to authenticate with, as returned by register")
+	token := flag.String("token", "", "Token to authenticate with, as returned by token")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	// Later sources override earlier ones: defaults, file, environment, flags
+	cfg := defaultConfig()
+	path, explicit := *configPath, *configPath != ""
+	if !explicit {
+		path = os.Getenv("AUCTION_CONFIG")
+		explicit = path != ""
+	}
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	if path != "" {
+		if err := loadConfigFile(&cfg, path, explicit); err != nil {
+			fatal(err)
+		}
 	}
+	loadEnv(&cfg)
+	cfg.merge(config{Servers: splitList(*servers), Output: *output, Timeout: *timeout, APIKey: *apiKey, Token: *token})
+
+	requestTimeout, err := cfg.validate()
+	if err != nil {
+		fatal(err)
+	}
+
+	app := &app{
+		api:     client.New(cfg.Servers, cfg.clientOptions(requestTimeout)...),
+		printer: printer{out: os.Stdout, json: cfg.Output == "json"},
+	}
+
+	name, args := flag.Arg(0), flag.Args()[1:]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			err := cmd.run(app, args)
+			if errors.Is(err, flag.ErrHelp) {
+				os.Exit(2)
+			}
+			if err != nil {
+				fatal(err)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
 
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
 }
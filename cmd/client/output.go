@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/client"
+)
+
+// printer writes command results as tables or JSON
+type printer struct {
+	out  io.Writer
+	json bool
+}
+
+// print writes value as indented JSON, or calls table to write it as a table
+func (p printer) print(value interface{}, table func(w *tabwriter.Writer)) error {
+	if p.json {
+		enc := json.NewEncoder(p.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(value)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// formatSequence formats a bid's sequence number, which bids stored before
+// they were numbered lack
+func formatSequence(sequence uint64) string {
+	if sequence == 0 {
+		return "-"
+	}
+	return strconv.FormatUint(sequence, 10)
+}
+
+func formatMoney(m auction.Money) string {
+	if m.IsZero() && m.Currency == "" {
+		return "-"
+	}
+	return m.String()
+}
+
+func auctionsTable(page auction.ListPage) func(w *tabwriter.Writer) {
+	return func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tTYPE\tMINIMUM BID\tCURRENT BID\tEXPIRES\tCLOSED")
+		for _, a := range page.Auctions {
+			closed := "no"
+			if a.ClosedAt != nil {
+				closed = "yes"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", a.ID, a.Name, a.Type(), formatMoney(a.MinimumBid), formatMoney(a.CurrentBid), formatTime(a.ExpiryTime), closed)
+		}
+		if page.NextCursor != "" {
+			fmt.Fprintf(w, "\nMore auctions: -cursor %s\n", page.NextCursor)
+		}
+	}
+}
+
+func auctionTable(a auction.AuctionItem) func(w *tabwriter.Writer) {
+	return func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "ID\t%s\n", a.ID)
+		fmt.Fprintf(w, "Name\t%s\n", a.Name)
+		fmt.Fprintf(w, "Description\t%s\n", a.Description)
+		if a.Category != "" {
+			fmt.Fprintf(w, "Category\t%s\n", a.Category)
+		}
+		if len(a.Tags) > 0 {
+			fmt.Fprintf(w, "Tags\t%s\n", strings.Join(a.Tags, ", "))
+		}
+		if a.SellerID != "" {
+			fmt.Fprintf(w, "Seller\t%s\n", a.SellerID)
+		}
+		fmt.Fprintf(w, "Type\t%s\n", a.Type())
+		fmt.Fprintf(w, "Minimum bid\t%s\n", formatMoney(a.MinimumBid))
+		if !a.ReservePrice.IsZero() {
+			fmt.Fprintf(w, "Reserve price\t%s\n", formatMoney(a.ReservePrice))
+		}
+		if !a.BuyNowPrice.IsZero() {
+			fmt.Fprintf(w, "Buy-now price\t%s\n", formatMoney(a.BuyNowPrice))
+		}
+		if rule := a.IncrementRule; rule != nil {
+			switch rule.Type {
+			case auction.IncrementFixed:
+				fmt.Fprintf(w, "Increment\t%s\n", formatMoney(rule.Amount))
+			case auction.IncrementPercent:
+				fmt.Fprintf(w, "Increment\t%g%%\n", rule.Percent)
+			case auction.IncrementTiered:
+				for _, tier := range rule.Tiers {
+					bound := "above"
+					if !tier.UpTo.IsZero() {
+						bound = "below " + formatMoney(tier.UpTo)
+					}
+					fmt.Fprintf(w, "Increment\t%s %s\n", formatMoney(tier.Increment), bound)
+				}
+			}
+		}
+		if a.Dutch != nil {
+			fmt.Fprintf(w, "Dutch schedule\t%s down to %s, %s every %s\n", formatMoney(a.Dutch.StartPrice),
+				formatMoney(a.Dutch.FloorPrice), formatMoney(a.Dutch.Decrement), time.Duration(a.Dutch.Interval))
+		}
+		if a.ExtensionWindow > 0 {
+			fmt.Fprintf(w, "Anti-sniping\tbids in the last %s extend to %s\n", time.Duration(a.ExtensionWindow), time.Duration(a.ExtensionDuration))
+		}
+		fmt.Fprintf(w, "Created\t%s\n", formatTime(a.CreatedAt))
+		fmt.Fprintf(w, "Expires\t%s\n", formatTime(a.ExpiryTime))
+		if a.ClosedAt != nil {
+			fmt.Fprintf(w, "Closed\t%s\n", formatTime(*a.ClosedAt))
+		}
+	}
+}
+
+func statusTable(s client.AuctionStatus) func(w *tabwriter.Writer) {
+	return func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Auction\t%s (%s)\n", s.Auction.Name, s.Auction.ID)
+		fmt.Fprintf(w, "Status\t%s\n", s.Status)
+		if s.TimeRemaining != "" {
+			fmt.Fprintf(w, "Time remaining\t%s\n", s.TimeRemaining)
+		}
+		fmt.Fprintf(w, "Expires\t%s\n", formatTime(s.Auction.ExpiryTime))
+		if s.HighestBid != nil {
+			fmt.Fprintf(w, "Highest bid\t%s by %s\n", formatMoney(s.HighestBid.BidPrice), s.HighestBid.ParticipantID)
+		}
+		if s.BidCount != nil {
+			fmt.Fprintf(w, "Sealed bids\t%d\n", *s.BidCount)
+		}
+		if !s.CurrentPrice.IsZero() {
+			fmt.Fprintf(w, "Current price\t%s\n", formatMoney(s.CurrentPrice))
+		}
+		if !s.NextMinimumBid.IsZero() {
+			fmt.Fprintf(w, "Next minimum bid\t%s\n", formatMoney(s.NextMinimumBid))
+		}
+		if s.Reserve != "" {
+			fmt.Fprintf(w, "Reserve\t%s\n", s.Reserve)
+		}
+		if !s.BuyNowPrice.IsZero() {
+			fmt.Fprintf(w, "Buy now\t%s\n", formatMoney(s.BuyNowPrice))
+		}
+	}
+}
+
+func bidsTable(bids []auction.Bid) func(w *tabwriter.Writer) {
+	return func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "SEQ\tTIME\tPARTICIPANT\tPRICE\tAUTOMATIC\tID")
+		for _, b := range bids {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\n", formatSequence(b.Sequence), formatTime(b.Timestamp), b.ParticipantID, formatMoney(b.BidPrice), b.Automatic, b.ID)
+		}
+	}
+}
+
+func bidTable(b auction.Bid) func(w *tabwriter.Writer) {
+	return func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Bid\t%s\n", b.ID)
+		fmt.Fprintf(w, "Auction\t%s\n", b.AuctionItemID)
+		fmt.Fprintf(w, "Sequence\t%s\n", formatSequence(b.Sequence))
+		fmt.Fprintf(w, "Participant\t%s\n", b.ParticipantID)
+		fmt.Fprintf(w, "Price\t%s\n", formatMoney(b.BidPrice))
+		fmt.Fprintf(w, "Time\t%s\n", formatTime(b.Timestamp))
+		if b.Automatic {
+			fmt.Fprintln(w, "Automatic\tyes")
+		}
+	}
+}
+
+func settlementTable(s auction.Settlement) func(w *tabwriter.Writer) {
+	return func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Auction\t%s\n", s.AuctionItemID)
+		fmt.Fprintf(w, "Outcome\t%s\n", s.Outcome)
+		if s.Reason != "" {
+			fmt.Fprintf(w, "Reason\t%s\n", s.Reason)
+		}
+		if s.WinnerID != "" {
+			fmt.Fprintf(w, "Winner\t%s\n", s.WinnerID)
+			fmt.Fprintf(w, "Price\t%s\n", formatMoney(s.ClearingPrice))
+		}
+		fmt.Fprintf(w, "Closed\t%s\n", formatTime(s.ClosedAt))
+	}
+}
+
+// printEvent writes an event as a line of JSON, or a line of text
+func (p printer) printEvent(event auction.Event) error {
+	if p.json {
+		return json.NewEncoder(p.out).Encode(event)
+	}
+
+	var detail string
+	switch {
+	case event.Bid != nil:
+		detail = fmt.Sprintf("%s by %s", formatMoney(event.Bid.BidPrice), event.Bid.ParticipantID)
+	case event.ExpiryTime != nil:
+		detail = "expires " + formatTime(*event.ExpiryTime)
+	case event.Auction != nil:
+		detail = "expires " + formatTime(event.Auction.ExpiryTime)
+	case event.Settlement != nil && event.Settlement.WinnerID != "":
+		detail = fmt.Sprintf("won by %s at %s", event.Settlement.WinnerID, formatMoney(event.Settlement.ClearingPrice))
+	case event.Settlement != nil:
+		detail = fmt.Sprintf("%s: %s", event.Settlement.Outcome, event.Settlement.Reason)
+	}
+	_, err := fmt.Fprintf(p.out, "%d\t%s\t%s\t%s\n", event.Sequence, formatTime(event.Time), event.Type, detail)
+	return err
+}
+
+func registrationTable(r client.Registration) func(w *tabwriter.Writer) {
+	return func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Participant\t%s\n", r.Participant.ID)
+		fmt.Fprintf(w, "API key\t%s\n", r.APIKey)
+		fmt.Fprintln(w, "Keep the API key safe, it is not shown again.")
+	}
+}
+
+func tokenTable(t client.Token) func(w *tabwriter.Writer) {
+	return func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Token\t%s\n", t.Token)
+		fmt.Fprintf(w, "Expires\t%s\n", formatTime(t.ExpiresAt))
+	}
+}
+
+func membersTable(members []client.Member) func(w *tabwriter.Writer) {
+	return func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tURL\tVERSION\tSTARTED")
+		for _, m := range members {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.ID, m.URL, m.Version, formatTime(m.StartedAt))
+		}
+	}
+}
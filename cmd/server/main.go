@@ -1,22 +1,54 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
 	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/api"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auth"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/closer"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
 )
 
+// version is reported to the cluster, set at build time with
+// -ldflags "-X main.version=..."
+var version = "dev"
+
 func main() {
 	// Command line flags
 	zkHosts := flag.String("zk", "localhost:2181,localhost:2182,localhost:2183", "ZooKeeper hosts, comma separated")
 	port := flag.String("port", "", "HTTP server port")
 	useZK := flag.Bool("use-zk", false, "Use ZooKeeper for distributed storage")
+	zkBids := flag.String("zk-bids", "optimistic", "How ZooKeeper bids are serialized: optimistic or lock")
+	storeType := flag.String("store", "memory", "Storage backend: memory, zk or raft")
+	nodeID := flag.String("id", "", "Server ID in the cluster, by default the host and port of -advertise. With Raft, one of the IDs in -peers")
+	advertise := flag.String("advertise", "", "URL where clients reach this server, by default http://localhost:<port>")
+	peers := flag.String("peers", "", "Raft cluster members as id=url pairs, comma separated")
+	raftDir := flag.String("raft-dir", "", "Directory for Raft state, kept in memory if empty")
+	raftSecret := flag.String("raft-secret", "", "Secret the Raft servers authenticate each other with, by default $AUCTION_RAFT_SECRET")
+	requestTimeout := flag.Duration("request-timeout", api.DefaultRequestTimeout, "How long a request may wait on the store, 0 for no limit")
+	closeInterval := flag.Duration("close-interval", time.Second, "How often to settle expired auctions")
+	jwtSecret := flag.String("jwt-secret", "", "Secret for HS256 tokens, by default $AUCTION_JWT_SECRET")
+	jwtPublicKey := flag.String("jwt-public-key", "", "PEM file with the Ed25519 public key that verifies EdDSA tokens")
+	jwtPrivateKey := flag.String("jwt-private-key", "", "PEM file with the Ed25519 private key that signs EdDSA tokens")
 	flag.Parse()
 
+	// -use-zk is kept for backward compatibility
+	if *useZK {
+		*storeType = "zk"
+	}
+
 	// Get port from environment variable or flag or use default
 	if *port == "" {
 		*port = os.Getenv("PORT")
@@ -28,19 +60,164 @@ func main() {
 	var server *api.Server
 	var err error
 
-	if *useZK {
+	switch *storeType {
+	case "zk":
 		// Using ZooKeeper
 		zkHostsList := strings.Split(*zkHosts, ",")
-		server, err = api.NewZooKeeperServer(zkHostsList)
+		var opts []storage.ZKOption
+		switch *zkBids {
+		case "optimistic":
+		case "lock":
+			opts = append(opts, storage.WithLockedBids())
+		default:
+			log.Fatalf("Unknown -zk-bids mode %q", *zkBids)
+		}
+		server, err = api.NewZooKeeperServer(zkHostsList, opts...)
 		if err != nil {
 			log.Fatalf("Failed to create ZooKeeper server: %v", err)
 		}
 		log.Printf("Starting distributed auction server with ZooKeeper on port %s...", *port)
-	} else {
+	case "raft":
+		// Using Raft replication between the auction servers themselves
+		peerURLs, err := parsePeers(*peers)
+		if err != nil {
+			log.Fatalf("Invalid -peers: %v", err)
+		}
+		if _, ok := peerURLs[*nodeID]; !ok {
+			log.Fatalf("Node ID %q must be listed in -peers", *nodeID)
+		}
+		if *raftSecret == "" {
+			*raftSecret = os.Getenv("AUCTION_RAFT_SECRET")
+		}
+		if *raftSecret == "" {
+			log.Fatalf("Raft servers need a shared -raft-secret or $AUCTION_RAFT_SECRET")
+		}
+		server, err = api.NewRaftServer(*nodeID, peerURLs, *raftDir, *raftSecret)
+		if err != nil {
+			log.Fatalf("Failed to create Raft server: %v", err)
+		}
+		log.Printf("Starting distributed auction server with Raft node %s on port %s...", *nodeID, *port)
+	case "memory":
 		// Using memory storage (for backward compatibility)
 		server = api.NewServer()
 		log.Printf("Starting standalone auction server on port %s...", *port)
+	default:
+		log.Fatalf("Unknown storage backend %q", *storeType)
+	}
+
+	keys, err := loadKeys(*jwtSecret, *jwtPublicKey, *jwtPrivateKey)
+	if err != nil {
+		log.Fatalf("Failed to load token keys: %v", err)
+	}
+	server.Keys = keys
+	server.RequestTimeout = *requestTimeout
+
+	if *advertise == "" {
+		*advertise = "http://localhost:" + *port
+	}
+	if *nodeID == "" {
+		advertised, err := url.Parse(*advertise)
+		if err != nil || advertised.Host == "" {
+			log.Fatalf("Invalid -advertise URL %q", *advertise)
+		}
+		*nodeID = advertised.Host
+	}
+
+	// Settle auctions as they expire
+	auctionCloser := closer.New(server.Store, *closeInterval)
+	auctionCloser.Start()
+
+	// Requests share a context that is cancelled on shutdown, so the store
+	// gives up on them rather than holding the server up
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	httpServer := &http.Server{
+		Addr:        ":" + *port,
+		Handler:     server.Router,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	listener, err := net.Listen("tcp", httpServer.Addr)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	log.Fatal(http.ListenAndServe(":"+*port, server.Router))
+	// Join the cluster once the server accepts connections, so clients that
+	// find it can reach it
+	err = server.Join(ctx, storage.Member{
+		ID:        *nodeID,
+		URL:       *advertise,
+		Version:   version,
+		StartedAt: time.Now(),
+	})
+	if err != nil {
+		log.Fatalf("Failed to join the cluster: %v", err)
+	}
+
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		log.Printf("Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Leave(shutdownCtx); err != nil {
+			log.Printf("Failed to leave the cluster: %v", err)
+		}
+		auctionCloser.Stop()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	if err := httpServer.Serve(listener); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-shutdown
+}
+
+// parsePeers parses a comma separated list of id=url pairs
+func parsePeers(value string) (map[string]string, error) {
+	peers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, url, ok := strings.Cut(pair, "=")
+		if !ok || id == "" || url == "" {
+			return nil, fmt.Errorf("expected id=url, got %q", pair)
+		}
+		peers[id] = url
+	}
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("no peers given")
+	}
+	return peers, nil
+}
+
+// loadKeys reads the keys used to verify and sign tokens. Without any,
+// participants authenticate with API keys only.
+func loadKeys(secret, publicKeyPath, privateKeyPath string) (auth.Keys, error) {
+	var keys auth.Keys
+	if secret == "" {
+		secret = os.Getenv("AUCTION_JWT_SECRET")
+	}
+	if secret != "" {
+		keys.HMACSecret = []byte(secret)
+	}
+
+	if privateKeyPath != "" {
+		private, err := auth.LoadEd25519PrivateKey(privateKeyPath)
+		if err != nil {
+			return auth.Keys{}, err
+		}
+		keys.Ed25519Private = private
+		keys.Ed25519Public = private.Public().(ed25519.PublicKey)
+	}
+	if publicKeyPath != "" {
+		public, err := auth.LoadEd25519PublicKey(publicKeyPath)
+		if err != nil {
+			return auth.Keys{}, err
+		}
+		keys.Ed25519Public = public
+	}
+	return keys, nil
 }
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auth"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
+)
+
+// tokenTTL is how long tokens issued by the server are valid
+const tokenTTL = time.Hour
+
+// Error codes for authentication failures
+const (
+	codeUnauthenticated      = "unauthenticated"
+	codeInvalidCredentials   = "invalid_credentials"
+	codeParticipantMismatch  = "participant_mismatch"
+	codeTokenIssuingDisabled = "token_issuing_disabled"
+)
+
+// authenticate identifies the participant behind a request's bearer
+// credential, an API key or a token, and adds it to the request context.
+// Requests without a credential pass through unidentified, while a
+// credential that does not check out is rejected.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, credential, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Bearer") || credential == "" {
+			writeError(w, http.StatusUnauthorized, codeInvalidCredentials, "Expected an Authorization: Bearer header", nil)
+			return
+		}
+
+		var participantID string
+		if auth.IsAPIKey(credential) {
+			id, secret, err := auth.ParseAPIKey(credential)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, codeInvalidCredentials, "Invalid API key", nil)
+				return
+			}
+			participant, err := s.Store.GetParticipant(r.Context(), id)
+			if errors.Is(err, storage.ErrParticipantNotFound) || (err == nil && !auth.CheckSecret(secret, participant.APIKeyHash)) {
+				writeError(w, http.StatusUnauthorized, codeInvalidCredentials, "Invalid API key", nil)
+				return
+			}
+			if err != nil {
+				writeStoreError(w, err)
+				return
+			}
+			participantID = participant.ID
+		} else {
+			if !s.Keys.CanVerify() {
+				writeError(w, http.StatusUnauthorized, codeInvalidCredentials, "This server does not accept tokens, use an API key", nil)
+				return
+			}
+			claims, err := s.Keys.Verify(credential, time.Now())
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, codeInvalidCredentials, "Invalid token: "+err.Error(), nil)
+				return
+			}
+			participantID = claims.Subject
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithParticipant(r.Context(), participantID)))
+	})
+}
+
+// requireParticipant only lets authenticated requests through
+func requireParticipant(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := auth.ParticipantFrom(r.Context()); !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, codeUnauthenticated, "Authentication required, send an API key or token as a bearer credential", nil)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// actingParticipant returns the authenticated participant of a request.
+// Requests may still name a participant, but only the one they are
+// authenticated as, so a false one gets an error response.
+func actingParticipant(w http.ResponseWriter, r *http.Request, named string) (string, bool) {
+	participantID, _ := auth.ParticipantFrom(r.Context())
+	if named != "" && named != participantID {
+		writeError(w, http.StatusForbidden, codeParticipantMismatch, "Requests can only act for the authenticated participant", map[string]interface{}{
+			"participant_id": participantID,
+		})
+		return "", false
+	}
+	return participantID, true
+}
+
+// RegisterParticipant handles requests to register a participant and
+// returns its API key, which is not shown again
+func (s *Server) RegisterParticipant(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
+
+	if !auth.ValidParticipantID(req.ID) {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "id must be 1 to 64 letters, digits, underscores or hyphens", nil)
+		return
+	}
+
+	key, hash, err := auth.NewAPIKey(req.ID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	participant, err := s.Store.RegisterParticipant(r.Context(), auction.Participant{ID: req.ID, APIKeyHash: hash})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"participant": participant.Public(),
+		"api_key":     key,
+	})
+}
+
+// GetCurrentParticipant handles requests for the authenticated participant
+func (s *Server) GetCurrentParticipant(w http.ResponseWriter, r *http.Request) {
+	participantID, _ := auth.ParticipantFrom(r.Context())
+
+	participant, err := s.Store.GetParticipant(r.Context(), participantID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(participant.Public())
+}
+
+// IssueToken handles requests to exchange a credential for a token, which
+// any server with the same keys can verify without a registry lookup
+func (s *Server) IssueToken(w http.ResponseWriter, r *http.Request) {
+	if !s.Keys.CanSign() {
+		writeError(w, http.StatusNotFound, codeTokenIssuingDisabled, "This server does not issue tokens", nil)
+		return
+	}
+
+	participantID, _ := auth.ParticipantFrom(r.Context())
+	claims := auth.NewClaims(participantID, time.Now(), tokenTTL)
+	token, err := s.Keys.Sign(claims)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":      token,
+		"expires_at": time.Unix(claims.ExpiresAt, 0).UTC(),
+	})
+}
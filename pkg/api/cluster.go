@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
+)
+
+// clusterResponse is the body of a cluster listing
+type clusterResponse struct {
+	// Self is the server that answered, if it joined
+	Self *storage.Member `json:"self,omitempty"`
+	// Members are the live servers, including this one
+	Members []storage.Member `json:"members"`
+}
+
+// Join announces the server to the cluster. With a store that keeps a
+// registry of servers, other servers and clients find it there until it
+// leaves or stops. Otherwise GET /cluster only lists the server itself.
+func (s *Server) Join(ctx context.Context, self storage.Member) error {
+	if registry, ok := s.Store.(storage.Registry); ok {
+		if err := registry.Join(ctx, self); err != nil {
+			return err
+		}
+	}
+	s.self = &self
+	return nil
+}
+
+// Leave removes the server from the registry, so clients stop being sent
+// to it. It is called on shutdown, before the server drains its requests.
+func (s *Server) Leave(ctx context.Context) error {
+	registry, ok := s.Store.(storage.Registry)
+	if !ok || s.self == nil {
+		return nil
+	}
+	return registry.Leave(ctx, s.self.ID)
+}
+
+// GetCluster lists the servers of the cluster that are alive
+func (s *Server) GetCluster(w http.ResponseWriter, r *http.Request) {
+	response := clusterResponse{Self: s.self, Members: []storage.Member{}}
+	if registry, ok := s.Store.(storage.Registry); ok {
+		members, err := registry.Members(r.Context())
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		response.Members = members
+	} else if s.self != nil {
+		response.Members = []storage.Member{*s.self}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/consensus"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
+)
+
+// Error codes for failures found by the API itself. Store failures use the
+// code of their storage.Error.
+const (
+	codeInvalidRequest       = "invalid_request"
+	codeBidsSealed           = "bids_sealed"
+	codeBidNotFound          = "bid_not_found"
+	codeStreamingUnsupported = "streaming_unsupported"
+	codeUnavailable          = "unavailable"
+	codeOutcomeUnknown       = "outcome_unknown"
+	codeInternal             = "internal_error"
+)
+
+// storeErrorStatus is the HTTP status of each store error that is not a
+// plain bad request
+var storeErrorStatus = map[string]int{
+	storage.ErrAuctionNotFound.Code:      http.StatusNotFound,
+	storage.ErrNoBids.Code:               http.StatusNotFound,
+	storage.ErrAuctionNotClosed.Code:     http.StatusNotFound,
+	storage.ErrAuctionClosed.Code:        http.StatusConflict,
+	storage.ErrAuctionExpired.Code:       http.StatusConflict,
+	storage.ErrAuctionNotExpired.Code:    http.StatusConflict,
+	storage.ErrBuyNowReached.Code:        http.StatusConflict,
+	storage.ErrParticipantExists.Code:    http.StatusConflict,
+	storage.ErrParticipantNotFound.Code:  http.StatusNotFound,
+	storage.ErrNotSeller.Code:            http.StatusForbidden,
+	storage.ErrSellerBid.Code:            http.StatusForbidden,
+	storage.ErrAuctionHasBids.Code:       http.StatusConflict,
+	storage.ErrIdempotencyKeyReused.Code: http.StatusUnprocessableEntity,
+	storage.ErrBidConflict.Code:          http.StatusConflict,
+	storage.ErrSessionExpired.Code:       http.StatusServiceUnavailable,
+	storage.ErrConnectionLost.Code:       http.StatusServiceUnavailable,
+}
+
+// errorResponse is the body of every error response
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// writeError sends an error response in the JSON error format
+func writeError(w http.ResponseWriter, status int, code, message string, details map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: errorBody{
+		Code:    code,
+		Message: message,
+		Details: details,
+	}})
+}
+
+// writeStoreError sends the error response for an error returned by the store
+func writeStoreError(w http.ResponseWriter, err error) {
+	// Tell the bidder what they need to bid instead
+	var tooLow *storage.BidTooLowError
+	if errors.As(err, &tooLow) {
+		writeError(w, http.StatusBadRequest, storage.ErrBidTooLow.Code, err.Error(), map[string]interface{}{
+			"next_minimum_bid": tooLow.NextMinimumBid,
+		})
+		return
+	}
+
+	var storeErr *storage.Error
+	if errors.As(err, &storeErr) {
+		status, ok := storeErrorStatus[storeErr.Code]
+		if !ok {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, storeErr.Code, err.Error(), nil)
+		return
+	}
+
+	// The write may have been applied. Retrying it with the same
+	// idempotency key returns its outcome.
+	if errors.Is(err, consensus.ErrOutcomeUnknown) {
+		writeError(w, http.StatusServiceUnavailable, codeOutcomeUnknown, err.Error(), nil)
+		return
+	}
+
+	// The cluster could not agree or the request timed out, another server
+	// may do better
+	if errors.Is(err, consensus.ErrNoLeader) || errors.Is(err, consensus.ErrLeadershipLost) ||
+		errors.Is(err, consensus.ErrShutdown) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled) {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error(), nil)
+		return
+	}
+
+	writeError(w, http.StatusInternalServerError, codeInternal, err.Error(), nil)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// keepAliveInterval is how often an idle event stream sends a comment, so
+// proxies do not time the connection out
+const keepAliveInterval = 15 * time.Second
+
+// StreamEvents handles requests to follow the events of an auction as
+// Server-Sent Events. Each event's id is its sequence number: a client that
+// reconnects with Last-Event-ID, or the after query parameter, receives the
+// events it missed before new ones.
+func (s *Server) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	auctionID := vars["id"]
+
+	auctionItem, err := s.Store.GetAuction(r.Context(), auctionID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	// Resume after the last event the client saw
+	resume := r.Header.Get("Last-Event-ID")
+	if resume == "" {
+		resume = r.URL.Query().Get("after")
+	}
+	var after uint64
+	if resume != "" {
+		if after, err = strconv.ParseUint(resume, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid Last-Event-ID or after, expected a sequence number", nil)
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, codeStreamingUnsupported, "Streaming is not supported", nil)
+		return
+	}
+
+	events, err := s.Store.Subscribe(r.Context(), auctionID, after)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			// Sealed bids stay hidden, subscribers only learn that one arrived
+			if auctionItem.IsSealed() {
+				event.Bid = nil
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Sequence, event.Type, data)
+			flusher.Flush()
+
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
@@ -1,41 +1,110 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auth"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/consensus"
 	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
 	"github.com/gorilla/mux"
 )
 
+// nextCursorHeader carries the cursor of the next page of a listing
+const nextCursorHeader = "X-Next-Cursor"
+
+// DefaultRequestTimeout is how long a request may take unless the server
+// is configured otherwise
+const DefaultRequestTimeout = 10 * time.Second
+
+// eventsRoute names the route of event streams, which stay open for as
+// long as the client follows them
+const eventsRoute = "events"
+
 // Server represents the API server
 type Server struct {
 	Router *mux.Router
 	Store  storage.Store
+	// Keys verify bearer tokens, and sign them if a private key is set.
+	// Without keys only API keys are accepted.
+	Keys auth.Keys
+	// RequestTimeout bounds the time a request may spend waiting on the
+	// store, except for event streams. Zero means no limit.
+	RequestTimeout time.Duration
+
+	// self is the server as it joined the cluster, set by Join before the
+	// server starts serving
+	self *storage.Member
 }
 
 // NewZooKeeperServer creates a new API server with ZooKeeper storage
-func NewZooKeeperServer(zkHosts []string) (*Server, error) {
-	store, err := storage.NewZKStore(zkHosts, "/auction-system")
+func NewZooKeeperServer(zkHosts []string, opts ...storage.ZKOption) (*Server, error) {
+	store, err := storage.NewZKStore(zkHosts, "/auction-system", opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	server := &Server{
-		Router: mux.NewRouter(),
-		Store:  store,
+		Router:         mux.NewRouter(),
+		Store:          store,
+		RequestTimeout: DefaultRequestTimeout,
 	}
 	server.setupRoutes()
 	return server, nil
 }
 
+// NewRaftServer creates a new API server with Raft-replicated storage.
+// peers maps the ID of every node in the cluster, including id, to its base URL.
+// The nodes authenticate their RPCs to each other with the shared secret.
+func NewRaftServer(id string, peers map[string]string, dataDir, secret string) (*Server, error) {
+	if secret == "" {
+		return nil, errors.New("a Raft cluster secret is required")
+	}
+
+	ids := make([]string, 0, len(peers))
+	for peerID := range peers {
+		ids = append(ids, peerID)
+	}
+
+	var persister consensus.Persister = consensus.NewMemoryPersister()
+	if dataDir != "" {
+		filePersister, err := consensus.NewFilePersister(dataDir)
+		if err != nil {
+			return nil, err
+		}
+		persister = filePersister
+	}
+
+	store, err := consensus.NewRaftStore(consensus.DefaultConfig(id, ids), consensus.NewHTTPTransport(peers, secret), persister)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{
+		Router:         mux.NewRouter(),
+		Store:          store,
+		RequestTimeout: DefaultRequestTimeout,
+	}
+	// Raft RPCs from the other nodes share the HTTP port with the API, and
+	// must present the cluster secret
+	server.Router.PathPrefix("/raft/").Handler(consensus.NewHTTPHandler(store.Node(), secret))
+	server.setupRoutes()
+	return server, nil
+}
+
 // NewServer creates a new API server
 func NewServer() *Server {
 	server := &Server{
-		Router: mux.NewRouter(),
-		Store:  storage.NewMemoryStore(),
+		Router:         mux.NewRouter(),
+		Store:          storage.NewMemoryStore(),
+		RequestTimeout: DefaultRequestTimeout,
 	}
 	server.setupRoutes()
 	return server
@@ -46,17 +115,32 @@ func (s *Server) setupRoutes() {
 
 	// Add CORS middleware
 	s.Router.Use(corsMiddleware)
+	s.Router.Use(s.withTimeout)
+	s.Router.Use(s.authenticate)
 
 	// Static file handling
 	s.Router.PathPrefix("/frontend/").Handler(http.StripPrefix("/frontend/", http.FileServer(http.Dir("./frontend"))))
 
 	s.Router.HandleFunc("/", serveFrontend).Methods("GET")
-	s.Router.HandleFunc("/auctions", s.CreateAuction).Methods("POST")
+	s.Router.HandleFunc("/participants", s.RegisterParticipant).Methods("POST")
+	s.Router.HandleFunc("/participants/me", requireParticipant(s.GetCurrentParticipant)).Methods("GET")
+	s.Router.HandleFunc("/auth/token", requireParticipant(s.IssueToken)).Methods("POST")
+	s.Router.HandleFunc("/auctions", requireParticipant(s.CreateAuction)).Methods("POST")
 	s.Router.HandleFunc("/auctions", s.ListAuctions).Methods("GET")
 	s.Router.HandleFunc("/auctions/{id}", s.GetAuction).Methods("GET")
-	s.Router.HandleFunc("/auctions/{id}/bids", s.PlaceBid).Methods("POST")
+	s.Router.HandleFunc("/auctions/{id}", requireParticipant(s.UpdateAuction)).Methods("PATCH")
+	s.Router.HandleFunc("/auctions/{id}/cancel", requireParticipant(s.CancelAuction)).Methods("POST")
+	s.Router.HandleFunc("/auctions/{id}/bids", requireParticipant(s.PlaceBid)).Methods("POST")
+	s.Router.HandleFunc("/auctions/{id}/bids/{bid}", s.GetBid).Methods("GET")
 	s.Router.HandleFunc("/auctions/{id}/status", s.QueryAuctionStatus).Methods("GET")
 	s.Router.HandleFunc("/auctions/{id}/history", s.GetBidHistory).Methods("GET")
+	s.Router.HandleFunc("/auctions/{id}/result", s.GetAuctionResult).Methods("GET")
+	s.Router.HandleFunc("/auctions/{id}/accept", requireParticipant(s.AcceptPrice)).Methods("POST")
+	s.Router.HandleFunc("/auctions/{id}/buy", requireParticipant(s.BuyNow)).Methods("POST")
+	s.Router.HandleFunc("/auctions/{id}/events", s.StreamEvents).Methods("GET").Name(eventsRoute)
+	s.Router.HandleFunc("/search", s.SearchAuctions).Methods("GET")
+	s.Router.HandleFunc("/health", s.Health).Methods("GET")
+	s.Router.HandleFunc("/cluster", s.GetCluster).Methods("GET")
 }
 
 // corsMiddleware adds CORS headers to enable cross-origin requests
@@ -67,8 +151,9 @@ func corsMiddleware(next http.Handler) http.Handler {
 
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Last-Event-ID, "+idempotencyKeyHeader)
+		w.Header().Set("Access-Control-Expose-Headers", nextCursorHeader+", Location")
 
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
@@ -80,6 +165,22 @@ func corsMiddleware(next http.Handler) http.Handler {
 
 }
 
+// withTimeout cancels the context of a request once the request timeout
+// passes, so the store gives up on it. The client going away cancels it
+// too.
+func (s *Server) withTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); s.RequestTimeout <= 0 || (route != nil && route.GetName() == eventsRoute) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.RequestTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func serveFrontend(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html")
 	http.ServeFile(w, r, "./frontend/index.html")
@@ -87,21 +188,121 @@ func serveFrontend(w http.ResponseWriter, r *http.Request) {
 
 // CreateAuction handles requests to create a new auction item
 func (s *Server) CreateAuction(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
 	var item auction.AuctionItem
-	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if err := json.Unmarshal(body, &item); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid request payload", nil)
 		return
 	}
 
+	// The seller is whoever creates the auction
+	sellerID, ok := actingParticipant(w, r, item.SellerID)
+	if !ok {
+		return
+	}
+	item.SellerID = sellerID
+
+	key, ok := idempotencyKey(w, r, sellerID, body)
+	if !ok {
+		return
+	}
+
+	// The current bid is kept by the store
+	item.CurrentBid = auction.Money{}
+
+	// The floor of a Dutch auction doubles as its minimum bid
+	if item.Type() == auction.TypeDutch && item.Dutch != nil && item.MinimumBid.IsZero() {
+		item.MinimumBid = item.Dutch.FloorPrice
+	}
+
 	// Validate required fields
-	if item.Name == "" || item.MinimumBid <= 0 || item.ExpiryTime.IsZero() {
-		http.Error(w, "Missing required fields: name, minimum_bid, expiry_time", http.StatusBadRequest)
+	if item.Name == "" || item.MinimumBid.Amount <= 0 || item.ExpiryTime.IsZero() {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Missing required fields: name, minimum_bid, expiry_time", nil)
+		return
+	}
+
+	if err := item.CheckCurrency(); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error(), nil)
+		return
+	}
+
+	if err := item.NormalizeLabels(); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error(), nil)
+		return
+	}
+
+	switch item.Type() {
+	case auction.TypeEnglish, auction.TypeSealedFirstPrice, auction.TypeSealedSecondPrice:
+		if item.Dutch != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "Only dutch auctions take a price schedule", nil)
+			return
+		}
+	case auction.TypeDutch:
+		if item.Dutch == nil || item.Dutch.FloorPrice.Amount <= 0 || !item.Dutch.FloorPrice.Less(item.Dutch.StartPrice) ||
+			item.Dutch.Decrement.Amount <= 0 || item.Dutch.Interval <= 0 {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "Dutch auctions need start_price above floor_price, and a positive floor_price, decrement and interval", nil)
+			return
+		}
+		if item.ExtensionWindow != 0 || item.ExtensionDuration != 0 {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "Dutch auctions cannot be extended", nil)
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid auction_type", nil)
 		return
 	}
 
-	createdItem, err := s.Store.CreateAuction(item)
+	if item.IsSealed() && (item.ExtensionWindow != 0 || item.ExtensionDuration != 0) {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Sealed auctions cannot be extended", nil)
+		return
+	}
+
+	if item.ExtensionWindow < 0 || item.ExtensionDuration < 0 ||
+		(item.ExtensionWindow > 0) != (item.ExtensionDuration > 0) {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "extension_window and extension_duration must both be set and positive", nil)
+		return
+	}
+
+	if !item.ReservePrice.IsZero() {
+		if item.Type() == auction.TypeDutch {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "Dutch auctions use floor_price instead of a reserve", nil)
+			return
+		}
+		if item.ReservePrice.Less(item.MinimumBid) {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "reserve_price cannot be lower than minimum_bid", nil)
+			return
+		}
+	}
+
+	if item.IncrementRule != nil {
+		if item.Type() != auction.TypeEnglish {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "Only english auctions take an increment_rule", nil)
+			return
+		}
+		if err := item.IncrementRule.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid increment_rule: "+err.Error(), nil)
+			return
+		}
+	}
+
+	if !item.BuyNowPrice.IsZero() {
+		if item.Type() != auction.TypeEnglish {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "Only english auctions take a buy_now_price", nil)
+			return
+		}
+		if item.BuyNowPrice.Less(item.MinimumBid) || item.BuyNowPrice.Less(item.ReservePrice) {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "buy_now_price cannot be lower than minimum_bid or reserve_price", nil)
+			return
+		}
+	}
+
+	createdItem, err := s.Store.CreateAuctionIdempotent(r.Context(), key, item)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStoreError(w, err)
 		return
 	}
 
@@ -110,14 +311,66 @@ func (s *Server) CreateAuction(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(createdItem)
 }
 
-// ListAuctions handles GET /auctions
+// ListAuctions handles GET /auctions. The query parameters filter, sort and
+// page the auctions, and the cursor of the next page is returned in the
+// X-Next-Cursor header.
 func (s *Server) ListAuctions(w http.ResponseWriter, r *http.Request) {
-	auctions, err := s.Store.ListAuctions()
+	query, err := auction.ParseListQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error(), nil)
+		return
+	}
+
+	page, err := s.Store.ListAuctions(r.Context(), query)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	// Reserve prices are never shown to bidders
+	for i := range page.Auctions {
+		page.Auctions[i] = page.Auctions[i].Public()
+	}
+
+	if page.NextCursor != "" {
+		w.Header().Set(nextCursorHeader, page.NextCursor)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page.Auctions)
+}
+
+// SearchAuctions handles GET /search. The q parameter is the text to look
+// for, and the filters of GET /auctions narrow the results, which are
+// ordered by relevance.
+func (s *Server) SearchAuctions(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	text := strings.TrimSpace(params.Get("q"))
+	if text == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Missing query parameter: q", nil)
+		return
+	}
+	if params.Get("sort") != "" || params.Get("cursor") != "" {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Search results are ordered by relevance and cannot take sort or cursor", nil)
+		return
+	}
+
+	query, err := auction.ParseListQuery(params)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error(), nil)
 		return
 	}
 
+	auctions, err := s.Store.SearchAuctions(r.Context(), text, query)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	// Reserve prices are never shown to bidders
+	for i := range auctions {
+		auctions[i] = auctions[i].Public()
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(auctions)
 }
@@ -127,14 +380,77 @@ func (s *Server) GetAuction(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	item, err := s.Store.GetAuction(id)
+	item, err := s.Store.GetAuction(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeStoreError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(item)
+	json.NewEncoder(w).Encode(item.Public())
+}
+
+// UpdateAuction handles a seller's requests to edit an auction before the
+// first bid
+func (s *Server) UpdateAuction(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	auctionID := vars["id"]
+
+	var update auction.AuctionUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
+
+	if update.IsEmpty() {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Nothing to update, set description, category, tags or expiry_time", nil)
+		return
+	}
+
+	if err := update.NormalizeLabels(); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error(), nil)
+		return
+	}
+
+	sellerID, _ := auth.ParticipantFrom(r.Context())
+	item, err := s.Store.UpdateAuction(r.Context(), auctionID, sellerID, update)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item.Public())
+}
+
+// CancelAuction handles a seller's requests to withdraw an auction
+func (s *Server) CancelAuction(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	auctionID := vars["id"]
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
+
+	// Bidders are told why the auction was withdrawn
+	if strings.TrimSpace(req.Reason) == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Missing required field: reason", nil)
+		return
+	}
+
+	sellerID, _ := auth.ParticipantFrom(r.Context())
+	settlement, err := s.Store.CancelAuction(r.Context(), auctionID, sellerID, req.Reason)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settlement)
 }
 
 // PlaceBid handles requests to place a bid on an auction item
@@ -142,34 +458,98 @@ func (s *Server) PlaceBid(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	auctionID := vars["id"]
 
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
 	var bid auction.Bid
-	if err := json.NewDecoder(r.Body).Decode(&bid); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if err := json.Unmarshal(body, &bid); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid request payload", nil)
 		return
 	}
 
-	// Validate required fields
-	if bid.ParticipantID == "" || bid.BidPrice <= 0 {
-		http.Error(w, "Missing required fields: participant_id, bid_price", http.StatusBadRequest)
+	// Bids are placed by the authenticated participant
+	participantID, ok := actingParticipant(w, r, bid.ParticipantID)
+	if !ok {
 		return
 	}
+	bid.ParticipantID = participantID
 
-	// Set the auction ID from the URL
-	bid.AuctionItemID = auctionID
+	key, ok := idempotencyKey(w, r, participantID, body)
+	if !ok {
+		return
+	}
 
-	// Set timestamp if not provided
-	if bid.Timestamp.IsZero() {
-		bid.Timestamp = time.Now()
+	// Validate required fields
+	if bid.BidPrice.Amount <= 0 {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Missing required field: bid_price", nil)
+		return
+	}
+
+	if bid.MaxBid.Amount < 0 {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "max_bid must be positive", nil)
+		return
 	}
 
-	if err := s.Store.PlaceBid(bid); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// Set the auction ID from the URL. The store stamps the bid with its own
+	// time, whatever the client sent.
+	bid.AuctionItemID = auctionID
+
+	placed, err := s.Store.PlaceBidIdempotent(r.Context(), key, bid)
+	if err != nil {
+		writeStoreError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", bidLocation(placed))
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Bid placed successfully"})
+	json.NewEncoder(w).Encode(placed)
+}
+
+// bidLocation returns the path of a bid
+func bidLocation(bid auction.Bid) string {
+	return "/auctions/" + url.PathEscape(bid.AuctionItemID) + "/bids/" + url.PathEscape(bid.ID)
+}
+
+// GetBid handles requests for a single bid. A sealed bid is only shown to
+// its bidder until the auction closes.
+func (s *Server) GetBid(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	auctionID := vars["id"]
+	bidID := vars["bid"]
+
+	item, err := s.Store.GetAuction(r.Context(), auctionID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	bids, err := s.Store.GetBidHistory(r.Context(), auctionID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	for _, bid := range bids {
+		if bid.ID != bidID {
+			continue
+		}
+
+		participantID, _ := auth.ParticipantFrom(r.Context())
+		if item.IsSealed() && item.ClosedAt == nil && bid.ParticipantID != participantID {
+			writeError(w, http.StatusForbidden, codeBidsSealed, "Bids are sealed until the auction closes", nil)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bid)
+		return
+	}
+
+	// Revised sealed bids are replaced, so their ID is not found either
+	writeError(w, http.StatusNotFound, codeBidNotFound, "Bid not found", nil)
 }
 
 // QueryAuctionStatus handles requests to get the current status of an auction
@@ -178,40 +558,81 @@ func (s *Server) QueryAuctionStatus(w http.ResponseWriter, r *http.Request) {
 	auctionID := vars["id"]
 
 	// Get the auction
-	auctionItem, err := s.Store.GetAuction(auctionID)
+	auctionItem, err := s.Store.GetAuction(r.Context(), auctionID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeStoreError(w, err)
 		return
 	}
 
 	// Get the highest bid
-	highestBid, err := s.Store.GetHighestBid(auctionID)
+	highestBid, err := s.Store.GetHighestBid(r.Context(), auctionID)
 
 	// Prepare the response
 	type AuctionStatus struct {
-		Auction       auction.AuctionItem `json:"auction"`
-		HighestBid    *auction.Bid        `json:"highest_bid,omitempty"`
-		Status        string              `json:"status"`
-		TimeRemaining string              `json:"time_remaining,omitempty"`
+		Auction        auction.AuctionItem `json:"auction"`
+		HighestBid     *auction.Bid        `json:"highest_bid,omitempty"`
+		BidCount       *int                `json:"bid_count,omitempty"`
+		CurrentPrice   auction.Money       `json:"current_price,omitzero"`
+		NextMinimumBid auction.Money       `json:"next_minimum_bid,omitzero"`
+		Reserve        string              `json:"reserve,omitempty"`
+		BuyNowPrice    auction.Money       `json:"buy_now_price,omitzero"`
+		Status         string              `json:"status"`
+		TimeRemaining  string              `json:"time_remaining,omitempty"`
 	}
 
 	status := AuctionStatus{
-		Auction: auctionItem,
+		Auction: auctionItem.Public(),
 	}
 
 	// Set status based on auction expiry
-	if time.Now().After(auctionItem.ExpiryTime) {
-		status.Status = "expired"
-	} else {
-		status.Status = "active"
+	status.Status = auctionItem.Status(time.Now())
+	if status.Status == auction.StatusActive {
 		status.TimeRemaining = auctionItem.ExpiryTime.Sub(time.Now()).String()
+
+		// Dutch auctions report the live asking price
+		if auctionItem.Type() == auction.TypeDutch {
+			status.CurrentPrice = auctionItem.CurrentPrice(time.Now())
+		}
+
+		// English auctions report the lowest bid they will accept next
+		if auctionItem.Type() == auction.TypeEnglish {
+			if err == nil {
+				status.NextMinimumBid = auctionItem.NextMinimumBid(&highestBid)
+			} else {
+				status.NextMinimumBid = auctionItem.NextMinimumBid(nil)
+			}
+		}
+
+		// The buy-now price is offered until bidding reaches it
+		if !auctionItem.BuyNowPrice.IsZero() && (err != nil || highestBid.BidPrice.Less(auctionItem.BuyNowPrice)) {
+			status.BuyNowPrice = auctionItem.BuyNowPrice
+		}
 	}
 
-	// Include highest bid if available
-	if err == nil {
+	// Sealed bids stay hidden until close, only their number is shown
+	if auctionItem.IsSealed() && auctionItem.ClosedAt == nil {
+		bids, err := s.Store.GetBidHistory(r.Context(), auctionID)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		count := len(bids)
+		status.BidCount = &count
+	} else if err == nil {
+		// Include highest bid if available
 		status.HighestBid = &highestBid
 	}
 
+	// Say whether the reserve has been met without revealing the amount.
+	// Sealed auctions would leak their bids, so they only tell after close.
+	if !auctionItem.ReservePrice.IsZero() && (!auctionItem.IsSealed() || auctionItem.ClosedAt != nil) {
+		if err == nil && auctionItem.ReserveMet(highestBid.BidPrice) {
+			status.Reserve = "reserve met"
+		} else {
+			status.Reserve = "reserve not met"
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
 }
@@ -221,12 +642,99 @@ func (s *Server) GetBidHistory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	auctionID := vars["id"]
 
-	bids, err := s.Store.GetBidHistory(auctionID)
+	item, err := s.Store.GetAuction(r.Context(), auctionID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeStoreError(w, err)
+		return
+	}
+
+	// Sealed bids must not be disclosed before the auction closes
+	if item.IsSealed() && item.ClosedAt == nil {
+		writeError(w, http.StatusForbidden, codeBidsSealed, "Bids are sealed until the auction closes", nil)
+		return
+	}
+
+	bids, err := s.Store.GetBidHistory(r.Context(), auctionID)
+	if err != nil {
+		writeStoreError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(bids)
 }
+
+// AcceptPrice handles requests to accept the current price of a Dutch auction
+func (s *Server) AcceptPrice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	auctionID := vars["id"]
+
+	// The body is optional, participant_id defaults to the authenticated participant
+	var req struct {
+		ParticipantID string `json:"participant_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
+
+	participantID, ok := actingParticipant(w, r, req.ParticipantID)
+	if !ok {
+		return
+	}
+
+	settlement, err := s.Store.AcceptPrice(r.Context(), auctionID, participantID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(settlement)
+}
+
+// BuyNow handles requests to buy an auction at its buy-now price
+func (s *Server) BuyNow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	auctionID := vars["id"]
+
+	// The body is optional, participant_id defaults to the authenticated participant
+	var req struct {
+		ParticipantID string `json:"participant_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
+
+	participantID, ok := actingParticipant(w, r, req.ParticipantID)
+	if !ok {
+		return
+	}
+
+	settlement, err := s.Store.BuyNow(r.Context(), auctionID, participantID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(settlement)
+}
+
+// GetAuctionResult handles requests to get the settlement of a closed auction
+func (s *Server) GetAuctionResult(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	auctionID := vars["id"]
+
+	settlement, err := s.Store.GetSettlement(r.Context(), auctionID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settlement)
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/consensus"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
+)
+
+// testError is an error response as clients see it
+type testError struct {
+	Status  int
+	Code    string
+	Details map[string]interface{}
+}
+
+// decodeError reads the JSON error envelope of a response
+func decodeError(t *testing.T, status int, body []byte) testError {
+	t.Helper()
+	var envelope errorResponse
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		t.Fatalf("Expected a JSON error envelope with status %d, got %q", status, body)
+	}
+	if envelope.Error.Message == "" {
+		t.Errorf("Expected the %s error to have a message", envelope.Error.Code)
+	}
+	return testError{Status: status, Code: envelope.Error.Code, Details: envelope.Error.Details}
+}
+
+func TestWriteStoreError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{storage.ErrAuctionNotFound, http.StatusNotFound, "auction_not_found"},
+		{storage.ErrNoBids, http.StatusNotFound, "no_bids"},
+		{storage.ErrAuctionNotClosed, http.StatusNotFound, "auction_not_closed"},
+		{storage.ErrAuctionClosed, http.StatusConflict, "auction_closed"},
+		{storage.ErrAuctionExpired, http.StatusConflict, "auction_expired"},
+		{storage.ErrAuctionNotExpired, http.StatusConflict, "auction_not_expired"},
+		{storage.ErrBuyNowReached, http.StatusConflict, "buy_now_unavailable"},
+		{storage.ErrParticipantExists, http.StatusConflict, "participant_exists"},
+		{storage.ErrParticipantNotFound, http.StatusNotFound, "participant_not_found"},
+		{storage.ErrNotSeller, http.StatusForbidden, "not_seller"},
+		{storage.ErrSellerBid, http.StatusForbidden, "seller_bid"},
+		{storage.ErrAuctionHasBids, http.StatusConflict, "auction_has_bids"},
+		{storage.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, "idempotency_key_reused"},
+		{storage.ErrBidConflict, http.StatusConflict, "bid_conflict"},
+		{storage.ErrSessionExpired, http.StatusServiceUnavailable, "session_expired"},
+		{storage.ErrConnectionLost, http.StatusServiceUnavailable, "connection_lost"},
+		// Store errors without a status of their own are bad requests
+		{storage.ErrBidTooLow, http.StatusBadRequest, "bid_too_low"},
+		{storage.ErrCurrencyMismatch, http.StatusBadRequest, "currency_mismatch"},
+		{storage.ErrMaxBidTooLow, http.StatusBadRequest, "max_bid_too_low"},
+		{storage.ErrProxyBidSealed, http.StatusBadRequest, "proxy_bid_not_allowed"},
+		{storage.ErrDutchBid, http.StatusBadRequest, "bids_not_accepted"},
+		{storage.ErrNotDutch, http.StatusBadRequest, "not_dutch_auction"},
+		{storage.ErrNoBuyNow, http.StatusBadRequest, "no_buy_now_price"},
+		{storage.ErrExpiryNotExtended, http.StatusBadRequest, "expiry_not_extended"},
+		{fmt.Errorf("placing bid: %w", storage.ErrAuctionNotFound), http.StatusNotFound, "auction_not_found"},
+		{consensus.ErrOutcomeUnknown, http.StatusServiceUnavailable, "outcome_unknown"},
+		{consensus.ErrNoLeader, http.StatusServiceUnavailable, "unavailable"},
+		{consensus.ErrLeadershipLost, http.StatusServiceUnavailable, "unavailable"},
+		{consensus.ErrShutdown, http.StatusServiceUnavailable, "unavailable"},
+		{context.DeadlineExceeded, http.StatusServiceUnavailable, "unavailable"},
+		{context.Canceled, http.StatusServiceUnavailable, "unavailable"},
+		{errors.New("disk on fire"), http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeStoreError(rec, tt.err)
+		got := decodeError(t, rec.Code, rec.Body.Bytes())
+		if got.Status != tt.status || got.Code != tt.code {
+			t.Errorf("%v: expected %d %s, got %d %s", tt.err, tt.status, tt.code, got.Status, got.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%v: expected a JSON response, got %q", tt.err, ct)
+		}
+	}
+
+	// Every store error with a status of its own is covered above
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		var storeErr *storage.Error
+		if errors.As(tt.err, &storeErr) {
+			covered[storeErr.Code] = true
+		}
+	}
+	for code := range storeErrorStatus {
+		if !covered[code] {
+			t.Errorf("Expected a test case for %s", code)
+		}
+	}
+
+	// A bid that is too low tells the bidder what to bid instead
+	rec := httptest.NewRecorder()
+	writeStoreError(rec, &storage.BidTooLowError{NextMinimumBid: auction.NewMoney(1100, "USD")})
+	got := decodeError(t, rec.Code, rec.Body.Bytes())
+	if got.Status != http.StatusBadRequest || got.Code != "bid_too_low" || got.Details["next_minimum_bid"] == nil {
+		t.Errorf("Expected a 400 bid_too_low with the next minimum bid, got %+v", got)
+	}
+}
+
+// testAPI drives the routes of a server backed by a MemoryStore
+type testAPI struct {
+	t      *testing.T
+	server *Server
+	url    string
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	server := NewServer()
+	ts := httptest.NewServer(server.Router)
+	t.Cleanup(ts.Close)
+	return &testAPI{t: t, server: server, url: ts.URL}
+}
+
+// call sends a request as the participant with the API key, if any, and
+// returns the status and body of the response
+func (a *testAPI) call(method, path, apiKey string, body interface{}, header http.Header) (int, []byte) {
+	a.t.Helper()
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			a.t.Fatalf("Failed to encode request: %v", err)
+		}
+	}
+	req, err := http.NewRequest(method, a.url+path, bytes.NewReader(payload))
+	if err != nil {
+		a.t.Fatalf("Failed to build request: %v", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("Failed to send %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	return resp.StatusCode, buf.Bytes()
+}
+
+// fail sends a request that must fail and returns its error
+func (a *testAPI) fail(method, path, apiKey string, body interface{}, header http.Header) testError {
+	a.t.Helper()
+	status, data := a.call(method, path, apiKey, body, header)
+	if status < 400 {
+		a.t.Fatalf("Expected %s %s to fail, got %d %s", method, path, status, data)
+	}
+	return decodeError(a.t, status, data)
+}
+
+// register registers a participant and returns its API key
+func (a *testAPI) register(id string) string {
+	a.t.Helper()
+	status, data := a.call(http.MethodPost, "/participants", "", map[string]string{"id": id}, nil)
+	var registration struct {
+		APIKey string `json:"api_key"`
+	}
+	if status != http.StatusCreated || json.Unmarshal(data, &registration) != nil {
+		a.t.Fatalf("Failed to register %s: %d %s", id, status, data)
+	}
+	return registration.APIKey
+}
+
+// create stores an auction by the seller directly, so that it may have
+// expired already
+func (a *testAPI) create(item auction.AuctionItem) auction.AuctionItem {
+	a.t.Helper()
+	if item.MinimumBid.IsZero() {
+		item.MinimumBid = auction.NewMoney(1000, "USD")
+	}
+	if item.ExpiryTime.IsZero() {
+		item.ExpiryTime = time.Now().Add(time.Hour)
+	}
+	created, err := a.server.Store.CreateAuction(context.Background(), item)
+	if err != nil {
+		a.t.Fatalf("Failed to create auction: %v", err)
+	}
+	return created
+}
+
+func usd(cents int64) auction.Money {
+	return auction.NewMoney(cents, "USD")
+}
+
+func TestHandlerStoreErrors(t *testing.T) {
+	a := newTestAPI(t)
+	sam, bob, alice := a.register("sam"), a.register("bob"), a.register("alice")
+
+	english := a.create(auction.AuctionItem{Name: "Lamp", SellerID: "sam", BuyNowPrice: usd(5000)})
+	plain := a.create(auction.AuctionItem{Name: "Chair", SellerID: "sam"})
+	expired := a.create(auction.AuctionItem{Name: "Vase", SellerID: "sam", ExpiryTime: time.Now().Add(-time.Minute)})
+	sealed := a.create(auction.AuctionItem{Name: "Rug", SellerID: "sam", AuctionType: auction.TypeSealedFirstPrice})
+	dutch := a.create(auction.AuctionItem{Name: "Clock", SellerID: "sam", AuctionType: auction.TypeDutch, MinimumBid: usd(1000),
+		Dutch: &auction.DutchSchedule{StartPrice: usd(5000), FloorPrice: usd(1000), Decrement: usd(100), Interval: auction.Duration(time.Minute)}})
+	cancelled := a.create(auction.AuctionItem{Name: "Desk", SellerID: "sam"})
+	if status, data := a.call(http.MethodPost, "/auctions/"+cancelled.ID+"/cancel", sam, map[string]string{"reason": "sold elsewhere"}, nil); status != http.StatusOK {
+		t.Fatalf("Failed to cancel auction: %d %s", status, data)
+	}
+	if status, data := a.call(http.MethodPost, "/auctions/"+english.ID+"/bids", bob, map[string]interface{}{"bid_price": usd(2000)}, nil); status != http.StatusCreated {
+		t.Fatalf("Failed to place bid: %d %s", status, data)
+	}
+
+	bid := func(price int64) map[string]interface{} {
+		return map[string]interface{}{"bid_price": usd(price)}
+	}
+	later := map[string]interface{}{"expiry_time": time.Now().Add(2 * time.Hour)}
+	earlier := map[string]interface{}{"expiry_time": time.Now().Add(time.Minute)}
+	keyed := http.Header{idempotencyKeyHeader: {"bid-1"}}
+
+	tests := []struct {
+		name         string
+		method, path string
+		apiKey       string
+		body         interface{}
+		header       http.Header
+		status       int
+		code         string
+	}{
+		{"missing auction", http.MethodGet, "/auctions/missing", "", nil, nil, http.StatusNotFound, "auction_not_found"},
+		{"bid on missing auction", http.MethodPost, "/auctions/missing/bids", bob, bid(2000), nil, http.StatusNotFound, "auction_not_found"},
+		{"status of missing auction", http.MethodGet, "/auctions/missing/status", "", nil, nil, http.StatusNotFound, "auction_not_found"},
+		{"history of missing auction", http.MethodGet, "/auctions/missing/history", "", nil, nil, http.StatusNotFound, "auction_not_found"},
+		{"result before close", http.MethodGet, "/auctions/" + english.ID + "/result", "", nil, nil, http.StatusNotFound, "auction_not_closed"},
+		{"duplicate participant", http.MethodPost, "/participants", "", map[string]string{"id": "bob"}, nil, http.StatusConflict, "participant_exists"},
+		{"bid too low", http.MethodPost, "/auctions/" + english.ID + "/bids", alice, bid(2000), nil, http.StatusBadRequest, "bid_too_low"},
+		{"bid in another currency", http.MethodPost, "/auctions/" + english.ID + "/bids", alice, map[string]interface{}{"bid_price": auction.NewMoney(3000, "EUR")}, nil, http.StatusBadRequest, "currency_mismatch"},
+		{"max bid below bid", http.MethodPost, "/auctions/" + english.ID + "/bids", alice, map[string]interface{}{"bid_price": usd(3000), "max_bid": usd(2500)}, nil, http.StatusBadRequest, "max_bid_too_low"},
+		{"proxy bid on sealed auction", http.MethodPost, "/auctions/" + sealed.ID + "/bids", alice, map[string]interface{}{"bid_price": usd(3000), "max_bid": usd(4000)}, nil, http.StatusBadRequest, "proxy_bid_not_allowed"},
+		{"bid on dutch auction", http.MethodPost, "/auctions/" + dutch.ID + "/bids", alice, bid(3000), nil, http.StatusBadRequest, "bids_not_accepted"},
+		{"bid on expired auction", http.MethodPost, "/auctions/" + expired.ID + "/bids", alice, bid(3000), nil, http.StatusConflict, "auction_expired"},
+		{"bid on cancelled auction", http.MethodPost, "/auctions/" + cancelled.ID + "/bids", alice, bid(3000), nil, http.StatusConflict, "auction_closed"},
+		{"seller bid", http.MethodPost, "/auctions/" + plain.ID + "/bids", sam, bid(2000), nil, http.StatusForbidden, "seller_bid"},
+		{"accept english auction", http.MethodPost, "/auctions/" + english.ID + "/accept", alice, nil, nil, http.StatusBadRequest, "not_dutch_auction"},
+		{"buy without buy-now price", http.MethodPost, "/auctions/" + plain.ID + "/buy", alice, nil, nil, http.StatusBadRequest, "no_buy_now_price"},
+		{"edit by another participant", http.MethodPatch, "/auctions/" + plain.ID, bob, later, nil, http.StatusForbidden, "not_seller"},
+		{"cancel by another participant", http.MethodPost, "/auctions/" + plain.ID + "/cancel", bob, map[string]string{"reason": "mine now"}, nil, http.StatusForbidden, "not_seller"},
+		{"edit after a bid", http.MethodPatch, "/auctions/" + english.ID, sam, later, nil, http.StatusConflict, "auction_has_bids"},
+		{"expiry moved earlier", http.MethodPatch, "/auctions/" + plain.ID, sam, earlier, nil, http.StatusBadRequest, "expiry_not_extended"},
+		{"unauthenticated bid", http.MethodPost, "/auctions/" + english.ID + "/bids", "", bid(3000), nil, http.StatusUnauthorized, "unauthenticated"},
+		{"invalid payload", http.MethodPost, "/auctions/" + english.ID + "/bids", alice, "not a bid", nil, http.StatusBadRequest, "invalid_request"},
+		{"first keyed bid", http.MethodPost, "/auctions/" + english.ID + "/bids", alice, bid(3000), keyed, http.StatusCreated, ""},
+		{"idempotency key reused", http.MethodPost, "/auctions/" + english.ID + "/bids", alice, bid(3500), keyed, http.StatusUnprocessableEntity, "idempotency_key_reused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, data := a.call(tt.method, tt.path, tt.apiKey, tt.body, tt.header)
+			if tt.code == "" {
+				if status != tt.status {
+					t.Fatalf("Expected %d, got %d %s", tt.status, status, data)
+				}
+				return
+			}
+			got := decodeError(t, status, data)
+			if got.Status != tt.status || got.Code != tt.code {
+				t.Errorf("Expected %d %s, got %d %s", tt.status, tt.code, got.Status, got.Code)
+			}
+		})
+	}
+
+	// A low bid is told the next minimum bid, and a bid reaching the buy-now
+	// price takes the option away
+	tooLow := a.fail(http.MethodPost, "/auctions/"+english.ID+"/bids", bob, bid(3000), nil)
+	if tooLow.Details["next_minimum_bid"] == nil {
+		t.Errorf("Expected bid_too_low to carry the next minimum bid, got %+v", tooLow)
+	}
+	if status, data := a.call(http.MethodPost, "/auctions/"+english.ID+"/bids", bob, bid(5000), nil); status != http.StatusCreated {
+		t.Fatalf("Failed to place bid: %d %s", status, data)
+	}
+	if got := a.fail(http.MethodPost, "/auctions/"+english.ID+"/buy", alice, nil, nil); got.Status != http.StatusConflict || got.Code != "buy_now_unavailable" {
+		t.Errorf("Expected 409 buy_now_unavailable, got %d %s", got.Status, got.Code)
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
+)
+
+// healthResponse is the body of a health check
+type healthResponse struct {
+	// Status is "ok", or "unavailable" while the store cannot serve requests
+	Status string `json:"status"`
+	// Store describes the connection of the store, for stores that have one
+	Store *storage.Health `json:"store,omitempty"`
+}
+
+// Health reports whether the server can serve requests. A ZooKeeper store
+// reports its session, and the server is unavailable while it has none, so
+// a load balancer can route around it.
+func (s *Server) Health(w http.ResponseWriter, r *http.Request) {
+	response := healthResponse{Status: "ok"}
+	status := http.StatusOK
+	if checker, ok := s.Store.(storage.HealthChecker); ok {
+		health := checker.Health()
+		response.Store = &health
+		if !health.Connected {
+			response.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
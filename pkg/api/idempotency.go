@@ -0,0 +1,41 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
+)
+
+// idempotencyKeyHeader is set by clients to make a write safe to retry:
+// every request with the same key gets the result of the first one
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds the keys clients may choose
+const maxIdempotencyKeyLength = 255
+
+// idempotencyKey returns the Idempotency-Key of a write by the participant,
+// fingerprinted by its method, path and body. The key is zero if the header
+// is absent. It writes an error response and returns false if the key is
+// too long.
+func idempotencyKey(w http.ResponseWriter, r *http.Request, participantID string, body []byte) (storage.IdempotencyKey, bool) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		return storage.IdempotencyKey{}, true
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("%s is longer than %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength), nil)
+		return storage.IdempotencyKey{}, false
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.Path)
+	h.Write(body)
+	return storage.IdempotencyKey{
+		ParticipantID: participantID,
+		Key:           key,
+		Fingerprint:   hex.EncodeToString(h.Sum(nil)),
+	}, true
+}
@@ -0,0 +1,31 @@
+package auction
+
+import "time"
+
+// EventType names a change to an auction
+type EventType string
+
+const (
+	// EventBidPlaced is a bid being accepted, including automatic proxy bids
+	EventBidPlaced EventType = "bid_placed"
+	// EventExtended is a late bid pushing the expiry time out
+	EventExtended EventType = "extended"
+	// EventUpdated is the seller editing the auction
+	EventUpdated EventType = "updated"
+	// EventClosed is the auction being settled, or cancelled by the seller
+	EventClosed EventType = "closed"
+)
+
+// Event is a change to an auction. Events of an auction are numbered from 1
+// in the order they happened, and every server reports the same numbers.
+type Event struct {
+	Sequence   uint64      `json:"sequence"`
+	Type       EventType   `json:"type"`
+	AuctionID  string      `json:"auction_id"`
+	Time       time.Time   `json:"time"`
+	Bid        *Bid        `json:"bid,omitempty"`
+	ExpiryTime *time.Time  `json:"expiry_time,omitempty"`
+	Settlement *Settlement `json:"settlement,omitempty"`
+	// Auction is the edited auction of an updated event, without its reserve
+	Auction *AuctionItem `json:"auction,omitempty"`
+}
@@ -0,0 +1,111 @@
+package auction
+
+import (
+	"errors"
+	"math"
+)
+
+// IncrementType selects how an IncrementRule computes the bid increment
+type IncrementType string
+
+const (
+	// IncrementFixed adds the same amount at every price
+	IncrementFixed IncrementType = "fixed"
+	// IncrementPercent adds a percentage of the current price
+	IncrementPercent IncrementType = "percent"
+	// IncrementTiered looks the increment up in a table of price bands
+	IncrementTiered IncrementType = "tiered"
+)
+
+// IncrementTier is one band of a tiered increment table. It applies to
+// prices below UpTo; the last tier leaves UpTo unset and covers the rest.
+type IncrementTier struct {
+	UpTo      Money `json:"up_to,omitzero"`
+	Increment Money `json:"increment"`
+}
+
+// IncrementRule is how much a new bid must beat the highest bid by
+type IncrementRule struct {
+	Type    IncrementType   `json:"type"`
+	Amount  Money           `json:"amount,omitzero"`
+	Percent float64         `json:"percent,omitempty"`
+	Tiers   []IncrementTier `json:"tiers,omitempty"`
+}
+
+// Validate checks that the rule is complete and consistent
+func (r IncrementRule) Validate() error {
+	switch r.Type {
+	case IncrementFixed:
+		if r.Amount.Amount <= 0 {
+			return errors.New("fixed increments need a positive amount")
+		}
+	case IncrementPercent:
+		if r.Percent <= 0 {
+			return errors.New("percent increments need a positive percent")
+		}
+	case IncrementTiered:
+		if len(r.Tiers) == 0 {
+			return errors.New("tiered increments need at least one tier")
+		}
+		var prev int64
+		for i, tier := range r.Tiers {
+			if tier.Increment.Amount <= 0 {
+				return errors.New("every tier needs a positive increment")
+			}
+			// Only the last tier may leave up_to open
+			if (!tier.UpTo.IsZero() || i < len(r.Tiers)-1) && tier.UpTo.Amount <= prev {
+				return errors.New("tiers must be ordered by a rising up_to")
+			}
+			prev = tier.UpTo.Amount
+		}
+	default:
+		return errors.New("increment type must be fixed, percent or tiered")
+	}
+	return nil
+}
+
+// Increment returns the amount a bid must beat price by
+func (r IncrementRule) Increment(price Money) Money {
+	switch r.Type {
+	case IncrementPercent:
+		// Round up to a whole minor unit so the increment is never zero
+		amount := int64(math.Ceil(float64(price.Amount)*r.Percent/100 - 1e-9))
+		return Money{Amount: max(amount, 1), Currency: price.Currency}
+	case IncrementTiered:
+		for _, tier := range r.Tiers {
+			if tier.UpTo.IsZero() || price.Less(tier.UpTo) {
+				return tier.Increment
+			}
+		}
+		return r.Tiers[len(r.Tiers)-1].Increment
+	default:
+		return r.Amount
+	}
+}
+
+// BidIncrement returns how much a bid must beat price by in this auction.
+// Auctions without a rule accept any higher bid.
+func (a AuctionItem) BidIncrement(price Money) Money {
+	if a.IncrementRule == nil {
+		return SmallestUnit(a.Currency())
+	}
+	return a.IncrementRule.Increment(price)
+}
+
+// ProxyIncrement returns how much proxy bids outbid price by. Auctions
+// without a rule step by one major unit of their currency.
+func (a AuctionItem) ProxyIncrement(price Money) Money {
+	if a.IncrementRule == nil {
+		return OneUnit(a.Currency())
+	}
+	return a.IncrementRule.Increment(price)
+}
+
+// NextMinimumBid returns the lowest bid the auction accepts after highest,
+// or the minimum bid if there is no highest bid yet
+func (a AuctionItem) NextMinimumBid(highest *Bid) Money {
+	if highest == nil {
+		return a.MinimumBid
+	}
+	return highest.BidPrice.Add(a.BidIncrement(highest.BidPrice))
+}
@@ -0,0 +1,70 @@
+package auction
+
+import "testing"
+
+func TestIncrementRules(t *testing.T) {
+	tiered := IncrementRule{
+		Type: IncrementTiered,
+		Tiers: []IncrementTier{
+			{UpTo: usd(50), Increment: usd(1)},
+			{UpTo: usd(500), Increment: usd(5)},
+			{Increment: usd(25)},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		rule  *IncrementRule
+		price float64
+		want  float64
+	}{
+		{"default", nil, 40, 40.01},
+		{"fixed", &IncrementRule{Type: IncrementFixed, Amount: usd(2.5)}, 40, 42.5},
+		{"percent", &IncrementRule{Type: IncrementPercent, Percent: 5}, 40, 42},
+		{"percent rounds up", &IncrementRule{Type: IncrementPercent, Percent: 5}, 10.1, 10.61},
+		{"lowest tier", &tiered, 49.99, 50.99},
+		{"tier boundary", &tiered, 50, 55},
+		{"open tier", &tiered, 1000, 1025},
+	}
+	for _, tt := range tests {
+		item := AuctionItem{MinimumBid: usd(10), IncrementRule: tt.rule}
+		if got := item.NextMinimumBid(&Bid{BidPrice: usd(tt.price)}); got != usd(tt.want) {
+			t.Errorf("%s: expected next minimum bid %v after %.2f, got %v", tt.name, usd(tt.want), tt.price, got)
+		}
+	}
+
+	if got := (AuctionItem{MinimumBid: usd(10)}).NextMinimumBid(nil); got != usd(10) {
+		t.Errorf("Expected the minimum bid without bids, got %v", got)
+	}
+
+	yen := AuctionItem{MinimumBid: NewMoney(1000, "JPY")}
+	if got := yen.NextMinimumBid(&Bid{BidPrice: NewMoney(1500, "JPY")}); got != NewMoney(1501, "JPY") {
+		t.Errorf("Expected the default increment to be one yen, got %v", got)
+	}
+
+	// Without a rule any higher bid is accepted, but proxies step by one unit
+	if got := (AuctionItem{MinimumBid: usd(10)}).ProxyIncrement(usd(40)); got != usd(1) {
+		t.Errorf("Expected the default proxy increment to be one dollar, got %v", got)
+	}
+}
+
+func TestIncrementRuleValidate(t *testing.T) {
+	invalid := []IncrementRule{
+		{Type: "bogus"},
+		{Type: IncrementFixed},
+		{Type: IncrementPercent, Percent: -1},
+		{Type: IncrementTiered},
+		{Type: IncrementTiered, Tiers: []IncrementTier{{Increment: usd(1)}, {UpTo: usd(50), Increment: usd(5)}}},
+		{Type: IncrementTiered, Tiers: []IncrementTier{{UpTo: usd(500), Increment: usd(5)}, {UpTo: usd(50), Increment: usd(1)}}},
+	}
+	for _, rule := range invalid {
+		if rule.Validate() == nil {
+			t.Errorf("Expected rule %+v to be invalid", rule)
+		}
+	}
+
+	valid := IncrementRule{Type: IncrementTiered, Tiers: []IncrementTier{{UpTo: usd(50), Increment: usd(1)}, {Increment: usd(5)}}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected tiered rule to be valid: %v", err)
+	}
+}
@@ -1,17 +1,83 @@
 package auction
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 )
 
+// AuctionType selects the rules an auction is run under
+type AuctionType string
+
+const (
+	// TypeEnglish is an open ascending auction, the default
+	TypeEnglish AuctionType = "english"
+	// TypeSealedFirstPrice hides bids until close, the highest bid wins and pays its own price
+	TypeSealedFirstPrice AuctionType = "sealed_first_price"
+	// TypeSealedSecondPrice hides bids until close, the highest bid wins and pays the second highest price
+	TypeSealedSecondPrice AuctionType = "sealed_second_price"
+	// TypeDutch starts at a high price that falls over time, the first participant to accept it wins
+	TypeDutch AuctionType = "dutch"
+)
+
+// DutchSchedule describes how the price of a Dutch auction falls over time
+type DutchSchedule struct {
+	StartPrice Money    `json:"start_price"`
+	FloorPrice Money    `json:"floor_price"`
+	Decrement  Money    `json:"decrement"`
+	Interval   Duration `json:"interval"`
+}
+
 // AuctionItem represents an item up for auction
 type AuctionItem struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	MinimumBid  float64   `json:"minimum_bid"`
-	ExpiryTime  time.Time `json:"expiry_time"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Category and Tags classify the auction for browsing and search. They
+	// are stored in lower case, see NormalizeLabels.
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	// SellerID is the participant who created the auction
+	SellerID    string      `json:"seller_id,omitempty"`
+	AuctionType AuctionType `json:"auction_type,omitempty"`
+	// MinimumBid also sets the currency of the auction, which every other
+	// amount in the auction and its bids must use
+	MinimumBid Money `json:"minimum_bid"`
+	// ReservePrice is the hidden amount the winning bid must reach for the
+	// item to sell. Bids below it are still accepted.
+	ReservePrice Money `json:"reserve_price,omitzero"`
+	// BuyNowPrice lets the first buyer end the auction immediately, until
+	// a bid reaches it
+	BuyNowPrice Money `json:"buy_now_price,omitzero"`
+	// CurrentBid is the highest bid so far, kept on the auction so listings
+	// can filter and sort by it. Sealed auctions never set it.
+	CurrentBid Money     `json:"current_bid,omitzero"`
+	ExpiryTime time.Time `json:"expiry_time"`
+	CreatedAt  time.Time `json:"created_at"`
+	// A bid accepted within ExtensionWindow of the expiry time pushes the
+	// expiry out to ExtensionDuration after the bid, to prevent sniping
+	ExtensionWindow   Duration `json:"extension_window,omitempty"`
+	ExtensionDuration Duration `json:"extension_duration,omitempty"`
+	// IncrementRule sets how far a bid must beat the highest bid, and the
+	// step proxy bids use. When it is unset, any bid above the highest one
+	// by the smallest unit of the currency is accepted, and proxy bids step
+	// by one major unit.
+	IncrementRule *IncrementRule `json:"increment_rule,omitempty"`
+	// Dutch holds the price schedule of Dutch auctions
+	Dutch *DutchSchedule `json:"dutch,omitempty"`
+	// ClosedAt is set once the auction has been settled
+	ClosedAt *time.Time `json:"closed_at,omitempty"`
+}
+
+// AuctionUpdate is a seller's change to an auction that has no bids yet.
+// Fields left nil are not changed.
+type AuctionUpdate struct {
+	Description *string   `json:"description,omitempty"`
+	Category    *string   `json:"category,omitempty"`
+	Tags        *[]string `json:"tags,omitempty"`
+	// ExpiryTime may only move the expiry later
+	ExpiryTime *time.Time `json:"expiry_time,omitempty"`
 }
 
 // Bid represents a bid placed on an auction item
@@ -19,6 +85,206 @@ type Bid struct {
 	ID            string    `json:"id"`
 	ParticipantID string    `json:"participant_id"`
 	AuctionItemID string    `json:"auction_item_id"`
-	BidPrice      float64   `json:"bid_price"`
+	BidPrice      Money     `json:"bid_price"`
 	Timestamp     time.Time `json:"timestamp"`
+	// MaxBid is only set on incoming bids. A participant who sets it is
+	// outbid automatically up to this amount, which is never disclosed.
+	MaxBid Money `json:"max_bid,omitzero"`
+	// Automatic is set on bids placed by the store on behalf of a proxy bidder
+	Automatic bool `json:"automatic"`
+	// Sequence numbers the bids of an auction from 1 in the order they were
+	// stored, and every server reports the same number. Numbers only go up,
+	// but may skip.
+	Sequence uint64 `json:"sequence,omitempty"`
+}
+
+// ProxyBid is the hidden maximum a participant is willing to pay, which
+// the store bids up to automatically whenever they are outbid
+type ProxyBid struct {
+	ParticipantID string    `json:"participant_id"`
+	MaxBid        Money     `json:"max_bid"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Outcome is how an auction ended
+type Outcome string
+
+const (
+	// OutcomeSold means the item was sold to the winner
+	OutcomeSold Outcome = "sold"
+	// OutcomeNoSale means the auction closed without a sale
+	OutcomeNoSale Outcome = "no_sale"
+	// OutcomeCancelled means the seller withdrew the auction, the settlement
+	// reason is the one they gave
+	OutcomeCancelled Outcome = "cancelled"
+)
+
+// Reasons recorded for auctions that close without a sale
+const (
+	ReasonNoBids        = "no_bids"
+	ReasonReserveNotMet = "reserve_not_met"
+)
+
+// Settlement records the final outcome of a closed auction
+type Settlement struct {
+	AuctionItemID string    `json:"auction_item_id"`
+	Outcome       Outcome   `json:"outcome"`
+	Reason        string    `json:"reason,omitempty"`
+	WinnerID      string    `json:"winner_id,omitempty"`
+	WinningBidID  string    `json:"winning_bid_id,omitempty"`
+	ClearingPrice Money     `json:"clearing_price"`
+	ClosedAt      time.Time `json:"closed_at"`
+}
+
+// IsEmpty reports whether the update changes nothing
+func (u AuctionUpdate) IsEmpty() bool {
+	return u.Description == nil && u.Category == nil && u.Tags == nil && u.ExpiryTime == nil
+}
+
+// Apply returns the auction with the update applied
+func (u AuctionUpdate) Apply(item AuctionItem) AuctionItem {
+	if u.Description != nil {
+		item.Description = *u.Description
+	}
+	if u.Category != nil {
+		item.Category = *u.Category
+	}
+	if u.Tags != nil {
+		item.Tags = *u.Tags
+	}
+	if u.ExpiryTime != nil {
+		item.ExpiryTime = *u.ExpiryTime
+	}
+	return item
+}
+
+// Type returns the auction type, treating an unset type as english
+func (a AuctionItem) Type() AuctionType {
+	if a.AuctionType == "" {
+		return TypeEnglish
+	}
+	return a.AuctionType
+}
+
+// Public returns a copy of the auction that is safe to show to anyone,
+// with the reserve price removed
+func (a AuctionItem) Public() AuctionItem {
+	a.ReservePrice = Money{}
+	return a
+}
+
+// Currency returns the currency of the auction
+func (a AuctionItem) Currency() string {
+	return a.MinimumBid.Currency
+}
+
+// ReserveMet reports whether a bid of the given price meets the reserve
+func (a AuctionItem) ReserveMet(price Money) bool {
+	return !price.Less(a.ReservePrice)
+}
+
+// IsSealed reports whether bids are hidden until the auction closes
+func (a AuctionItem) IsSealed() bool {
+	return a.Type() == TypeSealedFirstPrice || a.Type() == TypeSealedSecondPrice
+}
+
+// CurrentPrice returns the asking price of a Dutch auction at the given
+// time: the start price, less one decrement for every full interval since
+// the auction was created, but never below the floor
+func (a AuctionItem) CurrentPrice(at time.Time) Money {
+	if a.Dutch == nil {
+		return Money{}
+	}
+
+	var steps int64
+	if a.Dutch.Interval > 0 && at.After(a.CreatedAt) {
+		steps = int64(at.Sub(a.CreatedAt) / time.Duration(a.Dutch.Interval))
+	}
+
+	price := a.Dutch.StartPrice
+	price.Amount -= steps * a.Dutch.Decrement.Amount
+	return price.Max(a.Dutch.FloorPrice)
+}
+
+// CheckCurrency checks that every amount in the auction is in the currency
+// of its minimum bid, and that the currency is supported
+func (a AuctionItem) CheckCurrency() error {
+	currency := a.Currency()
+	if !ValidCurrency(currency) {
+		return fmt.Errorf("unsupported currency %q", currency)
+	}
+
+	amounts := []Money{a.ReservePrice, a.BuyNowPrice}
+	if a.Dutch != nil {
+		amounts = append(amounts, a.Dutch.StartPrice, a.Dutch.FloorPrice, a.Dutch.Decrement)
+	}
+	if a.IncrementRule != nil {
+		amounts = append(amounts, a.IncrementRule.Amount)
+		for _, tier := range a.IncrementRule.Tiers {
+			amounts = append(amounts, tier.UpTo, tier.Increment)
+		}
+	}
+	for _, amount := range amounts {
+		if !amount.IsZero() && amount.Currency != currency {
+			return fmt.Errorf("amount in %s does not match the auction currency %s", amount.Currency, currency)
+		}
+	}
+	return nil
+}
+
+// CheckBidCurrency checks that a bid is in the currency of the auction
+func (a AuctionItem) CheckBidCurrency(bid Bid) error {
+	if bid.BidPrice.Currency != a.Currency() || (!bid.MaxBid.IsZero() && bid.MaxBid.Currency != a.Currency()) {
+		return fmt.Errorf("bid must be in the auction currency %s", a.Currency())
+	}
+	return nil
+}
+
+// ExtendForBid applies the anti-sniping rule for a bid accepted at the given
+// time, and reports whether the expiry time was moved
+func (a *AuctionItem) ExtendForBid(at time.Time) bool {
+	if a.ExtensionWindow <= 0 || a.ExtensionDuration <= 0 {
+		return false
+	}
+	if a.ExpiryTime.Sub(at) > time.Duration(a.ExtensionWindow) {
+		return false
+	}
+
+	extended := at.Add(time.Duration(a.ExtensionDuration))
+	if !extended.After(a.ExpiryTime) {
+		return false
+	}
+	a.ExpiryTime = extended
+	return true
+}
+
+// Duration is a time.Duration that is encoded in JSON as a string such as
+// "2m30s". Plain numbers are also accepted and read as seconds.
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes a duration string or a number of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return errors.New("invalid duration")
+	}
+	return nil
 }
@@ -0,0 +1,83 @@
+package auction
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestExtendForBid(t *testing.T) {
+	expiry := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	item := AuctionItem{
+		ExpiryTime:        expiry,
+		ExtensionWindow:   Duration(time.Minute),
+		ExtensionDuration: Duration(2 * time.Minute),
+	}
+
+	// Bids before the window leave the expiry alone
+	if item.ExtendForBid(expiry.Add(-5 * time.Minute)) {
+		t.Fatalf("Bid outside the window should not extend the auction")
+	}
+
+	// A bid 30 seconds before close pushes the expiry to 2 minutes after the bid
+	if !item.ExtendForBid(expiry.Add(-30 * time.Second)) {
+		t.Fatalf("Bid inside the window should extend the auction")
+	}
+	if want := expiry.Add(90 * time.Second); !item.ExpiryTime.Equal(want) {
+		t.Fatalf("Expected expiry %v, got %v", want, item.ExpiryTime)
+	}
+
+	// Auctions without a window are never extended
+	plain := AuctionItem{ExpiryTime: expiry}
+	if plain.ExtendForBid(expiry.Add(-time.Second)) {
+		t.Fatalf("Auction without anti-sniping should not be extended")
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	var item AuctionItem
+	if err := json.Unmarshal([]byte(`{"extension_window":"1m30s","extension_duration":45}`), &item); err != nil {
+		t.Fatalf("Failed to decode durations: %v", err)
+	}
+	if time.Duration(item.ExtensionWindow) != 90*time.Second {
+		t.Fatalf("Unexpected window %v", time.Duration(item.ExtensionWindow))
+	}
+	if time.Duration(item.ExtensionDuration) != 45*time.Second {
+		t.Fatalf("Unexpected duration %v", time.Duration(item.ExtensionDuration))
+	}
+
+	data, _ := json.Marshal(item.ExtensionWindow)
+	if string(data) != `"1m30s"` {
+		t.Fatalf("Unexpected encoding %s", data)
+	}
+}
+
+func TestDutchCurrentPrice(t *testing.T) {
+	created := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	item := AuctionItem{
+		AuctionType: TypeDutch,
+		CreatedAt:   created,
+		Dutch: &DutchSchedule{
+			StartPrice: usd(100),
+			FloorPrice: usd(40),
+			Decrement:  usd(15),
+			Interval:   Duration(time.Minute),
+		},
+	}
+
+	tests := []struct {
+		elapsed time.Duration
+		want    float64
+	}{
+		{0, 100},
+		{59 * time.Second, 100},
+		{time.Minute, 85},
+		{3*time.Minute + 30*time.Second, 55},
+		{time.Hour, 40},
+	}
+	for _, tt := range tests {
+		if got := item.CurrentPrice(created.Add(tt.elapsed)); got != usd(tt.want) {
+			t.Errorf("After %v expected price %v, got %v", tt.elapsed, usd(tt.want), got)
+		}
+	}
+}
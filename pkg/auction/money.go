@@ -0,0 +1,170 @@
+package auction
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DefaultCurrency is used when an amount does not name its currency, which
+// includes every plain-number amount from before currencies were supported
+const DefaultCurrency = "USD"
+
+// currencyExponents lists the supported ISO 4217 currencies and the number
+// of decimal digits in their minor unit
+var currencyExponents = map[string]int{
+	"AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2,
+	"DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2,
+	"INR": 2, "JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3, "MXN": 2, "NOK": 2,
+	"NZD": 2, "OMR": 3, "PLN": 2, "SEK": 2, "SGD": 2, "THB": 2, "TRY": 2,
+	"TWD": 2, "USD": 2, "ZAR": 2,
+}
+
+// ValidCurrency reports whether code is a supported ISO 4217 currency code
+func ValidCurrency(code string) bool {
+	_, ok := currencyExponents[code]
+	return ok
+}
+
+// exponent returns the number of decimal digits in the minor unit of
+// currency, assuming two for unknown currencies
+func exponent(currency string) int {
+	if exp, ok := currencyExponents[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+// minorUnits returns how many minor units make up one major unit of currency
+func minorUnits(currency string) int64 {
+	return int64(math.Pow10(exponent(currency)))
+}
+
+// Money is an exact amount of money, counted in the minor unit of its
+// currency (cents for USD). It is encoded as {"amount": 1250, "currency": "USD"};
+// a plain number such as 12.5 is also accepted and read as DefaultCurrency.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// NewMoney returns amount minor units of currency
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// FromMajor converts an amount in major units, such as dollars, to Money,
+// rounding to the nearest minor unit
+func FromMajor(value float64, currency string) Money {
+	return Money{
+		Amount:   int64(math.Round(value * float64(minorUnits(currency)))),
+		Currency: currency,
+	}
+}
+
+// OneUnit returns one major unit of currency, such as a dollar
+func OneUnit(currency string) Money {
+	return Money{Amount: minorUnits(currency), Currency: currency}
+}
+
+// SmallestUnit returns one minor unit of currency, such as a cent
+func SmallestUnit(currency string) Money {
+	return Money{Amount: 1, Currency: currency}
+}
+
+// IsZero reports whether the amount is zero, so zero amounts can be omitted
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Add returns m plus o. Both are expected to be in the same currency.
+func (m Money) Add(o Money) Money {
+	if m.Currency == "" {
+		m.Currency = o.Currency
+	}
+	m.Amount += o.Amount
+	return m
+}
+
+// Less reports whether m is smaller than o
+func (m Money) Less(o Money) bool {
+	return m.Amount < o.Amount
+}
+
+// Max returns the larger of m and o
+func (m Money) Max(o Money) Money {
+	if m.Less(o) {
+		return o
+	}
+	return m
+}
+
+// Min returns the smaller of m and o
+func (m Money) Min(o Money) Money {
+	if o.Less(m) {
+		return o
+	}
+	return m
+}
+
+// Major returns the amount in major units, for display only
+func (m Money) Major() float64 {
+	return float64(m.Amount) / float64(minorUnits(m.Currency))
+}
+
+// String formats the amount with its currency, such as "12.50 USD"
+func (m Money) String() string {
+	units := minorUnits(m.Currency)
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	s := fmt.Sprintf("%s%d", sign, amount/units)
+	if digits := exponent(m.Currency); digits > 0 {
+		s += fmt.Sprintf(".%0*d", digits, amount%units)
+	}
+	if m.Currency != "" {
+		s += " " + m.Currency
+	}
+	return s
+}
+
+// UnmarshalJSON accepts {"amount": 1250, "currency": "USD"}, or a plain
+// number in major units of DefaultCurrency as sent by older clients
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '{' {
+		var v struct {
+			Amount   *int64 `json:"amount"`
+			Currency string `json:"currency"`
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		if v.Amount == nil {
+			return errors.New("money needs an amount in minor units")
+		}
+		*m = Money{Amount: *v.Amount, Currency: strings.ToUpper(v.Currency)}
+		if m.Currency == "" {
+			m.Currency = DefaultCurrency
+		}
+		return nil
+	}
+
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return errors.New("money must be an object with amount and currency, or a number")
+	}
+	*m = FromMajor(value, DefaultCurrency)
+	return nil
+}
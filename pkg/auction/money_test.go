@@ -0,0 +1,70 @@
+package auction
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// usd returns an amount of US dollars given in major units
+func usd(dollars float64) Money {
+	return FromMajor(dollars, "USD")
+}
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Money
+	}{
+		{`{"amount": 1250, "currency": "EUR"}`, NewMoney(1250, "EUR")},
+		{`{"amount": 1250, "currency": "eur"}`, NewMoney(1250, "EUR")},
+		{`{"amount": 1250}`, NewMoney(1250, "USD")},
+		// Plain numbers from older clients are read as dollars
+		{`12.5`, NewMoney(1250, "USD")},
+		{`0.30000000000000004`, NewMoney(30, "USD")},
+	}
+	for _, tt := range tests {
+		var got Money
+		if err := json.Unmarshal([]byte(tt.input), &got); err != nil {
+			t.Errorf("Failed to decode %s: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Decoding %s: expected %v, got %v", tt.input, tt.want, got)
+		}
+	}
+
+	for _, input := range []string{`"ten"`, `{"currency": "USD"}`, `{"amount": 1.5}`} {
+		var m Money
+		if err := json.Unmarshal([]byte(input), &m); err == nil {
+			t.Errorf("Expected %s to be rejected", input)
+		}
+	}
+
+	data, _ := json.Marshal(NewMoney(1250, "USD"))
+	if string(data) != `{"amount":1250,"currency":"USD"}` {
+		t.Errorf("Unexpected encoding %s", data)
+	}
+}
+
+func TestMoneyIsExact(t *testing.T) {
+	// 0.1 + 0.2 is not 0.3 in floating point, but it is in cents
+	sum := usd(0.1).Add(usd(0.2))
+	if sum != usd(0.3) || sum.Less(usd(0.3)) || usd(0.3).Less(sum) {
+		t.Fatalf("Expected 0.10 + 0.20 to equal 0.30, got %v", sum)
+	}
+
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{NewMoney(1205, "USD"), "12.05 USD"},
+		{NewMoney(-5, "USD"), "-0.05 USD"},
+		{NewMoney(1500, "JPY"), "1500 JPY"},
+		{NewMoney(1234, "BHD"), "1.234 BHD"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("Expected %q, got %q", tt.want, got)
+		}
+	}
+}
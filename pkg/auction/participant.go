@@ -0,0 +1,19 @@
+package auction
+
+import "time"
+
+// Participant is a registered bidder or seller
+type Participant struct {
+	ID string `json:"id"`
+	// APIKeyHash is the hash of the secret part of the participant's API
+	// key. The key itself is only shown once, when it is created.
+	APIKeyHash string    `json:"api_key_hash,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Public returns a copy of the participant that is safe to show to anyone,
+// with the API key hash removed
+func (p Participant) Public() Participant {
+	p.APIKeyHash = ""
+	return p
+}
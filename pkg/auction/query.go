@@ -0,0 +1,427 @@
+package auction
+
+import (
+	"container/heap"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statuses of an auction, as reported by its status and used to filter listings
+const (
+	StatusActive  = "active"
+	StatusExpired = "expired"
+	StatusClosed  = "closed"
+)
+
+// SortOrder is the order auctions are listed in
+type SortOrder string
+
+const (
+	// SortNewest lists the most recently created auctions first, the default
+	SortNewest SortOrder = "newest"
+	// SortEndingSoonest lists the auctions closest to their expiry first
+	SortEndingSoonest SortOrder = "ending_soonest"
+	// SortHighestBid lists auctions by their ListPrice, highest first
+	SortHighestBid SortOrder = "highest_bid"
+)
+
+// Page sizes of auction listings
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 200
+)
+
+// ListQuery selects a page of auctions. Zero fields do not filter.
+type ListQuery struct {
+	// Status is one of StatusActive, StatusExpired or StatusClosed
+	Status   string
+	SellerID string
+	// Category and Tag are compared with the normalized labels of auctions
+	Category string
+	Tag      string
+	// MinPrice and MaxPrice bound the ListPrice. When either is set only
+	// auctions in its currency match.
+	MinPrice      Money
+	MaxPrice      Money
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	ExpiresAfter  time.Time
+	ExpiresBefore time.Time
+	Sort          SortOrder
+	// Limit is the page size, at most MaxListLimit. When it is zero a query
+	// without a cursor returns every match, and one with a cursor pages by
+	// DefaultListLimit.
+	Limit int
+	// After continues the listing from the end of an earlier page
+	After *Cursor
+}
+
+// ListPage is a page of auctions. NextCursor is empty on the last page.
+type ListPage struct {
+	Auctions   []AuctionItem `json:"auctions"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// Cursor marks the last auction of a page by its sort key and ID, so the
+// next page starts after it even if auctions are added in the meantime
+type Cursor struct {
+	Sort SortOrder `json:"s"`
+	Key  int64     `json:"k"`
+	ID   string    `json:"id"`
+}
+
+// String encodes the cursor as an opaque token
+func (c Cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes a token returned by Cursor.String
+func ParseCursor(token string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.ID == "" {
+		return Cursor{}, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// Status returns whether the auction is active, expired or closed at the given time
+func (a AuctionItem) Status(now time.Time) string {
+	switch {
+	case a.ClosedAt != nil:
+		return StatusClosed
+	case now.After(a.ExpiryTime):
+		return StatusExpired
+	default:
+		return StatusActive
+	}
+}
+
+// ListPrice is the price listings filter and sort by: the current bid, or
+// the minimum bid until there is one
+func (a AuctionItem) ListPrice() Money {
+	if a.CurrentBid.IsZero() {
+		return a.MinimumBid
+	}
+	return a.CurrentBid
+}
+
+// Matches reports whether the auction passes every filter of the query
+func (q ListQuery) Matches(item AuctionItem, now time.Time) bool {
+	if q.Status != "" && item.Status(now) != q.Status {
+		return false
+	}
+	if q.SellerID != "" && item.SellerID != q.SellerID {
+		return false
+	}
+	if q.Category != "" && item.Category != NormalizeLabel(q.Category) {
+		return false
+	}
+	if q.Tag != "" && !item.HasTag(NormalizeLabel(q.Tag)) {
+		return false
+	}
+
+	price := item.ListPrice()
+	if !q.MinPrice.IsZero() && (price.Currency != q.MinPrice.Currency || price.Less(q.MinPrice)) {
+		return false
+	}
+	if !q.MaxPrice.IsZero() && (price.Currency != q.MaxPrice.Currency || q.MaxPrice.Less(price)) {
+		return false
+	}
+
+	if !q.CreatedAfter.IsZero() && !item.CreatedAt.After(q.CreatedAfter) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && !item.CreatedAt.Before(q.CreatedBefore) {
+		return false
+	}
+	if !q.ExpiresAfter.IsZero() && !item.ExpiryTime.After(q.ExpiresAfter) {
+		return false
+	}
+	if !q.ExpiresBefore.IsZero() && !item.ExpiryTime.Before(q.ExpiresBefore) {
+		return false
+	}
+	return true
+}
+
+// Filter keeps the auctions that match the query, in their given order,
+// up to the page size if it has one. Sort and After are ignored.
+func (q ListQuery) Filter(items []AuctionItem, now time.Time) []AuctionItem {
+	size := q.PageSize()
+	matched := make([]AuctionItem, 0, len(items))
+	for _, item := range items {
+		if size > 0 && len(matched) == size {
+			break
+		}
+		if q.Matches(item, now) {
+			matched = append(matched, item)
+		}
+	}
+	return matched
+}
+
+// SortOrder returns the sort order of the query, SortNewest by default
+func (q ListQuery) SortOrder() SortOrder {
+	if q.Sort == "" {
+		return SortNewest
+	}
+	return q.Sort
+}
+
+// PageSize returns the number of auctions on a page of the query, or zero
+// when it returns every match
+func (q ListQuery) PageSize() int {
+	switch {
+	case q.Limit <= 0 && q.After == nil:
+		return 0
+	case q.Limit <= 0:
+		return DefaultListLimit
+	case q.Limit > MaxListLimit:
+		return MaxListLimit
+	default:
+		return q.Limit
+	}
+}
+
+// sortKey returns the value auctions are ordered by, and whether the
+// order is descending
+func (s SortOrder) sortKey(item AuctionItem) (int64, bool) {
+	switch s {
+	case SortEndingSoonest:
+		return item.ExpiryTime.UnixNano(), false
+	case SortHighestBid:
+		return item.ListPrice().Amount, true
+	default:
+		return item.CreatedAt.UnixNano(), true
+	}
+}
+
+// cursorBefore reports whether the auction at cursor position (key, id)
+// comes before item. Ties on the key are broken by ID.
+func (s SortOrder) cursorBefore(key int64, id string, item AuctionItem) bool {
+	itemKey, desc := s.sortKey(item)
+	if key != itemKey {
+		return (key < itemKey) != desc
+	}
+	return id < item.ID
+}
+
+// Page filters, sorts and pages auctions
+func (q ListQuery) Page(items []AuctionItem, now time.Time) ListPage {
+	pager := q.Pager(now)
+	for _, item := range items {
+		pager.Add(item)
+	}
+	return pager.Page()
+}
+
+// Pager collects a page of a query from auctions offered one at a time, so
+// stores can page their auctions without copying or sorting all of them.
+// Only the auctions that can still make the page are kept.
+type Pager struct {
+	query ListQuery
+	now   time.Time
+	order SortOrder
+	size  int
+	// kept is a heap of the matches so far, the one listed last at its root
+	kept []AuctionItem
+}
+
+// Pager returns an empty pager for the query
+func (q ListQuery) Pager(now time.Time) *Pager {
+	return &Pager{query: q, now: now, order: q.SortOrder(), size: q.PageSize()}
+}
+
+// Add offers an auction to the page
+func (p *Pager) Add(item AuctionItem) {
+	if after := p.query.After; after != nil && !p.order.cursorBefore(after.Key, after.ID, item) {
+		return
+	}
+	if !p.query.Matches(item, p.now) {
+		return
+	}
+	if p.size == 0 {
+		p.kept = append(p.kept, item)
+		return
+	}
+	// One auction past the page tells whether there is a next page
+	if len(p.kept) <= p.size {
+		heap.Push((*pagerHeap)(p), item)
+	} else if p.before(item, p.kept[0]) {
+		p.kept[0] = item
+		heap.Fix((*pagerHeap)(p), 0)
+	}
+}
+
+// Page returns the sorted page of the auctions added so far
+func (p *Pager) Page() ListPage {
+	matched := p.kept
+	sort.Slice(matched, func(i, j int) bool {
+		return p.before(matched[i], matched[j])
+	})
+
+	page := ListPage{Auctions: matched}
+	if p.size > 0 && len(matched) > p.size {
+		page.Auctions = matched[:p.size]
+		last := page.Auctions[p.size-1]
+		key, _ := p.order.sortKey(last)
+		page.NextCursor = Cursor{Sort: p.order, Key: key, ID: last.ID}.String()
+	}
+	if page.Auctions == nil {
+		page.Auctions = []AuctionItem{}
+	}
+	return page
+}
+
+// before reports whether a is listed before b
+func (p *Pager) before(a, b AuctionItem) bool {
+	key, _ := p.order.sortKey(a)
+	return p.order.cursorBefore(key, a.ID, b)
+}
+
+// pagerHeap orders the kept auctions of a Pager for container/heap
+type pagerHeap Pager
+
+func (h *pagerHeap) Len() int { return len(h.kept) }
+func (h *pagerHeap) Less(i, j int) bool {
+	return (*Pager)(h).before(h.kept[j], h.kept[i])
+}
+func (h *pagerHeap) Swap(i, j int) { h.kept[i], h.kept[j] = h.kept[j], h.kept[i] }
+func (h *pagerHeap) Push(x any)    { h.kept = append(h.kept, x.(AuctionItem)) }
+func (h *pagerHeap) Pop() any {
+	last := h.kept[len(h.kept)-1]
+	h.kept = h.kept[:len(h.kept)-1]
+	return last
+}
+
+// Next returns the query for the page after the given one, and false if
+// it was the last page
+func (q ListQuery) Next(page ListPage) (ListQuery, bool) {
+	cursor, err := ParseCursor(page.NextCursor)
+	if err != nil {
+		return q, false
+	}
+	q.After = &cursor
+	return q, true
+}
+
+// Values encodes the query as URL query parameters. Prices are given in
+// minor units, along with their currency.
+func (q ListQuery) Values() url.Values {
+	v := url.Values{}
+	set := func(name, value string) {
+		if value != "" {
+			v.Set(name, value)
+		}
+	}
+	setTime := func(name string, t time.Time) {
+		if !t.IsZero() {
+			v.Set(name, t.Format(time.RFC3339Nano))
+		}
+	}
+
+	set("status", q.Status)
+	set("seller", q.SellerID)
+	set("category", q.Category)
+	set("tag", q.Tag)
+	for name, price := range map[string]Money{"min_price": q.MinPrice, "max_price": q.MaxPrice} {
+		if !price.IsZero() {
+			v.Set(name, strconv.FormatInt(price.Amount, 10))
+			v.Set("currency", price.Currency)
+		}
+	}
+	setTime("created_after", q.CreatedAfter)
+	setTime("created_before", q.CreatedBefore)
+	setTime("expires_after", q.ExpiresAfter)
+	setTime("expires_before", q.ExpiresBefore)
+	set("sort", string(q.Sort))
+	if q.Limit != 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.After != nil {
+		v.Set("cursor", q.After.String())
+	}
+	return v
+}
+
+// ParseListQuery decodes a query from URL query parameters, as encoded by Values
+func ParseListQuery(v url.Values) (ListQuery, error) {
+	q := ListQuery{
+		Status:   v.Get("status"),
+		SellerID: v.Get("seller"),
+		Category: NormalizeLabel(v.Get("category")),
+		Tag:      NormalizeLabel(v.Get("tag")),
+		Sort:     SortOrder(v.Get("sort")),
+	}
+
+	switch q.Status {
+	case "", StatusActive, StatusExpired, StatusClosed:
+	default:
+		return ListQuery{}, fmt.Errorf("status must be %s, %s or %s", StatusActive, StatusExpired, StatusClosed)
+	}
+	switch q.Sort {
+	case "", SortNewest, SortEndingSoonest, SortHighestBid:
+	default:
+		return ListQuery{}, fmt.Errorf("sort must be %s, %s or %s", SortNewest, SortEndingSoonest, SortHighestBid)
+	}
+
+	currency := strings.ToUpper(v.Get("currency"))
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	if !ValidCurrency(currency) {
+		return ListQuery{}, fmt.Errorf("unsupported currency %q", currency)
+	}
+	for name, price := range map[string]*Money{"min_price": &q.MinPrice, "max_price": &q.MaxPrice} {
+		if s := v.Get(name); s != "" {
+			amount, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || amount <= 0 {
+				return ListQuery{}, fmt.Errorf("%s must be a positive amount in minor units", name)
+			}
+			*price = NewMoney(amount, currency)
+		}
+	}
+
+	for name, t := range map[string]*time.Time{
+		"created_after":  &q.CreatedAfter,
+		"created_before": &q.CreatedBefore,
+		"expires_after":  &q.ExpiresAfter,
+		"expires_before": &q.ExpiresBefore,
+	} {
+		if s := v.Get(name); s != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return ListQuery{}, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*t = parsed
+		}
+	}
+
+	if s := v.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > MaxListLimit {
+			return ListQuery{}, fmt.Errorf("limit must be between 1 and %d", MaxListLimit)
+		}
+		q.Limit = limit
+	}
+
+	if s := v.Get("cursor"); s != "" {
+		cursor, err := ParseCursor(s)
+		if err != nil {
+			return ListQuery{}, err
+		}
+		if cursor.Sort != q.SortOrder() {
+			return ListQuery{}, errors.New("cursor belongs to a listing with another sort order")
+		}
+		q.After = &cursor
+	}
+	return q, nil
+}
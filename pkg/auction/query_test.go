@@ -0,0 +1,154 @@
+package auction
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestListQueryPage(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	closedAt := now.Add(-time.Minute)
+	items := []AuctionItem{
+		{ID: "a", SellerID: "sam", MinimumBid: NewMoney(1000, "USD"), CreatedAt: now.Add(-3 * time.Hour), ExpiryTime: now.Add(time.Hour)},
+		{ID: "b", SellerID: "sam", MinimumBid: NewMoney(500, "USD"), CurrentBid: NewMoney(4000, "USD"), CreatedAt: now.Add(-2 * time.Hour), ExpiryTime: now.Add(30 * time.Minute)},
+		{ID: "c", SellerID: "kim", MinimumBid: NewMoney(2000, "USD"), CreatedAt: now.Add(-time.Hour), ExpiryTime: now.Add(-time.Minute)},
+		{ID: "d", SellerID: "kim", MinimumBid: NewMoney(3000, "EUR"), CreatedAt: now.Add(-4 * time.Hour), ExpiryTime: now.Add(-time.Hour), ClosedAt: &closedAt},
+	}
+
+	tests := []struct {
+		name  string
+		query ListQuery
+		want  string
+	}{
+		{"newest first by default", ListQuery{}, "cbad"},
+		{"active", ListQuery{Status: StatusActive}, "ba"},
+		{"expired", ListQuery{Status: StatusExpired}, "c"},
+		{"closed", ListQuery{Status: StatusClosed}, "d"},
+		{"seller", ListQuery{SellerID: "kim"}, "cd"},
+		{"ending soonest", ListQuery{Sort: SortEndingSoonest}, "dcba"},
+		{"highest bid uses the current bid", ListQuery{Sort: SortHighestBid, MinPrice: NewMoney(1, "USD")}, "bca"},
+		{"price range", ListQuery{MinPrice: NewMoney(1500, "USD"), MaxPrice: NewMoney(3000, "USD")}, "c"},
+		{"price in another currency", ListQuery{MinPrice: NewMoney(1, "EUR")}, "d"},
+		{"created window", ListQuery{CreatedAfter: now.Add(-150 * time.Minute), CreatedBefore: now}, "cb"},
+		{"expiry window", ListQuery{ExpiresAfter: now, ExpiresBefore: now.Add(45 * time.Minute)}, "b"},
+	}
+	for _, tt := range tests {
+		page := tt.query.Page(items, now)
+		if got := ids(page.Auctions); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+		if page.NextCursor != "" {
+			t.Errorf("%s: expected a single page, got cursor %q", tt.name, page.NextCursor)
+		}
+	}
+}
+
+func TestListQueryCursor(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var items []AuctionItem
+	for i := 0; i < 5; i++ {
+		// Two auctions share each price, so ties are broken by ID
+		items = append(items, AuctionItem{
+			ID:         fmt.Sprintf("%d", i),
+			MinimumBid: NewMoney(int64(100*(i/2+1)), "USD"),
+			ExpiryTime: now.Add(time.Hour),
+		})
+	}
+
+	query := ListQuery{Sort: SortHighestBid, Limit: 2}
+	var pages []string
+	for more := true; more; {
+		page := query.Page(items, now)
+		pages = append(pages, ids(page.Auctions))
+		query, more = query.Next(page)
+
+		// An auction created between pages does not shift the next page
+		items = append(items, AuctionItem{ID: fmt.Sprintf("new%d", len(pages)), MinimumBid: NewMoney(1000, "USD"), ExpiryTime: now.Add(time.Hour)})
+	}
+	if want := []string{"42", "30", "1"}; fmt.Sprint(pages) != fmt.Sprint(want) {
+		t.Errorf("Expected pages %v, got %v", want, pages)
+	}
+}
+
+func TestListQueryWithoutLimit(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var items []AuctionItem
+	for i := 0; i < DefaultListLimit+10; i++ {
+		items = append(items, AuctionItem{
+			ID:         fmt.Sprintf("%03d", i),
+			CreatedAt:  now.Add(time.Duration(i) * time.Second),
+			ExpiryTime: now.Add(time.Hour),
+		})
+	}
+
+	// Without a limit or cursor every auction is listed
+	page := ListQuery{}.Page(items, now)
+	if len(page.Auctions) != len(items) || page.NextCursor != "" {
+		t.Fatalf("Expected all %d auctions on one page, got %d and cursor %q", len(items), len(page.Auctions), page.NextCursor)
+	}
+	if page.Auctions[0].ID != "059" {
+		t.Errorf("Expected the newest auction first, got %s", page.Auctions[0].ID)
+	}
+
+	// A cursor alone pages by the default limit
+	cursor := Cursor{Sort: SortNewest, Key: items[59].CreatedAt.UnixNano(), ID: "059"}
+	page = ListQuery{After: &cursor}.Page(items, now)
+	if len(page.Auctions) != DefaultListLimit || page.Auctions[0].ID != "058" || page.NextCursor == "" {
+		t.Errorf("Expected a page of %d from 058 with a cursor, got %d from %s", DefaultListLimit, len(page.Auctions), page.Auctions[0].ID)
+	}
+}
+
+func TestParseListQuery(t *testing.T) {
+	cursor := Cursor{Sort: SortEndingSoonest, Key: 42, ID: "a"}
+	query := ListQuery{
+		Status:        StatusActive,
+		SellerID:      "sam",
+		MinPrice:      NewMoney(500, "EUR"),
+		MaxPrice:      NewMoney(2500, "EUR"),
+		CreatedAfter:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		ExpiresBefore: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		Sort:          SortEndingSoonest,
+		Limit:         10,
+		After:         &cursor,
+	}
+
+	parsed, err := ParseListQuery(query.Values())
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	if parsed.After == nil || *parsed.After != cursor {
+		t.Errorf("Expected cursor %+v, got %+v", cursor, parsed.After)
+	}
+	parsed.After = query.After
+	if fmt.Sprint(parsed) != fmt.Sprint(query) {
+		t.Errorf("Expected %+v, got %+v", query, parsed)
+	}
+
+	for _, raw := range []string{
+		"status=open",
+		"sort=cheapest",
+		"min_price=-5",
+		"max_price=1.50",
+		"currency=XYZ&min_price=100",
+		"limit=0",
+		"limit=500",
+		"created_after=yesterday",
+		"cursor=garbage",
+		"cursor=" + cursor.String(),
+	} {
+		values, _ := url.ParseQuery(raw)
+		if _, err := ParseListQuery(values); err == nil {
+			t.Errorf("Expected %q to be rejected", raw)
+		}
+	}
+}
+
+func ids(items []AuctionItem) string {
+	var s string
+	for _, item := range items {
+		s += item.ID
+	}
+	return s
+}
@@ -0,0 +1,87 @@
+package auction
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Limits on the labels of an auction
+const (
+	MaxTags        = 10
+	MaxLabelLength = 32
+)
+
+// NormalizeLabel trims a category or tag and lowers its case
+func NormalizeLabel(label string) string {
+	return strings.ToLower(strings.TrimSpace(label))
+}
+
+// NormalizeLabels normalizes the category and tags of the auction, dropping
+// empty and repeated tags, and checks them against the limits
+func (a *AuctionItem) NormalizeLabels() error {
+	category, tags, err := normalizeLabels(a.Category, a.Tags)
+	if err != nil {
+		return err
+	}
+	a.Category, a.Tags = category, tags
+	return nil
+}
+
+// NormalizeLabels normalizes the category and tags the update sets, if any
+func (u *AuctionUpdate) NormalizeLabels() error {
+	var category string
+	var tags []string
+	if u.Category != nil {
+		category = *u.Category
+	}
+	if u.Tags != nil {
+		tags = *u.Tags
+	}
+
+	category, tags, err := normalizeLabels(category, tags)
+	if err != nil {
+		return err
+	}
+	if u.Category != nil {
+		u.Category = &category
+	}
+	if u.Tags != nil {
+		u.Tags = &tags
+	}
+	return nil
+}
+
+func normalizeLabels(category string, tags []string) (string, []string, error) {
+	category = NormalizeLabel(category)
+	if len(category) > MaxLabelLength {
+		return "", nil, fmt.Errorf("category is longer than %d characters", MaxLabelLength)
+	}
+
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = NormalizeLabel(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > MaxLabelLength {
+			return "", nil, fmt.Errorf("tag %q is longer than %d characters", tag, MaxLabelLength)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxTags {
+		return "", nil, fmt.Errorf("an auction can have at most %d tags", MaxTags)
+	}
+	return category, normalized, nil
+}
+
+// HasTag reports whether the auction is tagged with the given tag
+func (a AuctionItem) HasTag(tag string) bool {
+	for _, t := range a.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
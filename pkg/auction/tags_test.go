@@ -0,0 +1,42 @@
+package auction
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeLabels(t *testing.T) {
+	item := AuctionItem{Category: " Furniture ", Tags: []string{"Oak", "", "oak ", "Mid-Century"}}
+	if err := item.NormalizeLabels(); err != nil {
+		t.Fatalf("Failed to normalize labels: %v", err)
+	}
+	if item.Category != "furniture" || fmt.Sprint(item.Tags) != "[oak mid-century]" {
+		t.Errorf("Expected lower case labels without repeats, got %q %q", item.Category, item.Tags)
+	}
+	if !item.HasTag("oak") || item.HasTag("Oak") {
+		t.Errorf("Expected HasTag to compare normalized tags")
+	}
+
+	tooMany := AuctionItem{}
+	for i := 0; i <= MaxTags; i++ {
+		tooMany.Tags = append(tooMany.Tags, fmt.Sprintf("tag%d", i))
+	}
+	if err := tooMany.NormalizeLabels(); err == nil {
+		t.Errorf("Expected more than %d tags to be rejected", MaxTags)
+	}
+	long := AuctionItem{Category: strings.Repeat("x", MaxLabelLength+1)}
+	if err := long.NormalizeLabels(); err == nil {
+		t.Errorf("Expected a long category to be rejected")
+	}
+
+	// Updates only normalize the labels they set
+	tags := []string{"Brass", "brass"}
+	update := AuctionUpdate{Tags: &tags}
+	if err := update.NormalizeLabels(); err != nil {
+		t.Fatalf("Failed to normalize update: %v", err)
+	}
+	if update.Category != nil || fmt.Sprint(*update.Tags) != "[brass]" {
+		t.Errorf("Expected only the tags to be set, got %v %v", update.Category, *update.Tags)
+	}
+}
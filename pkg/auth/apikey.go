@@ -0,0 +1,69 @@
+// Package auth identifies participants by API key or signed bearer token.
+// API keys are checked against a hash kept with the participant, while
+// tokens are JSON Web Tokens signed with HMAC-SHA256 or Ed25519 that any
+// server holding the key can verify on its own.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// secretBytes is the length of the random part of an API key
+const secretBytes = 32
+
+// ErrInvalidAPIKey is returned for a key that is not of the form
+// "<participant ID>.<secret>"
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// participantIDPattern keeps IDs free of the dot that separates an API key
+var participantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidParticipantID reports whether id can be registered. IDs are 1 to 64
+// letters, digits, underscores or hyphens.
+func ValidParticipantID(id string) bool {
+	return participantIDPattern.MatchString(id)
+}
+
+// NewAPIKey creates an API key for a participant. The key is handed to the
+// participant and only its hash is stored.
+func NewAPIKey(participantID string) (key, hash string, err error) {
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	return participantID + "." + encoded, HashSecret(encoded), nil
+}
+
+// ParseAPIKey splits an API key into the participant it belongs to and its secret
+func ParseAPIKey(key string) (participantID, secret string, err error) {
+	participantID, secret, ok := strings.Cut(key, ".")
+	if !ok || !ValidParticipantID(participantID) || secret == "" || strings.Contains(secret, ".") {
+		return "", "", ErrInvalidAPIKey
+	}
+	return participantID, secret, nil
+}
+
+// HashSecret returns the hash stored for an API key secret
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckSecret reports whether secret matches a stored hash
+func CheckSecret(secret, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(hash)) == 1
+}
+
+// IsAPIKey reports whether a bearer credential looks like an API key rather
+// than a token. Tokens have three dot separated parts, keys have two.
+func IsAPIKey(credential string) bool {
+	return strings.Count(credential, ".") == 1
+}
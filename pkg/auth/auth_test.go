@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIKey(t *testing.T) {
+	key, hash, err := NewAPIKey("alice")
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	if !IsAPIKey(key) {
+		t.Errorf("Expected %q to look like an API key", key)
+	}
+
+	id, secret, err := ParseAPIKey(key)
+	if err != nil || id != "alice" {
+		t.Fatalf("Expected the key to belong to alice, got %q, %v", id, err)
+	}
+	if !CheckSecret(secret, hash) {
+		t.Errorf("Expected the secret to match its hash")
+	}
+	if CheckSecret(secret+"x", hash) {
+		t.Errorf("Expected a different secret not to match")
+	}
+
+	for _, bad := range []string{"", "alice", "alice.", ".secret", "a.b.c", "bad id.secret"} {
+		if _, _, err := ParseAPIKey(bad); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("Expected %q to be rejected, got %v", bad, err)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	now := time.Now()
+	claims := NewClaims("alice", now, time.Hour)
+
+	for name, keys := range map[string]Keys{
+		"HS256": {HMACSecret: []byte("secret")},
+		"EdDSA": {Ed25519Public: public, Ed25519Private: private},
+	} {
+		t.Run(name, func(t *testing.T) {
+			token, err := keys.Sign(claims)
+			if err != nil {
+				t.Fatalf("Failed to sign: %v", err)
+			}
+			if IsAPIKey(token) {
+				t.Errorf("Expected %q not to look like an API key", token)
+			}
+
+			got, err := keys.Verify(token, now)
+			if err != nil || got.Subject != "alice" {
+				t.Fatalf("Expected a valid token for alice, got %+v, %v", got, err)
+			}
+
+			if _, err := keys.Verify(token, now.Add(2*time.Hour)); !errors.Is(err, ErrTokenExpired) {
+				t.Errorf("Expected the token to expire, got %v", err)
+			}
+
+			// Changing the claims breaks the signature
+			parts := strings.Split(token, ".")
+			forged, _ := Keys{HMACSecret: []byte("secret")}.Sign(NewClaims("mallory", now, time.Hour))
+			parts[1] = strings.Split(forged, ".")[1]
+			if _, err := keys.Verify(strings.Join(parts, "."), now); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected a tampered token to be rejected, got %v", err)
+			}
+		})
+	}
+
+	// A token is only accepted with the algorithm of a configured key
+	hmacToken, _ := Keys{HMACSecret: []byte("secret")}.Sign(claims)
+	if _, err := (Keys{Ed25519Public: public}).Verify(hmacToken, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an HS256 token to be rejected without an HMAC secret, got %v", err)
+	}
+	unsigned := "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0." + strings.Split(hmacToken, ".")[1] + "."
+	if _, err := (Keys{HMACSecret: []byte("secret")}).Verify(unsigned, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an unsigned token to be rejected, got %v", err)
+	}
+
+	if _, err := (Keys{Ed25519Public: public}).Sign(claims); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("Expected signing without a private key to fail, got %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+package auth
+
+import "context"
+
+type participantKey struct{}
+
+// WithParticipant returns a context carrying the authenticated participant ID
+func WithParticipant(ctx context.Context, participantID string) context.Context {
+	return context.WithValue(ctx, participantKey{}, participantID)
+}
+
+// ParticipantFrom returns the authenticated participant ID of a context
+func ParticipantFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(participantKey{}).(string)
+	return id, ok && id != ""
+}
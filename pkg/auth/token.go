@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Signing algorithms, as named in the token header
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// leeway allows for clock differences between the issuer and the verifier
+const leeway = 30 * time.Second
+
+var (
+	// ErrInvalidToken is returned for a token that is malformed, signed with
+	// an unknown algorithm or key, or missing its subject or expiry
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is returned for a token past its expiry time
+	ErrTokenExpired = errors.New("token has expired")
+	// ErrNoSigningKey is returned when tokens are signed without a private key
+	ErrNoSigningKey = errors.New("no key to sign tokens with")
+)
+
+// Claims are the fields of a token. The subject is the participant ID.
+type Claims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// NewClaims returns claims for a participant that expire after ttl
+func NewClaims(participantID string, now time.Time, ttl time.Duration) Claims {
+	return Claims{
+		Subject:   participantID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+}
+
+// Keys are the keys tokens are signed and verified with. Tokens signed with
+// either an HMAC secret or an Ed25519 key are accepted, if that key is set.
+type Keys struct {
+	// HMACSecret signs and verifies HS256 tokens
+	HMACSecret []byte
+	// Ed25519Public verifies EdDSA tokens
+	Ed25519Public ed25519.PublicKey
+	// Ed25519Private signs EdDSA tokens. It can be left unset on servers
+	// that only verify tokens issued elsewhere.
+	Ed25519Private ed25519.PrivateKey
+}
+
+// CanVerify reports whether any tokens can be verified
+func (k Keys) CanVerify() bool {
+	return len(k.HMACSecret) > 0 || len(k.Ed25519Public) > 0
+}
+
+// CanSign reports whether Sign can issue tokens
+func (k Keys) CanSign() bool {
+	return len(k.HMACSecret) > 0 || len(k.Ed25519Private) > 0
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// Sign issues a token for claims, with the HMAC secret if there is one and
+// the Ed25519 private key otherwise
+func (k Keys) Sign(claims Claims) (string, error) {
+	alg := AlgHS256
+	if len(k.HMACSecret) == 0 {
+		if len(k.Ed25519Private) == 0 {
+			return "", ErrNoSigningKey
+		}
+		alg = AlgEdDSA
+	}
+
+	headerJSON, err := json.Marshal(header{Alg: alg, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := encodeSegment(headerJSON) + "." + encodeSegment(claimsJSON)
+
+	var signature []byte
+	if alg == AlgHS256 {
+		signature = hmacSHA256(k.HMACSecret, signingInput)
+	} else {
+		signature = ed25519.Sign(k.Ed25519Private, []byte(signingInput))
+	}
+	return signingInput + "." + encodeSegment(signature), nil
+}
+
+// Verify checks the signature and times of a token and returns its claims
+func (k Keys) Verify(token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var h header
+	if err := decodeJSONSegment(parts[0], &h); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	// The algorithm must match a configured key, so a token cannot pick
+	// a weaker check than the server expects
+	signingInput := parts[0] + "." + parts[1]
+	switch {
+	case h.Alg == AlgHS256 && len(k.HMACSecret) > 0:
+		if !hmac.Equal(signature, hmacSHA256(k.HMACSecret, signingInput)) {
+			return Claims{}, ErrInvalidToken
+		}
+	case h.Alg == AlgEdDSA && len(k.Ed25519Public) > 0:
+		if !ed25519.Verify(k.Ed25519Public, []byte(signingInput), signature) {
+			return Claims{}, ErrInvalidToken
+		}
+	default:
+		return Claims{}, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := decodeJSONSegment(parts[1], &claims); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	if !ValidParticipantID(claims.Subject) || claims.ExpiresAt == 0 {
+		return Claims{}, ErrInvalidToken
+	}
+	if now.Add(-leeway).Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(leeway).Unix() < claims.NotBefore {
+		return Claims{}, fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+func hmacSHA256(secret []byte, input string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+	return mac.Sum(nil)
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeJSONSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
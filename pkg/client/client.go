@@ -0,0 +1,453 @@
+// Package client is a Go client for the auction HTTP API
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/google/uuid"
+)
+
+// DefaultTimeout bounds each request when no HTTP client is given
+const DefaultTimeout = 10 * time.Second
+
+// Client calls the auction API of a cluster of servers. Requests go to the
+// last server that answered, and move on to the next one when it cannot be
+// reached. Reads and writes with an idempotency key also move on when a
+// server fails with a 5xx response.
+type Client struct {
+	// mu guards urls and preferred, which change when a discovered client
+	// refreshes its servers
+	mu        sync.Mutex
+	urls      []string
+	preferred int
+	http      *http.Client
+	// credential is sent as a bearer credential, an API key or a token
+	credential string
+
+	reconnectDelay time.Duration
+
+	// seed is set for clients created by Discover, which refresh their
+	// servers from the cluster every refreshInterval
+	seed            string
+	refreshInterval time.Duration
+	refreshedAt     time.Time
+	refreshing      atomic.Bool
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithTimeout sets the timeout of each request to a single server
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.http = &http.Client{Timeout: timeout}
+	}
+}
+
+// WithAPIKey authenticates requests with a participant's API key
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.credential = key
+	}
+}
+
+// WithToken authenticates requests with a token, as returned by Token
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.credential = token
+	}
+}
+
+// New creates a client for the servers at the given base URLs
+func New(urls []string, opts ...Option) *Client {
+	c := &Client{
+		http:            &http.Client{Timeout: DefaultTimeout},
+		reconnectDelay:  reconnectDelay,
+		refreshInterval: DefaultRefreshInterval,
+	}
+	for _, u := range urls {
+		c.urls = append(c.urls, strings.TrimRight(u, "/"))
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// idempotencyKeyHeader makes a write safe to retry on any server
+const idempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyKeyContext struct{}
+
+// WithIdempotencyKey returns a context that sends key as the idempotency key
+// of CreateAuction and PlaceBid. Each call otherwise uses a new key, which
+// only covers the servers it tries. Reusing a key when retrying a call that
+// failed or timed out returns the outcome of the first call, if it was
+// applied, instead of writing again. Keys are scoped to the participant.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContext{}, key)
+}
+
+// AuctionStatus is the current state of an auction
+type AuctionStatus struct {
+	Auction        auction.AuctionItem `json:"auction"`
+	HighestBid     *auction.Bid        `json:"highest_bid,omitempty"`
+	BidCount       *int                `json:"bid_count,omitempty"`
+	CurrentPrice   auction.Money       `json:"current_price,omitzero"`
+	NextMinimumBid auction.Money       `json:"next_minimum_bid,omitzero"`
+	Reserve        string              `json:"reserve,omitempty"`
+	BuyNowPrice    auction.Money       `json:"buy_now_price,omitzero"`
+	Status         string              `json:"status"`
+	TimeRemaining  string              `json:"time_remaining,omitempty"`
+}
+
+// Registration is a newly registered participant and its API key
+type Registration struct {
+	Participant auction.Participant `json:"participant"`
+	// APIKey is only returned once, when the participant registers
+	APIKey string `json:"api_key"`
+}
+
+// Token is a signed token that authenticates a participant until it expires
+type Token struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Register registers a participant. No credential is needed.
+func (c *Client) Register(ctx context.Context, participantID string) (Registration, error) {
+	var registration Registration
+	body := map[string]string{"id": participantID}
+	err := c.do(ctx, http.MethodPost, "/participants", body, &registration)
+	return registration, err
+}
+
+// Me returns the participant the client is authenticated as
+func (c *Client) Me(ctx context.Context) (auction.Participant, error) {
+	var participant auction.Participant
+	err := c.do(ctx, http.MethodGet, "/participants/me", nil, &participant)
+	return participant, err
+}
+
+// Token exchanges the client's credential for a token, if the server issues them
+func (c *Client) Token(ctx context.Context) (Token, error) {
+	var token Token
+	err := c.do(ctx, http.MethodPost, "/auth/token", nil, &token)
+	return token, err
+}
+
+// CreateAuction creates an auction and returns it as stored by the server.
+// The authenticated participant becomes its seller. The request carries an
+// idempotency key, see WithIdempotencyKey, so it creates a single auction
+// even if it reaches more than one server.
+func (c *Client) CreateAuction(ctx context.Context, item auction.AuctionItem) (auction.AuctionItem, error) {
+	var created auction.AuctionItem
+	err := c.doIdempotent(ctx, http.MethodPost, "/auctions", item, &created)
+	return created, err
+}
+
+// ListAuctions returns a page of the auctions matching the query. The
+// query for the next page is query.Next(page).
+func (c *Client) ListAuctions(ctx context.Context, query auction.ListQuery) (auction.ListPage, error) {
+	path := "/auctions"
+	if values := query.Values(); len(values) > 0 {
+		path += "?" + values.Encode()
+	}
+
+	resp, err := c.send(ctx, c.http, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return auction.ListPage{}, err
+	}
+	defer resp.Body.Close()
+
+	page := auction.ListPage{NextCursor: resp.Header.Get("X-Next-Cursor")}
+	if err := json.NewDecoder(resp.Body).Decode(&page.Auctions); err != nil {
+		return auction.ListPage{}, err
+	}
+	return page, nil
+}
+
+// Search returns the auctions whose name, description, category or tags
+// contain every term of the text, best match first. The filters of the
+// query narrow the results, while its sort order and cursor cannot be used.
+func (c *Client) Search(ctx context.Context, text string, query auction.ListQuery) ([]auction.AuctionItem, error) {
+	values := query.Values()
+	values.Set("q", text)
+
+	var auctions []auction.AuctionItem
+	err := c.do(ctx, http.MethodGet, "/search?"+values.Encode(), nil, &auctions)
+	return auctions, err
+}
+
+// GetAuction returns a single auction
+func (c *Client) GetAuction(ctx context.Context, id string) (auction.AuctionItem, error) {
+	var item auction.AuctionItem
+	err := c.do(ctx, http.MethodGet, auctionPath(id), nil, &item)
+	return item, err
+}
+
+// UpdateAuction edits an auction of the authenticated participant before
+// its first bid, and returns the updated auction
+func (c *Client) UpdateAuction(ctx context.Context, id string, update auction.AuctionUpdate) (auction.AuctionItem, error) {
+	var item auction.AuctionItem
+	err := c.do(ctx, http.MethodPatch, auctionPath(id), update, &item)
+	return item, err
+}
+
+// CancelAuction withdraws an auction of the authenticated participant, giving
+// bidders the reason
+func (c *Client) CancelAuction(ctx context.Context, id, reason string) (auction.Settlement, error) {
+	var settlement auction.Settlement
+	err := c.do(ctx, http.MethodPost, auctionPath(id, "cancel"), map[string]string{"reason": reason}, &settlement)
+	return settlement, err
+}
+
+// Status returns the current status of an auction
+func (c *Client) Status(ctx context.Context, id string) (AuctionStatus, error) {
+	var status AuctionStatus
+	err := c.do(ctx, http.MethodGet, auctionPath(id, "status"), nil, &status)
+	return status, err
+}
+
+// PlaceBid places a bid on the auction named by bid.AuctionItemID for the
+// authenticated participant, so bid.ParticipantID can be left empty, and
+// returns the bid as stored, with its ID and sequence number. A bid below
+// the next minimum bid fails with a *BidTooLowError. The request carries an
+// idempotency key, see WithIdempotencyKey, so the bid is placed once even
+// if it reaches more than one server.
+func (c *Client) PlaceBid(ctx context.Context, bid auction.Bid) (auction.Bid, error) {
+	var placed auction.Bid
+	err := c.doIdempotent(ctx, http.MethodPost, auctionPath(bid.AuctionItemID, "bids"), bid, &placed)
+	return placed, err
+}
+
+// GetBid returns a single bid of an auction. Until a sealed auction closes,
+// only its bidder can get a bid.
+func (c *Client) GetBid(ctx context.Context, auctionID, bidID string) (auction.Bid, error) {
+	var bid auction.Bid
+	err := c.do(ctx, http.MethodGet, auctionPath(auctionID, "bids", url.PathEscape(bidID)), nil, &bid)
+	return bid, err
+}
+
+// BidHistory returns the bids placed on an auction
+func (c *Client) BidHistory(ctx context.Context, id string) ([]auction.Bid, error) {
+	var bids []auction.Bid
+	err := c.do(ctx, http.MethodGet, auctionPath(id, "history"), nil, &bids)
+	return bids, err
+}
+
+// AcceptPrice accepts the current price of a Dutch auction. An empty
+// participantID stands for the authenticated participant.
+func (c *Client) AcceptPrice(ctx context.Context, id, participantID string) (auction.Settlement, error) {
+	return c.settle(ctx, id, "accept", participantID)
+}
+
+// BuyNow buys an auction at its buy-now price. An empty participantID
+// stands for the authenticated participant.
+func (c *Client) BuyNow(ctx context.Context, id, participantID string) (auction.Settlement, error) {
+	return c.settle(ctx, id, "buy", participantID)
+}
+
+// Result returns the settlement of a closed auction
+func (c *Client) Result(ctx context.Context, id string) (auction.Settlement, error) {
+	var settlement auction.Settlement
+	err := c.do(ctx, http.MethodGet, auctionPath(id, "result"), nil, &settlement)
+	return settlement, err
+}
+
+func (c *Client) settle(ctx context.Context, id, action, participantID string) (auction.Settlement, error) {
+	var settlement auction.Settlement
+	var body interface{}
+	if participantID != "" {
+		body = map[string]string{"participant_id": participantID}
+	}
+	err := c.do(ctx, http.MethodPost, auctionPath(id, action), body, &settlement)
+	return settlement, err
+}
+
+func auctionPath(id string, parts ...string) string {
+	return "/auctions/" + strings.Join(append([]string{url.PathEscape(id)}, parts...), "/")
+}
+
+// do sends a request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	return c.doHeader(ctx, method, path, nil, body, out)
+}
+
+// doIdempotent sends a write with the idempotency key from ctx, or a new
+// one, which every server it is sent to sees
+func (c *Client) doIdempotent(ctx context.Context, method, path string, body, out interface{}) error {
+	key, ok := ctx.Value(idempotencyKeyContext{}).(string)
+	if !ok {
+		key = uuid.New().String()
+	}
+	header := http.Header{idempotencyKeyHeader: {key}}
+	return c.doHeader(ctx, method, path, header, body, out)
+}
+
+// doHeader sends a request with extra headers and decodes the JSON response
+// into out
+func (c *Client) doHeader(ctx context.Context, method, path string, header http.Header, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.send(ctx, c.http, method, path, payload, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send sends a request and returns the first successful response, whose
+// body the caller must close. Servers are tried in turn, starting from the
+// preferred one: a server that cannot be connected to moves on to the next,
+// while other errors are returned straight away. A server that fails after
+// receiving the request may have applied it, so only reads and writes that
+// carry an idempotency key are sent to the next server then. When every
+// server fails, a discovered client asks the cluster for its live servers
+// and tries those it did not know.
+func (c *Client) send(ctx context.Context, hc *http.Client, method, path string, payload []byte, header http.Header) (*http.Response, error) {
+	c.refreshIfDue()
+	urls, start := c.servers()
+	if len(urls) == 0 {
+		return nil, ErrNoServers
+	}
+
+	resp, failures, err := c.sendToAll(ctx, hc, urls, start, method, path, payload, header)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	if c.seed != "" {
+		if added := c.refresh(ctx); len(added) > 0 {
+			var more []error
+			resp, more, err = c.sendToAll(ctx, hc, added, 0, method, path, payload, header)
+			if resp != nil || err != nil {
+				return resp, err
+			}
+			failures = append(failures, more...)
+		}
+	}
+	return nil, &UnavailableError{Failures: failures}
+}
+
+// sendToAll tries the servers in turn from start, as described for send.
+// It returns the first response, or an error that ends the request, or
+// otherwise the failure of every server.
+func (c *Client) sendToAll(ctx context.Context, hc *http.Client, urls []string, start int, method, path string, payload []byte, header http.Header) (*http.Response, []error, error) {
+	retryable := method == http.MethodGet || header.Get(idempotencyKeyHeader) != ""
+
+	var failures []error
+	for i := range urls {
+		server := urls[(start+i)%len(urls)]
+
+		resp, err := c.sendOnce(ctx, hc, server, method, path, payload, header)
+		if err == nil {
+			c.prefer(server)
+			return resp, nil, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, nil, ctxErr
+		}
+
+		// Any other server would reject the request the same way
+		if code := StatusCode(err); code != 0 && code < 500 {
+			return nil, nil, err
+		}
+		if !retryable && !notSent(err) {
+			return nil, nil, err
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", server, err))
+	}
+	return nil, failures, nil
+}
+
+// servers returns the servers of the client and the index of the preferred one
+func (c *Client) servers() ([]string, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.urls, c.preferred
+}
+
+// prefer makes a server that answered the first one tried next time
+func (c *Client) prefer(server string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, u := range c.urls {
+		if u == server {
+			c.preferred = i
+			return
+		}
+	}
+}
+
+// notSent reports whether a request failed before reaching the server,
+// because no connection could be made
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func (c *Client) sendOnce(ctx context.Context, hc *http.Client, server, method, path string, payload []byte, header http.Header) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, server+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.credential != "" {
+		req.Header.Set("Authorization", "Bearer "+c.credential)
+	}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, parseError(resp.StatusCode, data)
+	}
+	return resp, nil
+}
@@ -0,0 +1,624 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/api"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auth"
+)
+
+// usd returns an amount of US dollars given in major units
+func usd(dollars float64) auction.Money {
+	return auction.FromMajor(dollars, "USD")
+}
+
+// newTestServer starts an API server backed by memory storage
+func newTestServer(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(api.NewServer().Router)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// register registers a participant and returns a client authenticated as them
+func register(t *testing.T, url, participantID string) *Client {
+	t.Helper()
+	registration, err := New([]string{url}).Register(context.Background(), participantID)
+	if err != nil {
+		t.Fatalf("Failed to register %s: %v", participantID, err)
+	}
+	return New([]string{url}, WithAPIKey(registration.APIKey))
+}
+
+func TestClientEndpoints(t *testing.T) {
+	ts := newTestServer(t)
+	c := register(t, ts.URL, "sam")
+	alice := register(t, ts.URL, "alice")
+	bob := register(t, ts.URL, "bob")
+	ctx := context.Background()
+
+	item, err := c.CreateAuction(ctx, auction.AuctionItem{
+		Name:        "Lamp",
+		MinimumBid:  usd(10),
+		BuyNowPrice: usd(50),
+		ExpiryTime:  time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+
+	if item.SellerID != "sam" {
+		t.Errorf("Expected sam to be the seller, got %q", item.SellerID)
+	}
+
+	page, err := c.ListAuctions(ctx, auction.ListQuery{})
+	if err != nil || len(page.Auctions) != 1 || page.Auctions[0].ID != item.ID {
+		t.Fatalf("Expected the created auction to be listed, got %v, %v", page, err)
+	}
+	if got, err := c.GetAuction(ctx, item.ID); err != nil || got.Name != "Lamp" {
+		t.Fatalf("Expected to get the created auction, got %v, %v", got, err)
+	}
+
+	if _, err := alice.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(20)}); err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+
+	status, err := c.Status(ctx, item.ID)
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if status.Status != "active" || status.HighestBid == nil || status.HighestBid.BidPrice != usd(20) {
+		t.Errorf("Expected an active auction led by the 20.00 bid, got %+v", status)
+	}
+	if status.NextMinimumBid != usd(20.01) {
+		t.Errorf("Expected next minimum bid of 20.01, got %s", status.NextMinimumBid)
+	}
+
+	bids, err := c.BidHistory(ctx, item.ID)
+	if err != nil || len(bids) != 1 {
+		t.Fatalf("Expected one bid in the history, got %v, %v", bids, err)
+	}
+
+	settlement, err := bob.BuyNow(ctx, item.ID, "")
+	if err != nil {
+		t.Fatalf("Failed to buy now: %v", err)
+	}
+	if settlement.WinnerID != "bob" || settlement.ClearingPrice != usd(50) {
+		t.Errorf("Expected bob to buy at 50.00, got %+v", settlement)
+	}
+	if result, err := c.Result(ctx, item.ID); err != nil || result.WinnerID != "bob" {
+		t.Errorf("Expected the result to match the settlement, got %+v, %v", result, err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ts := newTestServer(t)
+	c := New([]string{ts.URL})
+	sam := register(t, ts.URL, "sam")
+	alice := register(t, ts.URL, "alice")
+	bob := register(t, ts.URL, "bob")
+	ctx := context.Background()
+
+	_, err := c.GetAuction(ctx, "missing")
+	if !IsNotFound(err) || ErrorCode(err) != "auction_not_found" {
+		t.Errorf("Expected an auction_not_found error, got %v", err)
+	}
+
+	// Bidding on a missing auction is a 404, not a bad request
+	_, err = alice.PlaceBid(ctx, auction.Bid{AuctionItemID: "missing", BidPrice: usd(20)})
+	if !IsNotFound(err) || ErrorCode(err) != "auction_not_found" {
+		t.Errorf("Expected an auction_not_found error, got %v", err)
+	}
+
+	item, err := sam.CreateAuction(ctx, auction.AuctionItem{
+		Name:       "Lamp",
+		MinimumBid: usd(10),
+		ExpiryTime: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+	if _, err := bob.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(20)}); err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+
+	_, err = alice.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(20)})
+	var tooLow *BidTooLowError
+	if !errors.As(err, &tooLow) {
+		t.Fatalf("Expected a BidTooLowError, got %v", err)
+	}
+	if tooLow.NextMinimumBid != usd(20.01) {
+		t.Errorf("Expected next minimum bid of 20.01, got %s", tooLow.NextMinimumBid)
+	}
+	if StatusCode(err) != http.StatusBadRequest || ErrorCode(err) != CodeBidTooLow {
+		t.Errorf("Expected a 400 bid_too_low error, got %d %q", StatusCode(err), ErrorCode(err))
+	}
+
+	_, err = bob.AcceptPrice(ctx, item.ID, "")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != "not_dutch_auction" {
+		t.Errorf("Expected accepting an english auction to fail with a 400 not_dutch_auction error, got %v", err)
+	}
+
+	_, err = alice.CreateAuction(ctx, auction.AuctionItem{Name: "Lamp"})
+	if !errors.As(err, &apiErr) || apiErr.Code != "invalid_request" || apiErr.Message == "" {
+		t.Errorf("Expected an invalid_request error with a message, got %v", err)
+	}
+}
+
+func TestClientAuthentication(t *testing.T) {
+	server := api.NewServer()
+	server.Keys = auth.Keys{HMACSecret: []byte("secret")}
+	ts := httptest.NewServer(server.Router)
+	t.Cleanup(ts.Close)
+	ctx := context.Background()
+
+	anonymous := New([]string{ts.URL})
+	sam := register(t, ts.URL, "sam")
+	alice := register(t, ts.URL, "alice")
+
+	if _, err := anonymous.Register(ctx, "alice"); StatusCode(err) != http.StatusConflict || ErrorCode(err) != "participant_exists" {
+		t.Errorf("Expected registering alice twice to fail with participant_exists, got %v", err)
+	}
+	if me, err := alice.Me(ctx); err != nil || me.ID != "alice" || me.APIKeyHash != "" {
+		t.Errorf("Expected alice without her key hash, got %+v, %v", me, err)
+	}
+
+	item, err := sam.CreateAuction(ctx, auction.AuctionItem{
+		Name:       "Lamp",
+		MinimumBid: usd(10),
+		ExpiryTime: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+
+	// Changes need a credential, and can only act for its participant
+	_, err = anonymous.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "alice", BidPrice: usd(20)})
+	if StatusCode(err) != http.StatusUnauthorized || ErrorCode(err) != "unauthenticated" {
+		t.Errorf("Expected an anonymous bid to fail with unauthenticated, got %v", err)
+	}
+	_, err = alice.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "bob", BidPrice: usd(20)})
+	if StatusCode(err) != http.StatusForbidden || ErrorCode(err) != "participant_mismatch" {
+		t.Errorf("Expected bidding as someone else to fail with participant_mismatch, got %v", err)
+	}
+	forged := New([]string{ts.URL}, WithAPIKey("alice.not-her-secret"))
+	if _, err := forged.Me(ctx); StatusCode(err) != http.StatusUnauthorized || ErrorCode(err) != "invalid_credentials" {
+		t.Errorf("Expected a wrong API key to fail with invalid_credentials, got %v", err)
+	}
+
+	// A token works in place of the API key
+	token, err := alice.Token(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get a token: %v", err)
+	}
+	if !token.ExpiresAt.After(time.Now()) {
+		t.Errorf("Expected the token to expire in the future, got %v", token.ExpiresAt)
+	}
+	withToken := New([]string{ts.URL}, WithToken(token.Token))
+	if _, err := withToken.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(20)}); err != nil {
+		t.Fatalf("Failed to bid with a token: %v", err)
+	}
+	if bids, err := anonymous.BidHistory(ctx, item.ID); err != nil || len(bids) != 1 || bids[0].ParticipantID != "alice" {
+		t.Errorf("Expected one bid from alice, got %+v, %v", bids, err)
+	}
+
+	// Servers without keys do not issue tokens
+	if _, err := register(t, newTestServer(t).URL, "bob").Token(ctx); ErrorCode(err) != "token_issuing_disabled" {
+		t.Errorf("Expected token_issuing_disabled, got %v", err)
+	}
+}
+
+func TestClientSellerActions(t *testing.T) {
+	ts := newTestServer(t)
+	sam := register(t, ts.URL, "sam")
+	alice := register(t, ts.URL, "alice")
+	ctx := context.Background()
+
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	item, err := sam.CreateAuction(ctx, auction.AuctionItem{
+		Name:       "Lamp",
+		MinimumBid: usd(10),
+		ExpiryTime: expiry,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+
+	description := "Brass desk lamp"
+	later := expiry.Add(time.Hour)
+	if _, err := alice.UpdateAuction(ctx, item.ID, auction.AuctionUpdate{Description: &description}); StatusCode(err) != http.StatusForbidden || ErrorCode(err) != "not_seller" {
+		t.Errorf("Expected alice editing sam's auction to fail with not_seller, got %v", err)
+	}
+	updated, err := sam.UpdateAuction(ctx, item.ID, auction.AuctionUpdate{Description: &description, ExpiryTime: &later})
+	if err != nil {
+		t.Fatalf("Failed to update auction: %v", err)
+	}
+	if updated.Description != description || !updated.ExpiryTime.Equal(later) {
+		t.Errorf("Expected the new description and expiry, got %+v", updated)
+	}
+	if _, err := sam.UpdateAuction(ctx, item.ID, auction.AuctionUpdate{ExpiryTime: &expiry}); ErrorCode(err) != "expiry_not_extended" {
+		t.Errorf("Expected an earlier expiry to fail with expiry_not_extended, got %v", err)
+	}
+
+	// Sellers cannot bid up their own items
+	_, err = sam.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(20)})
+	if StatusCode(err) != http.StatusForbidden || ErrorCode(err) != "seller_bid" {
+		t.Errorf("Expected the seller's bid to fail with seller_bid, got %v", err)
+	}
+
+	// Once bidding starts the terms are fixed, but the seller can still cancel
+	if _, err := alice.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(20)}); err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+	if _, err := sam.UpdateAuction(ctx, item.ID, auction.AuctionUpdate{Description: &description}); StatusCode(err) != http.StatusConflict || ErrorCode(err) != "auction_has_bids" {
+		t.Errorf("Expected editing after a bid to fail with auction_has_bids, got %v", err)
+	}
+	if _, err := sam.CancelAuction(ctx, item.ID, ""); ErrorCode(err) != "invalid_request" {
+		t.Errorf("Expected cancelling without a reason to fail with invalid_request, got %v", err)
+	}
+	if _, err := alice.CancelAuction(ctx, item.ID, "Changed my mind"); ErrorCode(err) != "not_seller" {
+		t.Errorf("Expected alice cancelling sam's auction to fail with not_seller, got %v", err)
+	}
+
+	settlement, err := sam.CancelAuction(ctx, item.ID, "Item damaged")
+	if err != nil {
+		t.Fatalf("Failed to cancel auction: %v", err)
+	}
+	if settlement.Outcome != auction.OutcomeCancelled || settlement.Reason != "Item damaged" || settlement.WinnerID != "" {
+		t.Errorf("Expected a cancellation without a winner, got %+v", settlement)
+	}
+	if result, err := alice.Result(ctx, item.ID); err != nil || result.Outcome != auction.OutcomeCancelled {
+		t.Errorf("Expected the result to be the cancellation, got %+v, %v", result, err)
+	}
+	_, err = alice.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(30)})
+	if ErrorCode(err) != "auction_closed" {
+		t.Errorf("Expected a bid on a cancelled auction to fail with auction_closed, got %v", err)
+	}
+}
+
+func TestClientListAuctions(t *testing.T) {
+	ts := newTestServer(t)
+	sam := register(t, ts.URL, "sam")
+	kim := register(t, ts.URL, "kim")
+	alice := register(t, ts.URL, "alice")
+	ctx := context.Background()
+
+	var created []auction.AuctionItem
+	for i, seller := range []*Client{sam, kim, sam} {
+		item, err := seller.CreateAuction(ctx, auction.AuctionItem{
+			Name:       fmt.Sprintf("Lot %d", i),
+			MinimumBid: usd(10),
+			ExpiryTime: time.Now().Add(time.Duration(i+1) * time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create auction: %v", err)
+		}
+		created = append(created, item)
+	}
+	if _, err := alice.PlaceBid(ctx, auction.Bid{AuctionItemID: created[1].ID, BidPrice: usd(25)}); err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+
+	page, err := alice.ListAuctions(ctx, auction.ListQuery{Sort: auction.SortHighestBid, Limit: 1})
+	if err != nil {
+		t.Fatalf("Failed to list auctions: %v", err)
+	}
+	if len(page.Auctions) != 1 || page.Auctions[0].ID != created[1].ID || page.Auctions[0].CurrentBid != usd(25) {
+		t.Fatalf("Expected the auction with a bid first, got %+v", page.Auctions)
+	}
+	if page.NextCursor == "" {
+		t.Fatalf("Expected a cursor for the next page")
+	}
+
+	// Filters and the sort order carry over to the next page
+	query := auction.ListQuery{SellerID: "sam", Sort: auction.SortEndingSoonest, Limit: 1}
+	var names []string
+	for more := true; more; {
+		page, err := alice.ListAuctions(ctx, query)
+		if err != nil {
+			t.Fatalf("Failed to list auctions: %v", err)
+		}
+		for _, item := range page.Auctions {
+			names = append(names, item.Name)
+		}
+		query, more = query.Next(page)
+	}
+	if fmt.Sprint(names) != "[Lot 0 Lot 2]" {
+		t.Errorf("Expected sam's auctions ending soonest first, got %v", names)
+	}
+
+	if _, err := alice.ListAuctions(ctx, auction.ListQuery{Status: "sold"}); ErrorCode(err) != "invalid_request" {
+		t.Errorf("Expected an unknown status to fail with invalid_request, got %v", err)
+	}
+}
+
+func TestClientSearch(t *testing.T) {
+	ts := newTestServer(t)
+	sam := register(t, ts.URL, "sam")
+	ctx := context.Background()
+
+	lamp, err := sam.CreateAuction(ctx, auction.AuctionItem{
+		Name:       "Brass desk lamp",
+		Category:   "Lighting",
+		Tags:       []string{"Vintage", "vintage", "brass"},
+		MinimumBid: usd(10),
+		ExpiryTime: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+	if lamp.Category != "lighting" || fmt.Sprint(lamp.Tags) != "[vintage brass]" {
+		t.Errorf("Expected normalized labels, got %q %q", lamp.Category, lamp.Tags)
+	}
+	if _, err := sam.CreateAuction(ctx, auction.AuctionItem{
+		Name:        "Oak desk",
+		Description: "Solid oak, fits a lamp",
+		Category:    "furniture",
+		MinimumBid:  usd(10),
+		ExpiryTime:  time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+
+	results, err := sam.Search(ctx, "lamps", auction.ListQuery{})
+	if err != nil || len(results) != 2 || results[0].ID != lamp.ID {
+		t.Fatalf("Expected both auctions with the lamp first, got %v, %v", results, err)
+	}
+	if results, err := sam.Search(ctx, "desk", auction.ListQuery{Tag: "Vintage"}); err != nil || len(results) != 1 || results[0].ID != lamp.ID {
+		t.Errorf("Expected the tag to narrow the results, got %v, %v", results, err)
+	}
+	if page, err := sam.ListAuctions(ctx, auction.ListQuery{Category: "furniture"}); err != nil || len(page.Auctions) != 1 {
+		t.Errorf("Expected one furniture auction, got %v, %v", page, err)
+	}
+
+	// Edited labels are searchable straight away
+	category := "Antiques"
+	if _, err := sam.UpdateAuction(ctx, lamp.ID, auction.AuctionUpdate{Category: &category}); err != nil {
+		t.Fatalf("Failed to update auction: %v", err)
+	}
+	if results, err := sam.Search(ctx, "antique", auction.ListQuery{Category: "antiques"}); err != nil || len(results) != 1 {
+		t.Errorf("Expected the new category to be found, got %v, %v", results, err)
+	}
+
+	if _, err := sam.Search(ctx, " ", auction.ListQuery{}); ErrorCode(err) != "invalid_request" {
+		t.Errorf("Expected an empty search to fail with invalid_request, got %v", err)
+	}
+	if _, err := sam.Search(ctx, "lamp", auction.ListQuery{Sort: auction.SortNewest}); ErrorCode(err) != "invalid_request" {
+		t.Errorf("Expected a sorted search to fail with invalid_request, got %v", err)
+	}
+}
+
+func TestClientFailover(t *testing.T) {
+	ts := newTestServer(t)
+
+	var failing atomic.Int32
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failing.Add(1)
+		http.Error(w, "lost quorum", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(broken.Close)
+
+	// An unreachable server, a failing one, then a healthy one
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	c := New([]string{unreachable.URL, broken.URL, ts.URL})
+	if _, err := c.ListAuctions(context.Background(), auction.ListQuery{}); err != nil {
+		t.Fatalf("Expected the healthy server to answer, got %v", err)
+	}
+	if failing.Load() != 1 {
+		t.Errorf("Expected the failing server to be tried once, got %d", failing.Load())
+	}
+
+	// The server that answered is tried first from then on
+	if _, err := c.ListAuctions(context.Background(), auction.ListQuery{}); err != nil {
+		t.Fatalf("Expected the healthy server to answer, got %v", err)
+	}
+	if failing.Load() != 1 {
+		t.Errorf("Expected the healthy server to be preferred, failing server tried %d times", failing.Load())
+	}
+
+	// 4xx responses are not retried elsewhere
+	c = New([]string{ts.URL, broken.URL})
+	if _, err := c.GetAuction(context.Background(), "missing"); !IsNotFound(err) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+	if failing.Load() != 1 {
+		t.Errorf("Expected a 404 not to fail over, failing server tried %d times", failing.Load())
+	}
+
+	c = New([]string{unreachable.URL, broken.URL})
+	_, err := c.ListAuctions(context.Background(), auction.ListQuery{})
+	var unavailable *UnavailableError
+	if !errors.As(err, &unavailable) || len(unavailable.Failures) != 2 {
+		t.Fatalf("Expected both servers to fail, got %v", err)
+	}
+	if StatusCode(err) != http.StatusServiceUnavailable {
+		t.Errorf("Expected the 503 to be reachable through the error, got %d", StatusCode(err))
+	}
+
+	// A write without an idempotency key may have been applied by a server
+	// that failed, so it only moves on from servers it could not reach
+	before := failing.Load()
+	_, err = New([]string{broken.URL, ts.URL}).Register(context.Background(), "zed")
+	if StatusCode(err) != http.StatusServiceUnavailable || failing.Load() != before+1 {
+		t.Errorf("Expected the failing server's 503 without failover, got %v", err)
+	}
+	if _, err := New([]string{unreachable.URL, ts.URL}).Register(context.Background(), "zed"); err != nil {
+		t.Errorf("Expected the write to move on from the unreachable server, got %v", err)
+	}
+
+	if _, err := New(nil).ListAuctions(context.Background(), auction.ListQuery{}); !errors.Is(err, ErrNoServers) {
+		t.Errorf("Expected ErrNoServers, got %v", err)
+	}
+}
+
+func TestClientBidTooLowDoesNotFailOver(t *testing.T) {
+	var requests atomic.Int32
+	tooLow := func() *httptest.Server {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":"bid_too_low","message":"Bid too low","details":{"next_minimum_bid":{"amount":1100,"currency":"USD"}}}}`))
+		}))
+		t.Cleanup(ts.Close)
+		return ts
+	}
+
+	// The bid carries an idempotency key, but every server would reject it
+	c := New([]string{tooLow().URL, tooLow().URL, tooLow().URL}, WithAPIKey("ak_test"))
+	_, err := c.PlaceBid(context.Background(), auction.Bid{AuctionItemID: "item", BidPrice: usd(10)})
+	var bidTooLow *BidTooLowError
+	if !errors.As(err, &bidTooLow) {
+		t.Fatalf("Expected a BidTooLowError, got %T %v", err, err)
+	}
+	var unavailable *UnavailableError
+	if errors.As(err, &unavailable) {
+		t.Errorf("Expected the rejection itself, got %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected exactly one request, got %d", requests.Load())
+	}
+}
+
+func TestClientContextCancellation(t *testing.T) {
+	var calls atomic.Int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-r.Context().Done()
+	}))
+	t.Cleanup(slow.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	c := New([]string{slow.URL, slow.URL})
+	_, err := c.ListAuctions(ctx, auction.ListQuery{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to stop the request, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected no failover after the deadline, got %d calls", calls.Load())
+	}
+}
+
+func TestClientIdempotentRetries(t *testing.T) {
+	ts := newTestServer(t)
+	seller := register(t, ts.URL, "sam")
+	alice := register(t, ts.URL, "alice")
+	ctx := context.Background()
+
+	item, err := seller.CreateAuction(ctx, auction.AuctionItem{Name: "Lamp", MinimumBid: usd(10), ExpiryTime: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+
+	// A server that applies the bid, then fails before answering
+	var keys []string
+	lossy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		req, _ := http.NewRequestWithContext(r.Context(), r.Method, ts.URL+r.URL.Path, r.Body)
+		req.Header = r.Header.Clone()
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+		http.Error(w, "lost quorum", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(lossy.Close)
+
+	c := New([]string{lossy.URL, ts.URL}, WithAPIKey(alice.credential))
+	if _, err := c.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(20)}); err != nil {
+		t.Fatalf("Expected the retried bid to succeed, got %v", err)
+	}
+	if len(keys) != 1 || keys[0] == "" {
+		t.Errorf("Expected the bid to carry an idempotency key, got %q", keys)
+	}
+	if history, _ := c.BidHistory(ctx, item.ID); len(history) != 1 {
+		t.Errorf("Expected the retry to place a single bid, got %d", len(history))
+	}
+
+	// A caller-chosen key replays across calls, and refuses another request
+	keyed := WithIdempotencyKey(ctx, "bid-30")
+	for i := 0; i < 2; i++ {
+		if _, err := alice.PlaceBid(keyed, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(30)}); err != nil {
+			t.Fatalf("Expected attempt %d to succeed, got %v", i+1, err)
+		}
+	}
+	if history, _ := c.BidHistory(ctx, item.ID); len(history) != 2 {
+		t.Errorf("Expected the repeated key to place one more bid, got %d", len(history))
+	}
+	_, err = alice.PlaceBid(keyed, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(40)})
+	if ErrorCode(err) != "idempotency_key_reused" || StatusCode(err) != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a reused key to fail with idempotency_key_reused, got %v", err)
+	}
+}
+
+func TestClientBids(t *testing.T) {
+	ts := newTestServer(t)
+	seller := register(t, ts.URL, "sam")
+	alice := register(t, ts.URL, "alice")
+	bob := register(t, ts.URL, "bob")
+	ctx := context.Background()
+
+	item, err := seller.CreateAuction(ctx, auction.AuctionItem{Name: "Lamp", MinimumBid: usd(10), ExpiryTime: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+
+	placed, err := alice.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(20)})
+	if err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+	if placed.ID == "" || placed.ParticipantID != "alice" || placed.Sequence != 1 || placed.Timestamp.IsZero() {
+		t.Errorf("Expected the stored bid with sequence 1, got %+v", placed)
+	}
+	if next, _ := bob.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(30)}); next.Sequence != 2 {
+		t.Errorf("Expected the next bid to have sequence 2, got %+v", next)
+	}
+
+	// The response points at the bid
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/auctions/"+item.ID+"/bids", strings.NewReader(`{"bid_price": 40}`))
+	req.Header.Set("Authorization", "Bearer "+alice.credential)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+	resp.Body.Close()
+	location := resp.Header.Get("Location")
+	if resp.StatusCode != http.StatusCreated || !strings.HasPrefix(location, "/auctions/"+item.ID+"/bids/") {
+		t.Fatalf("Expected 201 with the bid's location, got %d and %q", resp.StatusCode, location)
+	}
+	got, err := bob.GetBid(ctx, item.ID, strings.TrimPrefix(location, "/auctions/"+item.ID+"/bids/"))
+	if err != nil || got.BidPrice != usd(40) || got.Sequence != 3 {
+		t.Errorf("Expected the located bid of 40.00 with sequence 3, got %+v, %v", got, err)
+	}
+	if _, err := bob.GetBid(ctx, item.ID, "missing"); ErrorCode(err) != "bid_not_found" {
+		t.Errorf("Expected bid_not_found, got %v", err)
+	}
+
+	// Sealed bids are only shown to their bidder
+	sealed, err := seller.CreateAuction(ctx, auction.AuctionItem{Name: "Vase", AuctionType: auction.TypeSealedFirstPrice, MinimumBid: usd(10), ExpiryTime: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+	placed, err = alice.PlaceBid(ctx, auction.Bid{AuctionItemID: sealed.ID, BidPrice: usd(20)})
+	if err != nil {
+		t.Fatalf("Failed to place sealed bid: %v", err)
+	}
+	if got, err := alice.GetBid(ctx, sealed.ID, placed.ID); err != nil || got.BidPrice != usd(20) {
+		t.Errorf("Expected alice to see her sealed bid, got %+v, %v", got, err)
+	}
+	if _, err := bob.GetBid(ctx, sealed.ID, placed.ID); ErrorCode(err) != "bids_sealed" {
+		t.Errorf("Expected bob to be refused alice's sealed bid, got %v", err)
+	}
+}
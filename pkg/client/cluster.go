@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Member is an auction server that is alive in the cluster
+type Member struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Version   string    `json:"version"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Cluster returns the live servers of the cluster, ordered by ID
+func (c *Client) Cluster(ctx context.Context) ([]Member, error) {
+	var cluster struct {
+		Members []Member `json:"members"`
+	}
+	err := c.do(ctx, http.MethodGet, "/cluster", nil, &cluster)
+	return cluster.Members, err
+}
+
+// DefaultRefreshInterval is how often a client created by Discover asks
+// the cluster for its live servers
+const DefaultRefreshInterval = time.Minute
+
+// WithRefreshInterval sets how often a client created by Discover asks the
+// cluster for its live servers
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.refreshInterval = interval
+	}
+}
+
+// Discover creates a client for the cluster that the server at seed is part
+// of. Requests go to the seed first, then to the other live servers, so the
+// client keeps working when the seed goes down. A seed that does not know
+// its cluster yields a client for the seed alone. The client asks the
+// cluster for its servers again every refresh interval, in the background,
+// and whenever every server it knows fails.
+func Discover(ctx context.Context, seed string, opts ...Option) (*Client, error) {
+	members, err := New([]string{seed}, opts...).Cluster(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seed = strings.TrimRight(seed, "/")
+	c := New(clusterURLs(seed, members), opts...)
+	c.seed = seed
+	c.refreshedAt = time.Now()
+	return c, nil
+}
+
+// clusterURLs returns the seed followed by the URLs of the other members
+func clusterURLs(seed string, members []Member) []string {
+	urls := []string{seed}
+	seen := map[string]bool{seed: true}
+	for _, member := range members {
+		u := strings.TrimRight(member.URL, "/")
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// refreshIfDue refreshes the servers of a discovered client in the
+// background once the refresh interval has passed
+func (c *Client) refreshIfDue() {
+	if c.seed == "" || c.refreshInterval <= 0 {
+		return
+	}
+	c.mu.Lock()
+	due := time.Since(c.refreshedAt) >= c.refreshInterval
+	c.mu.Unlock()
+	if !due || !c.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer c.refreshing.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), c.http.Timeout+time.Second)
+		defer cancel()
+		c.refresh(ctx)
+	}()
+}
+
+// refresh asks the servers of the client, in turn, for the live servers of
+// the cluster, and replaces its servers with the first answer. It returns
+// the servers that were not known before.
+func (c *Client) refresh(ctx context.Context) []string {
+	urls, start := c.servers()
+	for i := range urls {
+		server := urls[(start+i)%len(urls)]
+		resp, err := c.sendOnce(ctx, c.http, server, http.MethodGet, "/cluster", nil, nil)
+		if err != nil {
+			continue
+		}
+		var cluster struct {
+			Members []Member `json:"members"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&cluster)
+		resp.Body.Close()
+		if err != nil {
+			continue
+		}
+		return c.setServers(clusterURLs(c.seed, cluster.Members))
+	}
+
+	// No server answered, so try again at the next request
+	c.mu.Lock()
+	c.refreshedAt = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+// setServers replaces the servers of the client, keeping the preferred one
+// if it is still listed, and returns the servers that are new
+func (c *Client) setServers(urls []string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	known := make(map[string]bool, len(c.urls))
+	for _, u := range c.urls {
+		known[u] = true
+	}
+	var added []string
+	preferred := 0
+	for i, u := range urls {
+		if !known[u] {
+			added = append(added, u)
+		}
+		if u == c.urls[c.preferred] {
+			preferred = i
+		}
+	}
+	c.urls = urls
+	c.preferred = preferred
+	c.refreshedAt = time.Now()
+	return added
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/api"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
+)
+
+func TestCluster(t *testing.T) {
+	ctx := context.Background()
+	server := api.NewServer()
+	ts := httptest.NewServer(server.Router)
+	t.Cleanup(ts.Close)
+
+	// A server without a registry lists nobody until it joins, then itself
+	members, err := New([]string{ts.URL}).Cluster(ctx)
+	if err != nil || len(members) != 0 {
+		t.Fatalf("Expected no members before joining, got %v, %v", members, err)
+	}
+	startedAt := time.Now().UTC().Truncate(time.Second)
+	err = server.Join(ctx, storage.Member{ID: "node-1", URL: ts.URL, Version: "test", StartedAt: startedAt})
+	if err != nil {
+		t.Fatalf("Failed to join: %v", err)
+	}
+	members, err = New([]string{ts.URL}).Cluster(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list the cluster: %v", err)
+	}
+	if len(members) != 1 || members[0].ID != "node-1" || members[0].URL != ts.URL ||
+		members[0].Version != "test" || !members[0].StartedAt.Equal(startedAt) {
+		t.Errorf("Expected the server to list itself, got %+v", members)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	ts := newTestServer(t)
+
+	// The seed knows the cluster but fails every other request
+	var seed *httptest.Server
+	seed = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cluster" {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string][]Member{
+			"members": {
+				{ID: "node-1", URL: seed.URL + "/"},
+				{ID: "node-2", URL: ts.URL},
+				{ID: "node-3"},
+			},
+		})
+	}))
+	t.Cleanup(seed.Close)
+
+	c, err := Discover(context.Background(), seed.URL)
+	if err != nil {
+		t.Fatalf("Failed to discover the cluster: %v", err)
+	}
+	if urls, _ := c.servers(); !slices.Equal(urls, []string{seed.URL, ts.URL}) {
+		t.Fatalf("Expected the seed then the other member, got %v", urls)
+	}
+	if _, err := c.ListAuctions(context.Background(), auction.ListQuery{}); err != nil {
+		t.Errorf("Expected the discovered server to answer, got %v", err)
+	}
+
+	// Without a seed there is nothing to discover
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	if _, err := Discover(context.Background(), unreachable.URL); err == nil {
+		t.Error("Expected an unreachable seed to fail")
+	}
+}
+
+func TestDiscoverRefreshesServers(t *testing.T) {
+	ctx := context.Background()
+	gone := httptest.NewServer(http.NotFoundHandler())
+	joined := newTestServer(t)
+
+	// The seed knows the cluster but fails every other request
+	var mu sync.Mutex
+	members := []Member{{ID: "node-2", URL: gone.URL}}
+	seed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cluster" {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		json.NewEncoder(w).Encode(map[string][]Member{"members": members})
+	}))
+	t.Cleanup(seed.Close)
+
+	c, err := Discover(ctx, seed.URL)
+	if err != nil {
+		t.Fatalf("Failed to discover the cluster: %v", err)
+	}
+
+	// Once every known server fails, the client finds the one that joined
+	gone.Close()
+	mu.Lock()
+	members = []Member{{ID: "node-3", URL: joined.URL}}
+	mu.Unlock()
+	if _, err := c.ListAuctions(ctx, auction.ListQuery{}); err != nil {
+		t.Fatalf("Expected the server that joined to answer, got %v", err)
+	}
+	if urls, _ := c.servers(); !slices.Equal(urls, []string{seed.URL, joined.URL}) {
+		t.Errorf("Expected the seed then the server that joined, got %v", urls)
+	}
+
+	// Requests also refresh the servers in the background once the interval
+	// has passed
+	c, err = Discover(ctx, seed.URL, WithRefreshInterval(time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to discover the cluster: %v", err)
+	}
+	mu.Lock()
+	members = []Member{{ID: "node-4", URL: gone.URL}}
+	mu.Unlock()
+	time.Sleep(2 * time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.ListAuctions(ctx, auction.ListQuery{})
+		if urls, _ := c.servers(); slices.Equal(urls, []string{seed.URL, gone.URL}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the servers to be refreshed on an interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+// ErrNoServers is returned when a client has no server URLs
+var ErrNoServers = errors.New("no servers configured")
+
+// Error is an error response from a server
+type Error struct {
+	StatusCode int
+	// Code identifies the kind of failure, such as "auction_not_found".
+	// It is empty if the response was not in the JSON error format.
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// CodeBidTooLow is the error code of a BidTooLowError
+const CodeBidTooLow = "bid_too_low"
+
+// BidTooLowError is returned when a bid is below the next minimum bid
+type BidTooLowError struct {
+	Message        string
+	NextMinimumBid auction.Money
+}
+
+func (e *BidTooLowError) Error() string {
+	return e.Message
+}
+
+// UnavailableError is returned when no server could handle a request
+type UnavailableError struct {
+	// Failures holds the error from each server tried, in order
+	Failures []error
+}
+
+func (e *UnavailableError) Error() string {
+	messages := make([]string, len(e.Failures))
+	for i, err := range e.Failures {
+		messages[i] = err.Error()
+	}
+	return "all servers failed:\n  " + strings.Join(messages, "\n  ")
+}
+
+func (e *UnavailableError) Unwrap() []error {
+	return e.Failures
+}
+
+// StatusCode returns the HTTP status of the server response behind err,
+// or 0 if err did not come from a server response
+func StatusCode(err error) int {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	var tooLow *BidTooLowError
+	if errors.As(err, &tooLow) {
+		return http.StatusBadRequest
+	}
+	return 0
+}
+
+// ErrorCode returns the error code of the server response behind err, or
+// an empty string if it has none
+func ErrorCode(err error) string {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	var tooLow *BidTooLowError
+	if errors.As(err, &tooLow) {
+		return CodeBidTooLow
+	}
+	return ""
+}
+
+// IsNotFound reports whether err means the auction or result does not exist
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// parseError turns an error response into an error. Servers send errors
+// as {"error":{"code","message","details"}}, anything else, such as a page
+// from a proxy, is kept as plain text.
+func parseError(statusCode int, data []byte) error {
+	var envelope struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+			Details struct {
+				NextMinimumBid *auction.Money `json:"next_minimum_bid"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &envelope) != nil || envelope.Error.Code == "" {
+		return &Error{StatusCode: statusCode, Message: strings.TrimSpace(string(data))}
+	}
+
+	body := envelope.Error
+	if body.Code == CodeBidTooLow && body.Details.NextMinimumBid != nil {
+		return &BidTooLowError{Message: body.Message, NextMinimumBid: *body.Details.NextMinimumBid}
+	}
+	return &Error{StatusCode: statusCode, Code: body.Code, Message: body.Message}
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+// reconnectDelay is how long an event stream waits before reconnecting
+const reconnectDelay = time.Second
+
+// Events follows the events of an auction with a sequence number above
+// after, so pass 0 to start at the beginning. When a connection drops the
+// stream reconnects, moving on to another server if needed, and resumes
+// after the last event it delivered. The channel is closed once ctx is
+// cancelled, or a server rejects the stream with a 4xx response. An error is
+// only returned if the first connection fails.
+func (c *Client) Events(ctx context.Context, id string, after uint64) (<-chan auction.Event, error) {
+	// The request timeout would cut the stream off, so streams have none
+	hc := *c.http
+	hc.Timeout = 0
+
+	resp, err := c.openEvents(ctx, &hc, id, after)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan auction.Event)
+	go func() {
+		defer close(out)
+		for {
+			after = readEvents(ctx, resp.Body, after, out)
+			resp.Body.Close()
+
+			for {
+				select {
+				case <-time.After(c.reconnectDelay):
+				case <-ctx.Done():
+					return
+				}
+
+				resp, err = c.openEvents(ctx, &hc, id, after)
+				if err == nil {
+					break
+				}
+				if code := StatusCode(err); code >= 400 && code < 500 {
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (c *Client) openEvents(ctx context.Context, hc *http.Client, id string, after uint64) (*http.Response, error) {
+	header := http.Header{"Accept": {"text/event-stream"}}
+	if after > 0 {
+		header.Set("Last-Event-ID", strconv.FormatUint(after, 10))
+	}
+	return c.send(ctx, hc, http.MethodGet, auctionPath(id, "events"), nil, header)
+}
+
+// readEvents sends the events of a Server-Sent Events stream on out until
+// the stream ends, and returns the sequence number of the last one sent
+func readEvents(ctx context.Context, body io.Reader, after uint64, out chan<- auction.Event) uint64 {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	// The id and event fields repeat what is in the JSON data, so only the
+	// data is read. Comments are keep-alives.
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(value, " "))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+
+		// A blank line ends the event
+		var event auction.Event
+		err := json.Unmarshal([]byte(data.String()), &event)
+		data.Reset()
+		if err != nil || event.Sequence <= after {
+			continue
+		}
+
+		select {
+		case out <- event:
+			after = event.Sequence
+		case <-ctx.Done():
+			return after
+		}
+	}
+	return after
+}
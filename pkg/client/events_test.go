@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+// nextEvent waits for the next event of a stream
+func nextEvent(t *testing.T, events <-chan auction.Event) auction.Event {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatalf("Event stream closed")
+		}
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for an event")
+	}
+	return auction.Event{}
+}
+
+func TestEvents(t *testing.T) {
+	ts := newTestServer(t)
+	c := register(t, ts.URL, "sam")
+	alice := register(t, ts.URL, "alice")
+	bob := register(t, ts.URL, "bob")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	item, err := c.CreateAuction(ctx, auction.AuctionItem{
+		Name:        "Lamp",
+		MinimumBid:  usd(10),
+		BuyNowPrice: usd(50),
+		ExpiryTime:  time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+
+	events, err := c.Events(ctx, item.ID, 0)
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+
+	if _, err := alice.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(20)}); err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+	event := nextEvent(t, events)
+	if event.Sequence != 1 || event.Type != auction.EventBidPlaced || event.Bid == nil || event.Bid.BidPrice != usd(20) {
+		t.Errorf("Expected alice's bid as event 1, got %+v", event)
+	}
+
+	if _, err := bob.BuyNow(ctx, item.ID, ""); err != nil {
+		t.Fatalf("Failed to buy now: %v", err)
+	}
+	if event := nextEvent(t, events); event.Sequence != 2 || event.Type != auction.EventBidPlaced {
+		t.Errorf("Expected the purchase as event 2, got %+v", event)
+	}
+	if event := nextEvent(t, events); event.Sequence != 3 || event.Type != auction.EventClosed || event.Settlement.WinnerID != "bob" {
+		t.Errorf("Expected the close as event 3, got %+v", event)
+	}
+
+	// A new stream can resume after any event
+	resumed, err := c.Events(ctx, item.ID, 2)
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	if event := nextEvent(t, resumed); event.Sequence != 3 {
+		t.Errorf("Expected to resume at event 3, got %+v", event)
+	}
+
+	if _, err := c.Events(ctx, "missing", 0); !IsNotFound(err) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
+func TestEventsHideSealedBids(t *testing.T) {
+	ts := newTestServer(t)
+	c := register(t, ts.URL, "sam")
+	alice := register(t, ts.URL, "alice")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	item, err := c.CreateAuction(ctx, auction.AuctionItem{
+		Name:        "Painting",
+		AuctionType: auction.TypeSealedFirstPrice,
+		MinimumBid:  usd(10),
+		ExpiryTime:  time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+	if _, err := alice.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, BidPrice: usd(20)}); err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+
+	events, err := c.Events(ctx, item.ID, 0)
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	if event := nextEvent(t, events); event.Type != auction.EventBidPlaced || event.Bid != nil {
+		t.Errorf("Expected a sealed bid event without the bid, got %+v", event)
+	}
+}
+
+func TestEventsReconnect(t *testing.T) {
+	// Each server sends one event and drops the connection, the next
+	// connection should ask for the events after it
+	var connections atomic.Int32
+	var lastEventIDs [3]atomic.Value
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := connections.Add(1)
+		if n > 3 {
+			<-r.Context().Done()
+			return
+		}
+		lastEventIDs[n-1].Store(r.Header.Get("Last-Event-ID"))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, ": keep-alive\n\n")
+		fmt.Fprintf(w, "id: %d\nevent: bid_placed\ndata: {\"sequence\":%d,\"type\":\"bid_placed\",\"auction_id\":\"a1\"}\n\n", n, n)
+	})
+	first := httptest.NewServer(handler)
+	t.Cleanup(first.Close)
+	second := httptest.NewServer(handler)
+	t.Cleanup(second.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := New([]string{first.URL, second.URL})
+	c.reconnectDelay = 10 * time.Millisecond
+	events, err := c.Events(ctx, "a1", 0)
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	for want := uint64(1); want <= 3; want++ {
+		if event := nextEvent(t, events); event.Sequence != want {
+			t.Errorf("Expected event %d, got %+v", want, event)
+		}
+	}
+
+	for i, want := range []string{"", "1", "2"} {
+		if got, _ := lastEventIDs[i].Load().(string); got != want {
+			t.Errorf("Connection %d: expected Last-Event-ID %q, got %q", i+1, want, got)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Errorf("Expected no further events")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected the stream to close when cancelled")
+	}
+}
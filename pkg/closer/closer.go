@@ -0,0 +1,146 @@
+package closer
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
+)
+
+// keySweepInterval is how often a Closer removes idempotency records older
+// than storage.IdempotencyRetention
+const keySweepInterval = time.Hour
+
+// Closer periodically settles auctions whose expiry time has passed, and
+// removes expired idempotency records from stores that keep them. Every
+// server may run a Closer against a shared store: the store guarantees
+// that each auction is settled exactly once.
+type Closer struct {
+	store    storage.Store
+	interval time.Duration
+	now      func() time.Time
+	// keysSweptAt is when idempotency records were last swept
+	keysSweptAt time.Time
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New creates a closer that checks for expired auctions every interval
+func New(store storage.Store, interval time.Duration) *Closer {
+	return &Closer{
+		store:    store,
+		interval: interval,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the closer in the background until Stop is called
+func (c *Closer) Start() {
+	go c.run()
+}
+
+// Stop halts the closer, cancelling the current sweep, and waits for it to
+// return
+func (c *Closer) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.done
+}
+
+func (c *Closer) run() {
+	defer close(c.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-c.stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.Sweep(ctx)
+		}
+	}
+}
+
+// Sweep settles every auction that has expired but not yet been closed,
+// until ctx is done, and removes expired idempotency records once every
+// keySweepInterval
+func (c *Closer) Sweep(ctx context.Context) {
+	c.closeExpired(ctx)
+	c.sweepKeys(ctx)
+}
+
+// closeExpired settles every auction that has expired but not yet been
+// closed, a page at a time
+func (c *Closer) closeExpired(ctx context.Context) {
+	query := auction.ListQuery{Status: auction.StatusExpired, Sort: auction.SortEndingSoonest, Limit: auction.MaxListLimit}
+	for more := true; more; {
+		page, err := c.store.ListAuctions(ctx, query)
+		if err != nil {
+			log.Printf("Closer: failed to list auctions: %v", err)
+			return
+		}
+		c.closeAll(ctx, page.Auctions)
+		query, more = query.Next(page)
+	}
+}
+
+// sweepKeys removes the idempotency records that are past their retention
+func (c *Closer) sweepKeys(ctx context.Context) {
+	sweeper, ok := c.store.(storage.IdempotencySweeper)
+	now := c.now()
+	if !ok || ctx.Err() != nil || now.Sub(c.keysSweptAt) < keySweepInterval {
+		return
+	}
+
+	removed, err := sweeper.SweepIdempotencyKeys(ctx, now.Add(-storage.IdempotencyRetention))
+	if err != nil {
+		log.Printf("Closer: failed to sweep idempotency keys: %v", err)
+		return
+	}
+	c.keysSweptAt = now
+	if removed > 0 {
+		log.Printf("Closer: removed %d expired idempotency keys", removed)
+	}
+}
+
+// closeAll settles the auctions that have expired
+func (c *Closer) closeAll(ctx context.Context, auctions []auction.AuctionItem) {
+	now := c.now()
+	for _, item := range auctions {
+		if ctx.Err() != nil {
+			return
+		}
+		if item.ClosedAt != nil || now.Before(item.ExpiryTime) {
+			continue
+		}
+
+		settlement, err := c.store.CloseAuction(ctx, item.ID)
+		if err != nil {
+			log.Printf("Closer: failed to close auction %s: %v", item.ID, err)
+			continue
+		}
+
+		if settlement.Outcome == auction.OutcomeNoSale {
+			log.Printf("Auction %s closed without a sale: %s", item.ID, settlement.Reason)
+		} else {
+			log.Printf("Auction %s closed: won by %s at %s", item.ID, settlement.WinnerID, settlement.ClearingPrice)
+		}
+	}
+}
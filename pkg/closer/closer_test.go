@@ -0,0 +1,85 @@
+package closer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
+	"github.com/google/uuid"
+)
+
+func TestSweepSettlesExpiredAuctionsOnce(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := storage.NewMemoryStoreWithClock(
+		func() time.Time { return now },
+		func() string { return uuid.New().String() },
+	)
+
+	expiring, _ := store.CreateAuction(ctx, auction.AuctionItem{Name: "Expiring", MinimumBid: auction.NewMoney(1000, "USD"), ExpiryTime: now.Add(time.Minute)})
+	open, _ := store.CreateAuction(ctx, auction.AuctionItem{Name: "Open", MinimumBid: auction.NewMoney(1000, "USD"), ExpiryTime: now.Add(time.Hour * 24 * 365)})
+
+	for _, cents := range []int64{1000, 1500, 1200} {
+		store.PlaceBid(ctx, auction.Bid{ParticipantID: "bidder", AuctionItemID: expiring.ID, BidPrice: auction.NewMoney(cents, "USD")})
+	}
+	store.PlaceBid(ctx, auction.Bid{ParticipantID: "winner", AuctionItemID: expiring.ID, BidPrice: auction.NewMoney(2000, "USD")})
+
+	// Move past the first auction's expiry and sweep twice
+	now = now.Add(2 * time.Minute)
+	c := New(store, time.Hour)
+	c.now = func() time.Time { return now }
+	c.Sweep(ctx)
+	first, err := store.GetSettlement(ctx, expiring.ID)
+	if err != nil {
+		t.Fatalf("Expected expired auction to be settled: %v", err)
+	}
+	c.Sweep(ctx)
+	second, _ := store.GetSettlement(ctx, expiring.ID)
+
+	if first.WinnerID != "winner" || first.ClearingPrice != auction.NewMoney(2000, "USD") {
+		t.Fatalf("Unexpected settlement: %+v", first)
+	}
+	if !first.ClosedAt.Equal(second.ClosedAt) {
+		t.Fatalf("Auction was settled twice: %v and %v", first.ClosedAt, second.ClosedAt)
+	}
+
+	if _, err := store.GetSettlement(ctx, open.ID); err == nil {
+		t.Fatalf("Auction that has not expired should not be settled")
+	}
+
+	// Bids are rejected once the auction is closed
+	if _, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "late", AuctionItemID: expiring.ID, BidPrice: auction.NewMoney(5000, "USD")}); err == nil {
+		t.Fatalf("Expected bid on closed auction to be rejected")
+	}
+}
+
+func TestSweepForgetsOldIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := storage.NewMemoryStoreWithClock(
+		func() time.Time { return now },
+		func() string { return uuid.New().String() },
+	)
+	key := storage.IdempotencyKey{ParticipantID: "sam", Key: "create-lamp", Fingerprint: "lamp"}
+	item := auction.AuctionItem{Name: "Lamp", MinimumBid: auction.NewMoney(1000, "USD"), ExpiryTime: now.Add(365 * 24 * time.Hour)}
+	first, _ := store.CreateAuctionIdempotent(ctx, key, item)
+
+	c := New(store, time.Hour)
+	c.now = func() time.Time { return now }
+
+	// Within the retention window a retry returns the first auction
+	now = now.Add(storage.IdempotencyRetention - time.Minute)
+	c.Sweep(ctx)
+	if retried, _ := store.CreateAuctionIdempotent(ctx, key, item); retried.ID != first.ID {
+		t.Fatalf("Expected the key to be honoured, got auction %s instead of %s", retried.ID, first.ID)
+	}
+
+	// Past it the record is swept, and the key makes a new auction
+	now = now.Add(keySweepInterval)
+	c.Sweep(ctx)
+	if later, _ := store.CreateAuctionIdempotent(ctx, key, item); later.ID == first.ID {
+		t.Fatalf("Expected the key to be forgotten after %v", storage.IdempotencyRetention)
+	}
+}
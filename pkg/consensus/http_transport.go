@@ -0,0 +1,154 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// secretHeader carries the cluster secret that every Raft RPC must present
+const secretHeader = "X-Raft-Secret"
+
+// HTTPTransport delivers Raft RPCs as JSON over HTTP. Each peer is addressed
+// by the base URL of its auction server, which mounts NewHTTPHandler under /raft/.
+type HTTPTransport struct {
+	peers  map[string]string // Map node ID to base URL
+	secret string
+	client *http.Client
+}
+
+// NewHTTPTransport creates a transport for the given peer URLs, sending the
+// secret shared by the cluster with every RPC
+func NewHTTPTransport(peers map[string]string, secret string) *HTTPTransport {
+	return &HTTPTransport{
+		peers:  peers,
+		secret: secret,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// post sends a JSON request to an RPC endpoint of the target node
+func (t *HTTPTransport) post(ctx context.Context, target, rpc string, req, resp interface{}) error {
+	baseURL, ok := t.peers[target]
+	if !ok {
+		return ErrUnreachable
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimRight(baseURL, "/")+"/raft/"+rpc, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(secretHeader, t.secret)
+
+	httpResp, err := t.client.Do(httpReq)
+	if err != nil {
+		return ErrUnreachable
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("raft: %s to %s failed with status %d", rpc, target, httpResp.StatusCode)
+	}
+
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+func (t *HTTPTransport) RequestVote(ctx context.Context, target string, req *RequestVoteRequest) (*RequestVoteResponse, error) {
+	var resp RequestVoteResponse
+	return &resp, t.post(ctx, target, "vote", req, &resp)
+}
+
+func (t *HTTPTransport) AppendEntries(ctx context.Context, target string, req *AppendEntriesRequest) (*AppendEntriesResponse, error) {
+	var resp AppendEntriesResponse
+	return &resp, t.post(ctx, target, "append", req, &resp)
+}
+
+func (t *HTTPTransport) InstallSnapshot(ctx context.Context, target string, req *InstallSnapshotRequest) (*InstallSnapshotResponse, error) {
+	var resp InstallSnapshotResponse
+	return &resp, t.post(ctx, target, "snapshot", req, &resp)
+}
+
+func (t *HTTPTransport) Forward(ctx context.Context, target string, req *ForwardRequest) (*ForwardResponse, error) {
+	var resp ForwardResponse
+	return &resp, t.post(ctx, target, "forward", req, &resp)
+}
+
+func (t *HTTPTransport) ReadIndex(ctx context.Context, target string) (*ReadIndexResponse, error) {
+	var resp ReadIndexResponse
+	return &resp, t.post(ctx, target, "read-index", struct{}{}, &resp)
+}
+
+// NewHTTPHandler serves the RPC endpoints used by HTTPTransport. It is meant
+// to be mounted under the /raft/ prefix of the auction server. Requests that
+// do not present the cluster secret are rejected, since the RPCs can rewrite
+// the whole state; an empty secret rejects them all.
+func NewHTTPHandler(handler RPCHandler, secret string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/raft/vote", func(w http.ResponseWriter, r *http.Request) {
+		var req RequestVoteRequest
+		if decodeRPC(w, r, &req) {
+			writeRPC(w, handler.HandleRequestVote(&req))
+		}
+	})
+	mux.HandleFunc("/raft/append", func(w http.ResponseWriter, r *http.Request) {
+		var req AppendEntriesRequest
+		if decodeRPC(w, r, &req) {
+			writeRPC(w, handler.HandleAppendEntries(&req))
+		}
+	})
+	mux.HandleFunc("/raft/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		var req InstallSnapshotRequest
+		if decodeRPC(w, r, &req) {
+			writeRPC(w, handler.HandleInstallSnapshot(&req))
+		}
+	})
+	mux.HandleFunc("/raft/forward", func(w http.ResponseWriter, r *http.Request) {
+		var req ForwardRequest
+		if decodeRPC(w, r, &req) {
+			writeRPC(w, handler.HandleForward(r.Context(), &req))
+		}
+	})
+	mux.HandleFunc("/raft/read-index", func(w http.ResponseWriter, r *http.Request) {
+		writeRPC(w, handler.HandleReadIndex(r.Context()))
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented := r.Header.Get(secretHeader)
+		if secret == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(secret)) != 1 {
+			http.Error(w, "invalid cluster secret", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// decodeRPC reads a JSON request body, replying with an error if it is invalid
+func decodeRPC(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, "invalid raft request", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeRPC(w http.ResponseWriter, resp interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,74 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// recordingHandler answers forwarded commands and counts them
+type recordingHandler struct {
+	forwarded int
+}
+
+func (h *recordingHandler) HandleRequestVote(req *RequestVoteRequest) *RequestVoteResponse {
+	return &RequestVoteResponse{}
+}
+
+func (h *recordingHandler) HandleAppendEntries(req *AppendEntriesRequest) *AppendEntriesResponse {
+	return &AppendEntriesResponse{}
+}
+
+func (h *recordingHandler) HandleInstallSnapshot(req *InstallSnapshotRequest) *InstallSnapshotResponse {
+	return &InstallSnapshotResponse{}
+}
+
+func (h *recordingHandler) HandleForward(ctx context.Context, req *ForwardRequest) *ForwardResponse {
+	h.forwarded++
+	return &ForwardResponse{Result: req.Command}
+}
+
+func (h *recordingHandler) HandleReadIndex(ctx context.Context) *ReadIndexResponse {
+	return &ReadIndexResponse{}
+}
+
+func TestHTTPHandlerRequiresSecret(t *testing.T) {
+	ctx := context.Background()
+	handler := &recordingHandler{}
+	ts := httptest.NewServer(NewHTTPHandler(handler, "cluster-secret"))
+	t.Cleanup(ts.Close)
+
+	resp, err := NewHTTPTransport(map[string]string{"node-1": ts.URL}, "cluster-secret").
+		Forward(ctx, "node-1", &ForwardRequest{Command: []byte("command")})
+	if err != nil || string(resp.Result) != "command" {
+		t.Fatalf("Expected a peer with the secret to be served, got %v, %v", resp, err)
+	}
+
+	if _, err := NewHTTPTransport(map[string]string{"node-1": ts.URL}, "wrong").
+		Forward(ctx, "node-1", &ForwardRequest{Command: []byte("command")}); err == nil {
+		t.Error("Expected a peer with the wrong secret to be rejected")
+	}
+	for _, rpc := range []string{"vote", "append", "snapshot", "forward", "read-index"} {
+		httpResp, err := http.Post(ts.URL+"/raft/"+rpc, "application/json", bytes.NewReader([]byte("{}")))
+		if err != nil {
+			t.Fatalf("Failed to send %s: %v", rpc, err)
+		}
+		httpResp.Body.Close()
+		if httpResp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected %s without the secret to be rejected, got status %d", rpc, httpResp.StatusCode)
+		}
+	}
+	if handler.forwarded != 1 {
+		t.Errorf("Expected only the authenticated command to be forwarded, got %d", handler.forwarded)
+	}
+
+	// Without a secret of its own, a server serves no one
+	open := httptest.NewServer(NewHTTPHandler(handler, ""))
+	t.Cleanup(open.Close)
+	if _, err := NewHTTPTransport(map[string]string{"node-1": open.URL}, "").
+		Forward(ctx, "node-1", &ForwardRequest{Command: []byte("command")}); err == nil {
+		t.Error("Expected a server without a secret to reject every RPC")
+	}
+}
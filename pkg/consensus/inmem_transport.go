@@ -0,0 +1,114 @@
+package consensus
+
+import (
+	"context"
+	"sync"
+)
+
+// InmemNetwork connects nodes running in the same process. It is intended
+// for tests and can simulate partitions by disconnecting nodes.
+type InmemNetwork struct {
+	mu           sync.RWMutex
+	handlers     map[string]RPCHandler
+	disconnected map[string]bool
+}
+
+// NewInmemNetwork creates an empty in-memory network
+func NewInmemNetwork() *InmemNetwork {
+	return &InmemNetwork{
+		handlers:     make(map[string]RPCHandler),
+		disconnected: make(map[string]bool),
+	}
+}
+
+// Register attaches a node to the network under the given ID
+func (n *InmemNetwork) Register(id string, handler RPCHandler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.handlers[id] = handler
+}
+
+// Disconnect drops all traffic to and from a node
+func (n *InmemNetwork) Disconnect(id string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.disconnected[id] = true
+}
+
+// Reconnect restores traffic to and from a node
+func (n *InmemNetwork) Reconnect(id string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.disconnected, id)
+}
+
+// Transport returns the transport used by the node with the given ID
+func (n *InmemNetwork) Transport(id string) Transport {
+	return &inmemTransport{network: n, from: id}
+}
+
+// route returns the handler for target if both endpoints are connected
+func (n *InmemNetwork) route(from, target string) (RPCHandler, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if n.disconnected[from] || n.disconnected[target] {
+		return nil, ErrUnreachable
+	}
+	handler, ok := n.handlers[target]
+	if !ok {
+		return nil, ErrUnreachable
+	}
+	return handler, nil
+}
+
+// inmemTransport is the Transport view of an InmemNetwork for a single node
+type inmemTransport struct {
+	network *InmemNetwork
+	from    string
+}
+
+// call runs fn against the target handler, giving up when ctx is done
+func call[T any](ctx context.Context, t *inmemTransport, target string, fn func(RPCHandler) T) (T, error) {
+	var zero T
+	handler, err := t.network.route(t.from, target)
+	if err != nil {
+		return zero, err
+	}
+
+	done := make(chan T, 1)
+	go func() {
+		done <- fn(handler)
+	}()
+
+	select {
+	case res := <-done:
+		// The reply is lost if either side was disconnected while the call was in flight
+		if _, err := t.network.route(t.from, target); err != nil {
+			return zero, err
+		}
+		return res, nil
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+func (t *inmemTransport) RequestVote(ctx context.Context, target string, req *RequestVoteRequest) (*RequestVoteResponse, error) {
+	return call(ctx, t, target, func(h RPCHandler) *RequestVoteResponse { return h.HandleRequestVote(req) })
+}
+
+func (t *inmemTransport) AppendEntries(ctx context.Context, target string, req *AppendEntriesRequest) (*AppendEntriesResponse, error) {
+	return call(ctx, t, target, func(h RPCHandler) *AppendEntriesResponse { return h.HandleAppendEntries(req) })
+}
+
+func (t *inmemTransport) InstallSnapshot(ctx context.Context, target string, req *InstallSnapshotRequest) (*InstallSnapshotResponse, error) {
+	return call(ctx, t, target, func(h RPCHandler) *InstallSnapshotResponse { return h.HandleInstallSnapshot(req) })
+}
+
+func (t *inmemTransport) Forward(ctx context.Context, target string, req *ForwardRequest) (*ForwardResponse, error) {
+	return call(ctx, t, target, func(h RPCHandler) *ForwardResponse { return h.HandleForward(ctx, req) })
+}
+
+func (t *inmemTransport) ReadIndex(ctx context.Context, target string) (*ReadIndexResponse, error) {
+	return call(ctx, t, target, func(h RPCHandler) *ReadIndexResponse { return h.HandleReadIndex(ctx) })
+}
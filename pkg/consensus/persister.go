@@ -0,0 +1,214 @@
+package consensus
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Persister stores a node's Raft state and latest snapshot. Both must
+// survive a restart for the node to rejoin the cluster safely.
+//
+// Changes between two saves are appended to a log of records, so that a
+// write costs the size of the change rather than the size of the state.
+// Saving the state replaces the log.
+type Persister interface {
+	ReadState() ([]byte, error)
+	ReadSnapshot() ([]byte, error)
+	// ReadLog returns the records appended since the state was saved
+	ReadLog() ([][]byte, error)
+	// AppendLog durably appends a record, which must not contain newlines
+	AppendLog(record []byte) error
+	SaveState(state []byte) error
+	SaveStateAndSnapshot(state, snapshot []byte) error
+}
+
+// MemoryPersister keeps Raft state in memory, for tests and throwaway clusters
+type MemoryPersister struct {
+	mu       sync.Mutex
+	state    []byte
+	snapshot []byte
+	records  [][]byte
+}
+
+// NewMemoryPersister creates an empty in-memory persister
+func NewMemoryPersister() *MemoryPersister {
+	return &MemoryPersister{}
+}
+
+func (p *MemoryPersister) ReadState() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state, nil
+}
+
+func (p *MemoryPersister) ReadSnapshot() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.snapshot, nil
+}
+
+func (p *MemoryPersister) ReadLog() ([][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]byte(nil), p.records...), nil
+}
+
+func (p *MemoryPersister) AppendLog(record []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = append(p.records, record)
+	return nil
+}
+
+func (p *MemoryPersister) SaveState(state []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state = state
+	p.records = nil
+	return nil
+}
+
+func (p *MemoryPersister) SaveStateAndSnapshot(state, snapshot []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state = state
+	p.snapshot = snapshot
+	p.records = nil
+	return nil
+}
+
+// FilePersister keeps Raft state in files under a directory. Log records
+// are appended to wal.log, one per line, and synced before AppendLog returns.
+type FilePersister struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFilePersister creates a persister writing to dir, creating it if needed
+func NewFilePersister(dir string) (*FilePersister, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FilePersister{dir: dir}, nil
+}
+
+func (p *FilePersister) ReadState() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.read("state.json")
+}
+
+func (p *FilePersister) ReadSnapshot() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.read("snapshot.json")
+}
+
+func (p *FilePersister) ReadLog() ([][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, err := p.read("wal.log")
+	if err != nil {
+		return nil, err
+	}
+	var records [][]byte
+	for {
+		line, rest, found := bytes.Cut(data, []byte("\n"))
+		if !found {
+			// A record without its newline was cut short by a crash, and
+			// AppendLog never reported it as written
+			return records, nil
+		}
+		records = append(records, line)
+		data = rest
+	}
+}
+
+func (p *FilePersister) AppendLog(record []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(p.dir, "wal.log"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(record[:len(record):len(record)], '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (p *FilePersister) SaveState(state []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.write("state.json", state); err != nil {
+		return err
+	}
+	return p.truncateLog()
+}
+
+func (p *FilePersister) SaveStateAndSnapshot(state, snapshot []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Write the snapshot first so that state never refers to a missing
+	// snapshot. A crash in between leaves the older state, and the node
+	// trusts the index recorded in the snapshot over it.
+	if err := p.write("snapshot.json", snapshot); err != nil {
+		return err
+	}
+	if err := p.write("state.json", state); err != nil {
+		return err
+	}
+	return p.truncateLog()
+}
+
+// truncateLog drops the records covered by a newly saved state. A crash
+// before it leaves them in place, so the node skips records that are older
+// than its state.
+func (p *FilePersister) truncateLog() error {
+	err := os.Remove(filepath.Join(p.dir, "wal.log"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (p *FilePersister) read(name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(p.dir, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// write replaces a file atomically by renaming a synced temporary file over it
+func (p *FilePersister) write(name string, data []byte) error {
+	tmp, err := os.CreateTemp(p.dir, name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(p.dir, name))
+}
@@ -0,0 +1,1220 @@
+package consensus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotLeader is returned when a command is proposed to a node that is not the leader
+	ErrNotLeader = errors.New("raft: not the leader")
+	// ErrNoLeader is returned when no leader could be found before the deadline
+	ErrNoLeader = errors.New("raft: no known leader")
+	// ErrLeadershipLost is returned when a proposed entry was overwritten by a new leader
+	ErrLeadershipLost = errors.New("raft: leadership lost before command committed")
+	// ErrOutcomeUnknown is returned when a proposed entry may have been
+	// committed, but the node caught up from a snapshot instead of applying
+	// it, so its result is lost
+	ErrOutcomeUnknown = errors.New("raft: command outcome unknown, the node caught up from a snapshot")
+	// ErrShutdown is returned by a node that has been shut down
+	ErrShutdown = errors.New("raft: node is shut down")
+)
+
+// remoteError rebuilds an error returned by the leader for a forwarded
+// command, so that callers can still match the errors of this package
+func remoteError(message string) error {
+	for _, err := range []error{ErrNotLeader, ErrNoLeader, ErrLeadershipLost, ErrOutcomeUnknown, ErrShutdown} {
+		if message == err.Error() {
+			return err
+		}
+	}
+	return errors.New(message)
+}
+
+// EntryType distinguishes state machine commands from internal entries
+type EntryType int
+
+const (
+	// EntryCommand carries a command for the state machine
+	EntryCommand EntryType = iota
+	// EntryNoop is appended by each new leader to commit entries from earlier terms
+	EntryNoop
+)
+
+// LogEntry is a single entry in the replicated log
+type LogEntry struct {
+	Index uint64    `json:"index"`
+	Term  uint64    `json:"term"`
+	Type  EntryType `json:"type"`
+	Data  []byte    `json:"data,omitempty"`
+}
+
+// StateMachine is the replicated state machine driven by committed log entries.
+// Apply is only ever called from a single goroutine, in log order.
+type StateMachine interface {
+	Apply(entry LogEntry) []byte
+	Snapshot() ([]byte, error)
+	Restore(snapshot []byte) error
+}
+
+// Config holds the settings of a Raft node
+type Config struct {
+	// ID identifies this node, and must appear in Peers
+	ID string
+	// Peers lists the IDs of every node in the cluster, including this one
+	Peers []string
+	// ElectionTimeout is the minimum time without a leader before starting an election
+	ElectionTimeout time.Duration
+	// HeartbeatInterval is how often the leader contacts idle followers
+	HeartbeatInterval time.Duration
+	// SnapshotThreshold is how many applied entries trigger log compaction, 0 disables it
+	SnapshotThreshold uint64
+}
+
+// DefaultConfig returns a configuration suitable for nodes on a local network
+func DefaultConfig(id string, peers []string) Config {
+	return Config{
+		ID:                id,
+		Peers:             peers,
+		ElectionTimeout:   300 * time.Millisecond,
+		HeartbeatInterval: 50 * time.Millisecond,
+		SnapshotThreshold: 1024,
+	}
+}
+
+type role int
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+func (r role) String() string {
+	switch r {
+	case leader:
+		return "leader"
+	case candidate:
+		return "candidate"
+	default:
+		return "follower"
+	}
+}
+
+// persistentState is the part of the node state that must survive restarts
+type persistentState struct {
+	Term     uint64     `json:"term"`
+	VotedFor string     `json:"voted_for"`
+	Log      []LogEntry `json:"log"`
+	// Record is the last log record the state includes
+	Record uint64 `json:"record"`
+}
+
+// logRecord is a change to the persistent state, appended to the persister's
+// log instead of saving the whole state
+type logRecord struct {
+	Seq      uint64 `json:"seq"`
+	Term     uint64 `json:"term"`
+	VotedFor string `json:"voted_for"`
+	// Entries replace the log from the index of the first one
+	Entries []LogEntry `json:"entries,omitempty"`
+}
+
+// applyResult is delivered to a proposer once its entry has been applied
+type applyResult struct {
+	result []byte
+	err    error
+}
+
+// waiter tracks a proposal waiting for its entry to be applied
+type waiter struct {
+	term uint64
+	ch   chan applyResult
+}
+
+// savedSnapshot is a snapshot as persisted, with the index and term of the
+// last entry it covers. The state is saved after the snapshot, so a crash
+// between the two leaves a snapshot ahead of the log, and only the snapshot
+// can tell how far it goes.
+type savedSnapshot struct {
+	Index uint64 `json:"last_included_index"`
+	Term  uint64 `json:"last_included_term"`
+	Data  []byte `json:"data"`
+}
+
+// pendingSnapshot is a snapshot received from the leader that the applier must restore
+type pendingSnapshot struct {
+	index uint64
+	data  []byte
+}
+
+// Node is a single member of a Raft cluster
+type Node struct {
+	mu        sync.Mutex
+	applyCond *sync.Cond
+
+	config    Config
+	transport Transport
+	persister Persister
+	fsm       StateMachine
+
+	role        role
+	currentTerm uint64
+	votedFor    string
+	leaderID    string
+	// log[0] is a sentinel holding the index and term of the last snapshot
+	log []LogEntry
+	// record numbers the records appended to the persister's log
+	record uint64
+
+	commitIndex uint64
+	lastApplied uint64
+
+	nextIndex  map[string]uint64
+	matchIndex map[string]uint64
+	triggers   map[string]chan struct{}
+
+	electionDeadline time.Time
+	waiters          map[uint64]*waiter
+	snapshot         *pendingSnapshot
+
+	shutdownCh chan struct{}
+	shutdown   bool
+}
+
+// NewNode creates a Raft node, restores any persisted state and starts it
+func NewNode(config Config, transport Transport, persister Persister, fsm StateMachine) (*Node, error) {
+	n := &Node{
+		config:     config,
+		transport:  transport,
+		persister:  persister,
+		fsm:        fsm,
+		log:        []LogEntry{{Index: 0, Term: 0, Type: EntryNoop}},
+		waiters:    make(map[uint64]*waiter),
+		shutdownCh: make(chan struct{}),
+	}
+	n.applyCond = sync.NewCond(&n.mu)
+
+	if err := n.restore(); err != nil {
+		return nil, err
+	}
+	n.resetElectionDeadline()
+
+	go n.ticker()
+	go n.applier()
+
+	return n, nil
+}
+
+// restore loads persisted state and the latest snapshot
+func (n *Node) restore() error {
+	data, err := n.persister.ReadState()
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		var state persistentState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return err
+		}
+		n.currentTerm = state.Term
+		n.votedFor = state.VotedFor
+		n.record = state.Record
+		if len(state.Log) > 0 {
+			n.log = state.Log
+		}
+	}
+
+	records, err := n.persister.ReadLog()
+	if err != nil {
+		return err
+	}
+	for _, data := range records {
+		var record logRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		// Records left over from before the state was saved are in it already
+		if record.Seq > n.record {
+			n.replay(record)
+		}
+	}
+
+	snapshot, err := n.readSnapshot()
+	if err != nil {
+		return err
+	}
+	if snapshot != nil {
+		// The entries the snapshot covers must not be applied again
+		if snapshot.Index > n.snapshotIndex() {
+			n.compactTo(snapshot.Index, snapshot.Term)
+		}
+		if err := n.fsm.Restore(snapshot.Data); err != nil {
+			return err
+		}
+	}
+
+	n.commitIndex = n.snapshotIndex()
+	n.lastApplied = n.snapshotIndex()
+	return nil
+}
+
+// Shutdown stops the node. It stops taking part in elections and replication.
+func (n *Node) Shutdown() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.stop()
+}
+
+// fail stops a node that can no longer keep its promises, such as one whose
+// disk stopped taking writes. Carrying on could acknowledge entries or cast
+// votes that a restart would forget. It must be called with n.mu held.
+func (n *Node) fail(err error) {
+	if !n.shutdown {
+		log.Printf("raft: stopping node %s: %v", n.config.ID, err)
+	}
+	n.stop()
+}
+
+// stop shuts the node down, failing every waiting proposal. It must be
+// called with n.mu held.
+func (n *Node) stop() {
+	if n.shutdown {
+		return
+	}
+	n.shutdown = true
+	close(n.shutdownCh)
+	n.applyCond.Broadcast()
+
+	for index, w := range n.waiters {
+		w.ch <- applyResult{err: ErrShutdown}
+		delete(n.waiters, index)
+	}
+}
+
+// ID returns the ID of this node
+func (n *Node) ID() string {
+	return n.config.ID
+}
+
+// Leader returns the ID of the current leader, or an empty string if unknown
+func (n *Node) Leader() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.leaderID
+}
+
+// IsLeader reports whether this node currently believes it is the leader
+func (n *Node) IsLeader() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.role == leader
+}
+
+// Term returns the current term of this node
+func (n *Node) Term() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.currentTerm
+}
+
+// Log helpers. All of them must be called with n.mu held.
+
+func (n *Node) snapshotIndex() uint64 {
+	return n.log[0].Index
+}
+
+func (n *Node) lastIndex() uint64 {
+	return n.log[len(n.log)-1].Index
+}
+
+func (n *Node) lastTerm() uint64 {
+	return n.log[len(n.log)-1].Term
+}
+
+// termAt returns the term of the entry at index, which must not be compacted
+func (n *Node) termAt(index uint64) uint64 {
+	return n.log[index-n.snapshotIndex()].Term
+}
+
+// entriesFrom returns a copy of the log starting at index
+func (n *Node) entriesFrom(index uint64) []LogEntry {
+	entries := n.log[index-n.snapshotIndex():]
+	result := make([]LogEntry, len(entries))
+	copy(result, entries)
+	return result
+}
+
+func (n *Node) quorum() int {
+	return len(n.config.Peers)/2 + 1
+}
+
+func (n *Node) encodeState() []byte {
+	data, _ := json.Marshal(persistentState{
+		Term:     n.currentTerm,
+		VotedFor: n.votedFor,
+		Log:      n.log,
+		Record:   n.record,
+	})
+	return data
+}
+
+// compactTo drops the log up to index, which a snapshot covers, keeping the
+// entries that follow it if the log agrees with the snapshot about its term
+func (n *Node) compactTo(index, term uint64) {
+	sentinel := LogEntry{Index: index, Term: term, Type: EntryNoop}
+	if index >= n.snapshotIndex() && index < n.lastIndex() && n.termAt(index) == term {
+		n.log = append([]LogEntry{sentinel}, n.entriesFrom(index+1)...)
+	} else {
+		n.log = []LogEntry{sentinel}
+	}
+}
+
+// saveSnapshot saves the state along with a snapshot covering the log up to
+// its sentinel
+func (n *Node) saveSnapshot(data []byte) error {
+	snapshot, err := json.Marshal(savedSnapshot{Index: n.snapshotIndex(), Term: n.log[0].Term, Data: data})
+	if err != nil {
+		return err
+	}
+	return n.persister.SaveStateAndSnapshot(n.encodeState(), snapshot)
+}
+
+// readSnapshot returns the saved snapshot, or nil if there is none
+func (n *Node) readSnapshot() (*savedSnapshot, error) {
+	data, err := n.persister.ReadSnapshot()
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	var snapshot savedSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// persist records the current term and vote
+func (n *Node) persist() error {
+	return n.persistEntries(nil)
+}
+
+// persistEntries records the current term and vote along with entries that
+// were just written to the log, replacing any that followed them. Only the
+// change is appended, the state is saved in full with each snapshot. A
+// node that fails to persist is stopped, and one that is stopped persists
+// nothing, so callers must not act on the change when it returns an error.
+func (n *Node) persistEntries(entries []LogEntry) error {
+	if n.shutdown {
+		return ErrShutdown
+	}
+	n.record++
+	data, _ := json.Marshal(logRecord{
+		Seq:      n.record,
+		Term:     n.currentTerm,
+		VotedFor: n.votedFor,
+		Entries:  entries,
+	})
+	if err := n.persister.AppendLog(data); err != nil {
+		n.fail(fmt.Errorf("appending to the log: %w", err))
+		return err
+	}
+	return nil
+}
+
+// replay applies a record from the persister's log on restart
+func (n *Node) replay(record logRecord) {
+	n.record = record.Seq
+	n.currentTerm = record.Term
+	n.votedFor = record.VotedFor
+
+	entries := record.Entries
+	for len(entries) > 0 && entries[0].Index <= n.snapshotIndex() {
+		entries = entries[1:]
+	}
+	if len(entries) == 0 || entries[0].Index > n.lastIndex()+1 {
+		return
+	}
+	n.log = append(n.log[:entries[0].Index-n.snapshotIndex()], entries...)
+}
+
+func (n *Node) resetElectionDeadline() {
+	timeout := n.config.ElectionTimeout
+	jitter := time.Duration(rand.Int63n(int64(timeout)))
+	n.electionDeadline = time.Now().Add(timeout + jitter)
+}
+
+// becomeFollower steps down to follower in the given term
+func (n *Node) becomeFollower(term uint64) {
+	if term > n.currentTerm {
+		n.currentTerm = term
+		n.votedFor = ""
+		n.leaderID = ""
+	}
+	n.role = follower
+	n.persist()
+}
+
+// ticker starts elections when the leader has been silent for too long
+func (n *Node) ticker() {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.shutdownCh:
+			return
+		case <-ticker.C:
+		}
+
+		n.mu.Lock()
+		if n.role != leader && time.Now().After(n.electionDeadline) {
+			n.startElection()
+		}
+		n.mu.Unlock()
+	}
+}
+
+// startElection becomes a candidate and requests votes from every peer
+func (n *Node) startElection() {
+	n.role = candidate
+	n.currentTerm++
+	n.votedFor = n.config.ID
+	n.leaderID = ""
+	if n.persist() != nil {
+		return
+	}
+	n.resetElectionDeadline()
+
+	term := n.currentTerm
+	req := &RequestVoteRequest{
+		Term:         term,
+		CandidateID:  n.config.ID,
+		LastLogIndex: n.lastIndex(),
+		LastLogTerm:  n.lastTerm(),
+	}
+
+	votes := 1
+	if votes >= n.quorum() {
+		n.becomeLeader()
+		return
+	}
+
+	for _, peer := range n.config.Peers {
+		if peer == n.config.ID {
+			continue
+		}
+		go func(peer string) {
+			ctx, cancel := context.WithTimeout(context.Background(), n.config.ElectionTimeout)
+			defer cancel()
+
+			resp, err := n.transport.RequestVote(ctx, peer, req)
+			if err != nil {
+				return
+			}
+
+			n.mu.Lock()
+			defer n.mu.Unlock()
+
+			if resp.Term > n.currentTerm {
+				n.becomeFollower(resp.Term)
+				return
+			}
+			if n.role != candidate || n.currentTerm != term || !resp.VoteGranted {
+				return
+			}
+			votes++
+			if votes >= n.quorum() {
+				n.becomeLeader()
+			}
+		}(peer)
+	}
+}
+
+// becomeLeader takes over replication for the current term
+func (n *Node) becomeLeader() {
+	n.role = leader
+	n.leaderID = n.config.ID
+	n.nextIndex = make(map[string]uint64)
+	n.matchIndex = make(map[string]uint64)
+	n.triggers = make(map[string]chan struct{})
+
+	// A no-op entry lets the new leader commit entries left over from earlier terms
+	noop := LogEntry{Index: n.lastIndex() + 1, Term: n.currentTerm, Type: EntryNoop}
+	n.log = append(n.log, noop)
+	if n.persistEntries([]LogEntry{noop}) != nil {
+		return
+	}
+
+	for _, peer := range n.config.Peers {
+		if peer == n.config.ID {
+			continue
+		}
+		n.nextIndex[peer] = n.lastIndex()
+		n.matchIndex[peer] = 0
+		trigger := make(chan struct{}, 1)
+		n.triggers[peer] = trigger
+		go n.replicate(peer, n.currentTerm, trigger)
+	}
+	n.advanceCommitIndex()
+}
+
+// triggerReplication wakes every replicator so new entries are sent immediately
+func (n *Node) triggerReplication() {
+	for _, trigger := range n.triggers {
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// replicate keeps a single follower up to date for as long as this node leads the given term
+func (n *Node) replicate(peer string, term uint64, trigger chan struct{}) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-n.shutdownCh:
+			return
+		case <-trigger:
+		case <-timer.C:
+		}
+
+		more := n.replicateOnce(peer, term)
+
+		n.mu.Lock()
+		stillLeader := n.role == leader && n.currentTerm == term
+		n.mu.Unlock()
+		if !stillLeader {
+			return
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if more {
+			timer.Reset(0)
+		} else {
+			timer.Reset(n.config.HeartbeatInterval)
+		}
+	}
+}
+
+// replicateOnce sends a single AppendEntries or InstallSnapshot RPC to peer.
+// It reports whether the follower still needs more entries.
+func (n *Node) replicateOnce(peer string, term uint64) bool {
+	n.mu.Lock()
+	if n.role != leader || n.currentTerm != term {
+		n.mu.Unlock()
+		return false
+	}
+
+	next := n.nextIndex[peer]
+	if next <= n.snapshotIndex() {
+		n.mu.Unlock()
+		return n.sendSnapshot(peer, term)
+	}
+
+	req := &AppendEntriesRequest{
+		Term:         term,
+		LeaderID:     n.config.ID,
+		PrevLogIndex: next - 1,
+		PrevLogTerm:  n.termAt(next - 1),
+		Entries:      n.entriesFrom(next),
+		LeaderCommit: n.commitIndex,
+	}
+	n.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.ElectionTimeout)
+	defer cancel()
+
+	resp, err := n.transport.AppendEntries(ctx, peer, req)
+	if err != nil {
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if resp.Term > n.currentTerm {
+		n.becomeFollower(resp.Term)
+		return false
+	}
+	if n.role != leader || n.currentTerm != term {
+		return false
+	}
+
+	if resp.Success {
+		match := req.PrevLogIndex + uint64(len(req.Entries))
+		if match > n.matchIndex[peer] {
+			n.matchIndex[peer] = match
+		}
+		n.nextIndex[peer] = n.matchIndex[peer] + 1
+		n.advanceCommitIndex()
+		return n.nextIndex[peer] <= n.lastIndex()
+	}
+
+	// Back up to the follower's first conflicting index and retry straight away
+	next = resp.ConflictIndex
+	if next < 1 {
+		next = 1
+	}
+	if next > n.lastIndex()+1 {
+		next = n.lastIndex() + 1
+	}
+	n.nextIndex[peer] = next
+	return true
+}
+
+// sendSnapshot sends the latest snapshot to a follower that is behind the start of the log
+func (n *Node) sendSnapshot(peer string, term uint64) bool {
+	// The snapshot is read under the lock that compaction holds, and is sent
+	// with the index and term it was saved with
+	n.mu.Lock()
+	snapshot, err := n.readSnapshot()
+	n.mu.Unlock()
+	if err != nil || snapshot == nil {
+		return false
+	}
+	req := &InstallSnapshotRequest{
+		Term:              term,
+		LeaderID:          n.config.ID,
+		LastIncludedIndex: snapshot.Index,
+		LastIncludedTerm:  snapshot.Term,
+		Data:              snapshot.Data,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.ElectionTimeout)
+	defer cancel()
+
+	resp, err := n.transport.InstallSnapshot(ctx, peer, req)
+	if err != nil {
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if resp.Term > n.currentTerm {
+		n.becomeFollower(resp.Term)
+		return false
+	}
+	if n.role != leader || n.currentTerm != term || !resp.Success {
+		return false
+	}
+
+	if req.LastIncludedIndex > n.matchIndex[peer] {
+		n.matchIndex[peer] = req.LastIncludedIndex
+	}
+	n.nextIndex[peer] = n.matchIndex[peer] + 1
+	n.advanceCommitIndex()
+	return n.nextIndex[peer] <= n.lastIndex()
+}
+
+// advanceCommitIndex commits the highest entry of the current term stored on a majority
+func (n *Node) advanceCommitIndex() {
+	for index := n.lastIndex(); index > n.commitIndex && index > n.snapshotIndex(); index-- {
+		// Only entries from the current term are committed by counting replicas
+		if n.termAt(index) != n.currentTerm {
+			break
+		}
+
+		count := 1
+		for _, match := range n.matchIndex {
+			if match >= index {
+				count++
+			}
+		}
+		if count >= n.quorum() {
+			n.commitIndex = index
+			n.applyCond.Broadcast()
+			return
+		}
+	}
+}
+
+// HandleRequestVote serves a RequestVote RPC
+func (n *Node) HandleRequestVote(req *RequestVoteRequest) *RequestVoteResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if req.Term < n.currentTerm {
+		return &RequestVoteResponse{Term: n.currentTerm}
+	}
+	if req.Term > n.currentTerm {
+		n.becomeFollower(req.Term)
+	}
+	if n.shutdown {
+		return &RequestVoteResponse{Term: n.currentTerm}
+	}
+
+	upToDate := req.LastLogTerm > n.lastTerm() ||
+		(req.LastLogTerm == n.lastTerm() && req.LastLogIndex >= n.lastIndex())
+
+	granted := false
+	if (n.votedFor == "" || n.votedFor == req.CandidateID) && upToDate {
+		n.votedFor = req.CandidateID
+		// A vote that is not on disk could be cast again after a restart
+		if n.persist() == nil {
+			granted = true
+			n.resetElectionDeadline()
+		}
+	}
+
+	return &RequestVoteResponse{Term: n.currentTerm, VoteGranted: granted}
+}
+
+// HandleAppendEntries serves an AppendEntries RPC
+func (n *Node) HandleAppendEntries(req *AppendEntriesRequest) *AppendEntriesResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if req.Term < n.currentTerm {
+		return &AppendEntriesResponse{Term: n.currentTerm}
+	}
+	if req.Term > n.currentTerm || n.role != follower {
+		n.becomeFollower(req.Term)
+	}
+	if n.shutdown {
+		return &AppendEntriesResponse{Term: n.currentTerm}
+	}
+	n.leaderID = req.LeaderID
+	n.resetElectionDeadline()
+
+	prevIndex, prevTerm, entries := req.PrevLogIndex, req.PrevLogTerm, req.Entries
+
+	// Skip entries that are already covered by our snapshot
+	if prevIndex < n.snapshotIndex() {
+		skip := n.snapshotIndex() - prevIndex
+		if skip >= uint64(len(entries)) {
+			return &AppendEntriesResponse{Term: n.currentTerm, Success: true}
+		}
+		entries = entries[skip:]
+		prevIndex, prevTerm = n.snapshotIndex(), n.log[0].Term
+	}
+
+	if prevIndex > n.lastIndex() {
+		return &AppendEntriesResponse{Term: n.currentTerm, ConflictIndex: n.lastIndex() + 1}
+	}
+	if n.termAt(prevIndex) != prevTerm {
+		// Report the first index of the conflicting term so the leader can skip it entirely
+		conflictTerm := n.termAt(prevIndex)
+		conflict := prevIndex
+		for conflict > n.snapshotIndex()+1 && n.termAt(conflict-1) == conflictTerm {
+			conflict--
+		}
+		return &AppendEntriesResponse{Term: n.currentTerm, ConflictIndex: conflict}
+	}
+
+	for i, entry := range entries {
+		if entry.Index <= n.lastIndex() {
+			if n.termAt(entry.Index) == entry.Term {
+				continue
+			}
+			// Drop the conflicting entry and everything after it
+			n.log = n.log[:entry.Index-n.snapshotIndex()]
+		}
+		n.log = append(n.log, entries[i:]...)
+		// Entries are only acknowledged once they are on disk
+		if n.persistEntries(entries[i:]) != nil {
+			return &AppendEntriesResponse{Term: n.currentTerm}
+		}
+		break
+	}
+
+	// Only entries this request matched are known to be the leader's, and a
+	// stale request must never move the commit index back
+	lastNew := prevIndex + uint64(len(entries))
+	if commit := min(req.LeaderCommit, lastNew); commit > n.commitIndex {
+		n.commitIndex = commit
+		n.applyCond.Broadcast()
+	}
+
+	return &AppendEntriesResponse{Term: n.currentTerm, Success: true}
+}
+
+// HandleInstallSnapshot serves an InstallSnapshot RPC
+func (n *Node) HandleInstallSnapshot(req *InstallSnapshotRequest) *InstallSnapshotResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if req.Term < n.currentTerm {
+		return &InstallSnapshotResponse{Term: n.currentTerm}
+	}
+	if req.Term > n.currentTerm || n.role != follower {
+		n.becomeFollower(req.Term)
+	}
+	if n.shutdown {
+		return &InstallSnapshotResponse{Term: n.currentTerm}
+	}
+	n.leaderID = req.LeaderID
+	n.resetElectionDeadline()
+
+	// Ignore snapshots that do not move us forward
+	if req.LastIncludedIndex <= n.commitIndex {
+		return &InstallSnapshotResponse{Term: n.currentTerm, Success: true}
+	}
+
+	n.compactTo(req.LastIncludedIndex, req.LastIncludedTerm)
+	n.commitIndex = req.LastIncludedIndex
+	if err := n.saveSnapshot(req.Data); err != nil {
+		n.fail(fmt.Errorf("saving a snapshot: %w", err))
+		return &InstallSnapshotResponse{Term: n.currentTerm}
+	}
+	n.snapshot = &pendingSnapshot{index: req.LastIncludedIndex, data: req.Data}
+	n.applyCond.Broadcast()
+
+	return &InstallSnapshotResponse{Term: n.currentTerm, Success: true}
+}
+
+// applier feeds committed entries to the state machine in log order
+func (n *Node) applier() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for {
+		for !n.shutdown && n.snapshot == nil && n.lastApplied >= n.commitIndex {
+			n.applyCond.Wait()
+		}
+		if n.shutdown {
+			return
+		}
+
+		if snap := n.snapshot; snap != nil {
+			n.snapshot = nil
+			n.mu.Unlock()
+			err := n.fsm.Restore(snap.data)
+			n.mu.Lock()
+			if err != nil {
+				// The state machine no longer matches the log
+				n.fail(fmt.Errorf("restoring a snapshot: %w", err))
+				return
+			}
+
+			if snap.index > n.lastApplied {
+				n.lastApplied = snap.index
+			}
+			// The entries of these waiters are covered by the snapshot, but
+			// whether they are the ones that were proposed is not known
+			for index, w := range n.waiters {
+				if index <= snap.index {
+					w.ch <- applyResult{err: ErrOutcomeUnknown}
+					delete(n.waiters, index)
+				}
+			}
+			n.applyCond.Broadcast()
+			continue
+		}
+
+		entries := n.entriesFrom(n.lastApplied + 1)
+		entries = entries[:n.commitIndex-n.lastApplied]
+		n.mu.Unlock()
+
+		for _, entry := range entries {
+			var result []byte
+			if entry.Type == EntryCommand {
+				result = n.fsm.Apply(entry)
+			}
+
+			n.mu.Lock()
+			n.lastApplied = entry.Index
+			if w, ok := n.waiters[entry.Index]; ok {
+				if w.term == entry.Term {
+					w.ch <- applyResult{result: result}
+				} else {
+					w.ch <- applyResult{err: ErrLeadershipLost}
+				}
+				delete(n.waiters, entry.Index)
+			}
+			n.applyCond.Broadcast()
+			n.mu.Unlock()
+		}
+
+		n.maybeSnapshot()
+		n.mu.Lock()
+	}
+}
+
+// maybeSnapshot compacts the log once enough entries have been applied.
+// It runs on the applier goroutine so the snapshot matches lastApplied.
+func (n *Node) maybeSnapshot() {
+	n.mu.Lock()
+	threshold := n.config.SnapshotThreshold
+	due := threshold > 0 && n.lastApplied-n.snapshotIndex() >= threshold
+	index := n.lastApplied
+	n.mu.Unlock()
+
+	if !due {
+		return
+	}
+
+	data, err := n.fsm.Snapshot()
+	if err != nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if index <= n.snapshotIndex() {
+		return
+	}
+	n.compactTo(index, n.termAt(index))
+	if err := n.saveSnapshot(data); err != nil {
+		n.fail(fmt.Errorf("saving a snapshot: %w", err))
+	}
+}
+
+// Propose appends a command to the log and waits for it to be applied.
+// It returns ErrNotLeader if this node is not the leader.
+func (n *Node) Propose(ctx context.Context, command []byte) ([]byte, error) {
+	n.mu.Lock()
+	if n.shutdown {
+		n.mu.Unlock()
+		return nil, ErrShutdown
+	}
+	if n.role != leader {
+		n.mu.Unlock()
+		return nil, ErrNotLeader
+	}
+
+	entry := LogEntry{
+		Index: n.lastIndex() + 1,
+		Term:  n.currentTerm,
+		Type:  EntryCommand,
+		Data:  command,
+	}
+	n.log = append(n.log, entry)
+	if n.persistEntries([]LogEntry{entry}) != nil {
+		// The node has stopped
+		n.mu.Unlock()
+		return nil, ErrShutdown
+	}
+
+	w := &waiter{term: entry.Term, ch: make(chan applyResult, 1)}
+	n.waiters[entry.Index] = w
+	n.advanceCommitIndex()
+	n.triggerReplication()
+	n.mu.Unlock()
+
+	select {
+	case res := <-w.ch:
+		return res.result, res.err
+	case <-ctx.Done():
+		n.mu.Lock()
+		delete(n.waiters, entry.Index)
+		n.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// Apply replicates a command through the cluster, forwarding it to the
+// leader when this node is a follower, and returns the state machine result.
+func (n *Node) Apply(ctx context.Context, command []byte) ([]byte, error) {
+	for {
+		result, err := n.Propose(ctx, command)
+		if err != ErrNotLeader {
+			return result, err
+		}
+
+		leaderID := n.Leader()
+		if leaderID == "" || leaderID == n.config.ID {
+			if err := n.waitForLeader(ctx); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		resp, err := n.transport.Forward(ctx, leaderID, &ForwardRequest{Command: command})
+		if err != nil {
+			// The command may have reached the leader, so it is not safe to retry
+			return nil, err
+		}
+		if resp.Error == ErrNotLeader.Error() {
+			if err := n.waitForLeader(ctx); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.Error != "" {
+			return nil, remoteError(resp.Error)
+		}
+		return resp.Result, nil
+	}
+}
+
+// waitForLeader pauses briefly so that an election can complete
+func (n *Node) waitForLeader(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ErrNoLeader
+	case <-n.shutdownCh:
+		return ErrShutdown
+	case <-time.After(n.config.HeartbeatInterval):
+		return nil
+	}
+}
+
+// ReadBarrier waits until this node has applied every entry committed before
+// the call, so that a subsequent local read is linearizable.
+func (n *Node) ReadBarrier(ctx context.Context) error {
+	var index uint64
+	for {
+		var err error
+		index, err = n.readIndex(ctx)
+		if err == nil {
+			break
+		}
+		if err != ErrNotLeader {
+			return err
+		}
+
+		leaderID := n.Leader()
+		if leaderID == "" || leaderID == n.config.ID {
+			if err := n.waitForLeader(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+
+		resp, err := n.transport.ReadIndex(ctx, leaderID)
+		if err == nil && resp.Error == "" {
+			index = resp.Index
+			break
+		}
+		if err := n.waitForLeader(ctx); err != nil {
+			return err
+		}
+	}
+
+	return n.waitApplied(ctx, index)
+}
+
+// waitApplied blocks until the state machine has applied index
+func (n *Node) waitApplied(ctx context.Context, index uint64) error {
+	stop := context.AfterFunc(ctx, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		n.applyCond.Broadcast()
+	})
+	defer stop()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for n.lastApplied < index {
+		if n.shutdown {
+			return ErrShutdown
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		n.applyCond.Wait()
+	}
+	return nil
+}
+
+// readIndex returns the commit index after confirming with a majority that
+// this node is still the leader
+func (n *Node) readIndex(ctx context.Context) (uint64, error) {
+	n.mu.Lock()
+	if n.role != leader {
+		n.mu.Unlock()
+		return 0, ErrNotLeader
+	}
+	term := n.currentTerm
+	n.mu.Unlock()
+
+	// Wait for the no-op of this term to commit so commitIndex is up to date
+	for {
+		n.mu.Lock()
+		if n.role != leader || n.currentTerm != term {
+			n.mu.Unlock()
+			return 0, ErrNotLeader
+		}
+		if n.commitIndex > n.snapshotIndex() && n.termAt(n.commitIndex) == term ||
+			n.commitIndex == n.snapshotIndex() && n.log[0].Term == term {
+			break
+		}
+		n.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(n.config.HeartbeatInterval / 5):
+		}
+	}
+	index := n.commitIndex
+	n.mu.Unlock()
+
+	if !n.confirmLeadership(ctx, term) {
+		return 0, ErrNotLeader
+	}
+	return index, nil
+}
+
+// confirmLeadership checks that a majority still recognises this node as leader of term
+func (n *Node) confirmLeadership(ctx context.Context, term uint64) bool {
+	req := &AppendEntriesRequest{Term: term, LeaderID: n.config.ID}
+
+	acks := make(chan bool, len(n.config.Peers))
+	for _, peer := range n.config.Peers {
+		if peer == n.config.ID {
+			continue
+		}
+		go func(peer string) {
+			ctx, cancel := context.WithTimeout(ctx, n.config.ElectionTimeout)
+			defer cancel()
+
+			resp, err := n.transport.AppendEntries(ctx, peer, req)
+			if err != nil {
+				acks <- false
+				return
+			}
+			if resp.Term > term {
+				n.mu.Lock()
+				if resp.Term > n.currentTerm {
+					n.becomeFollower(resp.Term)
+				}
+				n.mu.Unlock()
+			}
+			acks <- resp.Term == term
+		}(peer)
+	}
+
+	count := 1
+	for i := 0; i < len(n.config.Peers)-1 && count < n.quorum(); i++ {
+		if <-acks {
+			count++
+		}
+	}
+	return count >= n.quorum()
+}
+
+// HandleForward serves a command forwarded by a follower
+func (n *Node) HandleForward(ctx context.Context, req *ForwardRequest) *ForwardResponse {
+	result, err := n.Propose(ctx, req.Command)
+	if err != nil {
+		return &ForwardResponse{Error: err.Error()}
+	}
+	return &ForwardResponse{Result: result}
+}
+
+// HandleReadIndex serves a read index request from a follower
+func (n *Node) HandleReadIndex(ctx context.Context) *ReadIndexResponse {
+	index, err := n.readIndex(ctx)
+	if err != nil {
+		return &ReadIndexResponse{Error: err.Error()}
+	}
+	return &ReadIndexResponse{Index: index}
+}
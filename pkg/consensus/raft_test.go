@@ -0,0 +1,591 @@
+package consensus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
+)
+
+// testCluster is a set of RaftStores connected through an in-memory network
+type testCluster struct {
+	network *InmemNetwork
+	stores  map[string]*RaftStore
+}
+
+func newTestCluster(t *testing.T, size int, snapshotThreshold uint64) *testCluster {
+	t.Helper()
+
+	ids := make([]string, size)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("node-%d", i+1)
+	}
+
+	c := &testCluster{
+		network: NewInmemNetwork(),
+		stores:  make(map[string]*RaftStore),
+	}
+	for _, id := range ids {
+		config := DefaultConfig(id, ids)
+		config.ElectionTimeout = 100 * time.Millisecond
+		config.HeartbeatInterval = 20 * time.Millisecond
+		config.SnapshotThreshold = snapshotThreshold
+
+		store, err := NewRaftStore(config, c.network.Transport(id), NewMemoryPersister())
+		if err != nil {
+			t.Fatalf("Failed to create store %s: %v", id, err)
+		}
+		c.network.Register(id, store.Node())
+		c.stores[id] = store
+	}
+
+	t.Cleanup(func() {
+		for _, store := range c.stores {
+			store.Close()
+		}
+	})
+	return c
+}
+
+// waitForLeader returns the ID of the leader among the connected nodes
+func (c *testCluster) waitForLeader(t *testing.T, exclude string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for id, store := range c.stores {
+			if id != exclude && store.Node().IsLeader() {
+				return id
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("No leader elected")
+	return ""
+}
+
+func newTestAuction() auction.AuctionItem {
+	return auction.AuctionItem{
+		Name:       "Test Item",
+		MinimumBid: auction.NewMoney(1000, "USD"),
+		ExpiryTime: time.Now().Add(time.Hour),
+	}
+}
+
+func TestRaftStoreReplicatesAcrossNodes(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCluster(t, 3, 0)
+	c.waitForLeader(t, "")
+
+	item, err := c.stores["node-1"].CreateAuction(ctx, newTestAuction())
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+
+	// Place increasing bids through every node, leader or not
+	price := auction.NewMoney(1000, "USD")
+	for i := 0; i < 9; i++ {
+		store := c.stores[fmt.Sprintf("node-%d", i%3+1)]
+		price.Amount += 500
+		bid := auction.Bid{ParticipantID: fmt.Sprintf("p-%d", i), AuctionItemID: item.ID, BidPrice: price}
+		if _, err := store.PlaceBid(ctx, bid); err != nil {
+			t.Fatalf("Failed to place bid %d: %v", i, err)
+		}
+	}
+
+	// A lower bid must be rejected by the replicated state machine, and the
+	// rejection keeps its next minimum bid through forwarding
+	_, err = c.stores["node-2"].PlaceBid(ctx, auction.Bid{ParticipantID: "late", AuctionItemID: item.ID, BidPrice: auction.NewMoney(1200, "USD")})
+	var tooLow *storage.BidTooLowError
+	if !errors.As(err, &tooLow) || tooLow.NextMinimumBid != price.Add(auction.SmallestUnit("USD")) {
+		t.Fatalf("Expected lower bid to be rejected with the next minimum bid, got %v", err)
+	}
+
+	// Other store errors still match their sentinel after crossing the log
+	_, err = c.stores["node-3"].AcceptPrice(ctx, item.ID, "late")
+	if !errors.Is(err, storage.ErrNotDutch) {
+		t.Fatalf("Expected accepting an english auction to fail with ErrNotDutch, got %v", err)
+	}
+
+	for id, store := range c.stores {
+		history, err := store.GetBidHistory(ctx, item.ID)
+		if err != nil {
+			t.Fatalf("Failed to get history from %s: %v", id, err)
+		}
+		if len(history) != 9 {
+			t.Fatalf("Node %s has %d bids, expected 9", id, len(history))
+		}
+		highest, err := store.GetHighestBid(ctx, item.ID)
+		if err != nil {
+			t.Fatalf("Failed to get highest bid from %s: %v", id, err)
+		}
+		if highest.BidPrice != price || highest.ID != history[8].ID {
+			t.Fatalf("Node %s reports highest bid %v, expected %v", id, highest, price)
+		}
+
+		// Every node numbers the events the same way
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		events, err := store.Subscribe(ctx, item.ID, 0)
+		if err != nil {
+			cancel()
+			t.Fatalf("Failed to subscribe on %s: %v", id, err)
+		}
+		for i := range history {
+			event := <-events
+			if event.Sequence != uint64(i+1) || event.Bid == nil || event.Bid.ID != history[i].ID {
+				cancel()
+				t.Fatalf("Node %s reports event %+v, expected bid %s as event %d", id, event, history[i].ID, i+1)
+			}
+		}
+		cancel()
+	}
+}
+
+func TestRaftStoreSurvivesLeaderFailure(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCluster(t, 3, 0)
+	oldLeader := c.waitForLeader(t, "")
+
+	item, err := c.stores[oldLeader].CreateAuction(ctx, newTestAuction())
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+
+	c.network.Disconnect(oldLeader)
+	newLeader := c.waitForLeader(t, oldLeader)
+
+	bid := auction.Bid{ParticipantID: "p-1", AuctionItemID: item.ID, BidPrice: auction.NewMoney(2000, "USD")}
+	if _, err := c.stores[newLeader].PlaceBid(ctx, bid); err != nil {
+		t.Fatalf("Failed to place bid after leader failure: %v", err)
+	}
+
+	// The old leader catches up once it rejoins the cluster
+	c.network.Reconnect(oldLeader)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		highest, err := c.stores[oldLeader].GetHighestBid(ctx, item.ID)
+		if err == nil && highest.BidPrice.Amount == 2000 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Old leader did not catch up: %v, %v", highest, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRaftStoreCatchesUpFromSnapshot(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCluster(t, 3, 5)
+	leaderID := c.waitForLeader(t, "")
+
+	// Pick a follower and cut it off while the log is compacted
+	var lagging string
+	for id := range c.stores {
+		if id != leaderID {
+			lagging = id
+			break
+		}
+	}
+	c.network.Disconnect(lagging)
+
+	item, err := c.stores[leaderID].CreateAuction(ctx, newTestAuction())
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+	for i := 1; i <= 20; i++ {
+		bid := auction.Bid{ParticipantID: "p", AuctionItemID: item.ID, BidPrice: auction.NewMoney(int64(1000+100*i), "USD")}
+		if _, err := c.stores[leaderID].PlaceBid(ctx, bid); err != nil {
+			t.Fatalf("Failed to place bid %d: %v", i, err)
+		}
+	}
+
+	c.network.Reconnect(lagging)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		history, err := c.stores[lagging].GetBidHistory(ctx, item.ID)
+		if err == nil && len(history) == 20 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Lagging node did not catch up: %d bids, %v", len(history), err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRaftStoreReplaysIdempotentWrites(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCluster(t, 3, 0)
+	c.waitForLeader(t, "")
+
+	// A retry through another node returns the auction the first one created
+	createKey := storage.IdempotencyKey{ParticipantID: "sam", Key: "create-1", Fingerprint: "lamp"}
+	item, err := c.stores["node-1"].CreateAuctionIdempotent(ctx, createKey, newTestAuction())
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+	retried, err := c.stores["node-2"].CreateAuctionIdempotent(ctx, createKey, newTestAuction())
+	if err != nil || retried.ID != item.ID {
+		t.Fatalf("Expected the retry to return auction %s, got %+v, %v", item.ID, retried, err)
+	}
+
+	bidKey := storage.IdempotencyKey{ParticipantID: "p-1", Key: "bid-1", Fingerprint: "15"}
+	bid := auction.Bid{ParticipantID: "p-1", AuctionItemID: item.ID, BidPrice: auction.NewMoney(1500, "USD")}
+	placed, err := c.stores["node-2"].PlaceBidIdempotent(ctx, bidKey, bid)
+	if err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+	if again, err := c.stores["node-3"].PlaceBidIdempotent(ctx, bidKey, bid); err != nil || again.ID != placed.ID {
+		t.Fatalf("Expected the retry to return bid %s, got %+v, %v", placed.ID, again, err)
+	}
+
+	bidKey.Fingerprint = "20"
+	if _, err := c.stores["node-1"].PlaceBidIdempotent(ctx, bidKey, bid); !errors.Is(err, storage.ErrIdempotencyKeyReused) {
+		t.Errorf("Expected a different request to fail with ErrIdempotencyKeyReused, got %v", err)
+	}
+
+	for id, store := range c.stores {
+		if history, err := store.GetBidHistory(ctx, item.ID); err != nil || len(history) != 1 {
+			t.Errorf("Node %s has %d bids, expected 1 (%v)", id, len(history), err)
+		}
+	}
+}
+
+func TestRaftStoreHonorsContext(t *testing.T) {
+	c := newTestCluster(t, 3, 0)
+	c.waitForLeader(t, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for id, store := range c.stores {
+		if _, err := store.CreateAuction(ctx, newTestAuction()); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected node %s to give up on the write, got %v", id, err)
+		}
+		// A read that cannot confirm the leader in time reports there is none
+		if _, err := store.ListAuctions(ctx, auction.ListQuery{}); !errors.Is(err, context.Canceled) && !errors.Is(err, ErrNoLeader) {
+			t.Errorf("Expected node %s to give up on the read, got %v", id, err)
+		}
+	}
+}
+
+func TestRaftStoreRestartsFromDisk(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	network := NewInmemNetwork()
+	open := func() *RaftStore {
+		t.Helper()
+		persister, err := NewFilePersister(dir)
+		if err != nil {
+			t.Fatalf("Failed to open persister: %v", err)
+		}
+		config := DefaultConfig("node-1", []string{"node-1"})
+		config.ElectionTimeout = 50 * time.Millisecond
+		config.SnapshotThreshold = 8
+		store, err := NewRaftStore(config, network.Transport("node-1"), persister)
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		network.Register("node-1", store.Node())
+		return store
+	}
+
+	// Enough writes to compact the log once, with more appended after
+	store := open()
+	item, err := store.CreateAuction(ctx, newTestAuction())
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+	for i := 1; i <= 12; i++ {
+		bid := auction.Bid{ParticipantID: "p", AuctionItemID: item.ID, BidPrice: auction.NewMoney(int64(1000+100*i), "USD")}
+		if _, err := store.PlaceBid(ctx, bid); err != nil {
+			t.Fatalf("Failed to place bid %d: %v", i, err)
+		}
+	}
+	store.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "wal.log")); err != nil {
+		t.Fatalf("Expected entries after the snapshot in the log: %v", err)
+	}
+
+	store = open()
+	defer store.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		history, err := store.GetBidHistory(ctx, item.ID)
+		if err == nil && len(history) == 12 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Restarted node has %d bids, expected 12 (%v)", len(history), err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRaftStoreReportsUnknownOutcome(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCluster(t, 3, 5)
+	oldLeader := c.waitForLeader(t, "")
+
+	// A write to a leader cut off from the cluster cannot commit
+	c.network.Disconnect(oldLeader)
+	result := make(chan error, 1)
+	go func() {
+		_, err := c.stores[oldLeader].CreateAuction(ctx, newTestAuction())
+		result <- err
+	}()
+
+	// The rest of the cluster moves on and compacts its log
+	newLeader := c.waitForLeader(t, oldLeader)
+	item, err := c.stores[newLeader].CreateAuction(ctx, newTestAuction())
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+	for i := 1; i <= 10; i++ {
+		bid := auction.Bid{ParticipantID: "p", AuctionItemID: item.ID, BidPrice: auction.NewMoney(int64(1000+100*i), "USD")}
+		if _, err := c.stores[newLeader].PlaceBid(ctx, bid); err != nil {
+			t.Fatalf("Failed to place bid %d: %v", i, err)
+		}
+	}
+
+	// The old leader catches up from a snapshot, which says nothing of
+	// whether its write was the entry at its index
+	c.network.Reconnect(oldLeader)
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrOutcomeUnknown) {
+			t.Errorf("Expected ErrOutcomeUnknown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The write to the old leader never finished")
+	}
+}
+
+// failingPersister stops taking writes once failing is set, like a full disk
+type failingPersister struct {
+	*MemoryPersister
+	failing atomic.Bool
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (p *failingPersister) AppendLog(record []byte) error {
+	if p.failing.Load() {
+		return errDiskFull
+	}
+	return p.MemoryPersister.AppendLog(record)
+}
+
+func (p *failingPersister) SaveStateAndSnapshot(state, snapshot []byte) error {
+	if p.failing.Load() {
+		return errDiskFull
+	}
+	return p.MemoryPersister.SaveStateAndSnapshot(state, snapshot)
+}
+
+// testFSM applies nothing and fails to restore snapshots when asked to
+type testFSM struct {
+	restoreErr error
+}
+
+func (f *testFSM) Apply(entry LogEntry) []byte   { return nil }
+func (f *testFSM) Snapshot() ([]byte, error)     { return []byte("{}"), nil }
+func (f *testFSM) Restore(snapshot []byte) error { return f.restoreErr }
+
+// newTestNode starts a follower that never calls an election by itself
+func newTestNode(t *testing.T, persister Persister, fsm StateMachine) *Node {
+	t.Helper()
+	config := DefaultConfig("node-1", []string{"node-1", "node-2", "node-3"})
+	config.ElectionTimeout = time.Hour
+	node, err := NewNode(config, NewInmemNetwork().Transport("node-1"), persister, fsm)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	t.Cleanup(node.Shutdown)
+	return node
+}
+
+// waitStopped waits for a node to refuse proposals because it has stopped
+func waitStopped(t *testing.T, node *Node) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := node.Propose(context.Background(), []byte("{}"))
+		if errors.Is(err, ErrShutdown) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the node to stop, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNodeStopsWhenPersistingFails(t *testing.T) {
+	persister := &failingPersister{MemoryPersister: NewMemoryPersister()}
+	node := newTestNode(t, persister, &testFSM{})
+
+	entry := func(index uint64) []LogEntry {
+		return []LogEntry{{Index: index, Term: 1, Type: EntryNoop}}
+	}
+	resp := node.HandleAppendEntries(&AppendEntriesRequest{Term: 1, LeaderID: "node-2", Entries: entry(1)})
+	if !resp.Success {
+		t.Fatal("Expected entries to be accepted while the disk works")
+	}
+
+	// Entries that cannot be written are not acknowledged, and the node
+	// stops rather than voting or acknowledging anything else
+	persister.failing.Store(true)
+	resp = node.HandleAppendEntries(&AppendEntriesRequest{Term: 1, LeaderID: "node-2", PrevLogIndex: 1, PrevLogTerm: 1, Entries: entry(2)})
+	if resp.Success {
+		t.Error("Expected entries that were not persisted to be refused")
+	}
+	waitStopped(t, node)
+
+	persister.failing.Store(false)
+	vote := node.HandleRequestVote(&RequestVoteRequest{Term: 2, CandidateID: "node-3", LastLogIndex: 2, LastLogTerm: 1})
+	if vote.VoteGranted {
+		t.Error("Expected a stopped node not to vote")
+	}
+	resp = node.HandleAppendEntries(&AppendEntriesRequest{Term: 2, LeaderID: "node-3", PrevLogIndex: 1, PrevLogTerm: 1})
+	if resp.Success {
+		t.Error("Expected a stopped node not to acknowledge entries")
+	}
+
+	// A vote that cannot be written is not granted either
+	persister = &failingPersister{MemoryPersister: NewMemoryPersister()}
+	node = newTestNode(t, persister, &testFSM{})
+	persister.failing.Store(true)
+	vote = node.HandleRequestVote(&RequestVoteRequest{Term: 1, CandidateID: "node-3"})
+	if vote.VoteGranted {
+		t.Error("Expected a vote that was not persisted to be refused")
+	}
+
+	// So is a snapshot that cannot be saved
+	persister = &failingPersister{MemoryPersister: NewMemoryPersister()}
+	node = newTestNode(t, persister, &testFSM{})
+	node.HandleAppendEntries(&AppendEntriesRequest{Term: 1, LeaderID: "node-2"})
+	persister.failing.Store(true)
+	installed := node.HandleInstallSnapshot(&InstallSnapshotRequest{Term: 1, LeaderID: "node-2", LastIncludedIndex: 5, LastIncludedTerm: 1, Data: []byte("{}")})
+	if installed.Success {
+		t.Error("Expected a snapshot that was not saved to be refused")
+	}
+	waitStopped(t, node)
+}
+
+func TestNodeStopsWhenSnapshotRestoreFails(t *testing.T) {
+	node := newTestNode(t, NewMemoryPersister(), &testFSM{restoreErr: errors.New("corrupt snapshot")})
+
+	resp := node.HandleInstallSnapshot(&InstallSnapshotRequest{Term: 1, LeaderID: "node-2", LastIncludedIndex: 5, LastIncludedTerm: 1, Data: []byte("{}")})
+	if !resp.Success {
+		t.Fatal("Expected the snapshot to be saved")
+	}
+	// The state machine no longer matches the log, so the node must not
+	// serve from it
+	waitStopped(t, node)
+}
+
+// tornPersister saves snapshots but crashes before saving the state that
+// goes with them
+type tornPersister struct {
+	*MemoryPersister
+}
+
+func (p *tornPersister) SaveStateAndSnapshot(state, snapshot []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.snapshot = snapshot
+	return nil
+}
+
+// countingFSM counts the commands it applies, and snapshots the count
+type countingFSM struct {
+	applied atomic.Int64
+}
+
+func (f *countingFSM) Apply(entry LogEntry) []byte {
+	f.applied.Add(1)
+	return nil
+}
+
+func (f *countingFSM) Snapshot() ([]byte, error) {
+	return json.Marshal(f.applied.Load())
+}
+
+func (f *countingFSM) Restore(snapshot []byte) error {
+	var applied int64
+	if err := json.Unmarshal(snapshot, &applied); err != nil {
+		return err
+	}
+	f.applied.Store(applied)
+	return nil
+}
+
+func TestNodeRestartsFromSnapshotNewerThanState(t *testing.T) {
+	ctx := context.Background()
+	persister := &tornPersister{MemoryPersister: NewMemoryPersister()}
+
+	start := func(fsm StateMachine) *Node {
+		config := DefaultConfig("node-1", []string{"node-1"})
+		config.ElectionTimeout = 20 * time.Millisecond
+		config.SnapshotThreshold = 4
+		node, err := NewNode(config, NewInmemNetwork().Transport("node-1"), persister, fsm)
+		if err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+		t.Cleanup(node.Shutdown)
+		return node
+	}
+
+	node := start(&countingFSM{})
+	for i := 0; i < 10; i++ {
+		if _, err := node.Apply(ctx, []byte("{}")); err != nil {
+			t.Fatalf("Failed to apply command %d: %v", i, err)
+		}
+	}
+	node.Shutdown()
+	if snapshot, _ := persister.ReadSnapshot(); len(snapshot) == 0 {
+		t.Fatal("Expected a snapshot to be saved")
+	}
+
+	// Every snapshot was saved without its state, so the state still holds
+	// the whole log. The commands the snapshot covers must not be applied
+	// on top of it again.
+	fsm := &countingFSM{}
+	node = start(fsm)
+	if err := node.ReadBarrier(ctx); err != nil {
+		t.Fatalf("Failed to catch up: %v", err)
+	}
+	if applied := fsm.applied.Load(); applied != 10 {
+		t.Errorf("Expected 10 commands after the restart, got %d", applied)
+	}
+}
+
+func TestNodeCommitIndexNeverGoesBack(t *testing.T) {
+	node := newTestNode(t, NewMemoryPersister(), &testFSM{})
+
+	entries := make([]LogEntry, 3)
+	for i := range entries {
+		entries[i] = LogEntry{Index: uint64(i + 1), Term: 1, Type: EntryNoop}
+	}
+	node.HandleAppendEntries(&AppendEntriesRequest{Term: 1, LeaderID: "node-2", Entries: entries, LeaderCommit: 2})
+
+	// A reordered request carries fewer entries than the follower already
+	// matched, and must not move the commit index back to the last of them
+	node.HandleAppendEntries(&AppendEntriesRequest{Term: 1, LeaderID: "node-2", Entries: entries[:1], LeaderCommit: 3})
+	node.mu.Lock()
+	commitIndex := node.commitIndex
+	node.mu.Unlock()
+	if commitIndex != 2 {
+		t.Errorf("Expected the commit index to stay at 2, got %d", commitIndex)
+	}
+}
@@ -0,0 +1,526 @@
+package consensus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// defaultTimeout bounds how long a store operation waits for the cluster
+const defaultTimeout = 5 * time.Second
+
+// Store operations replicated through the log
+const (
+	opCreateAuction = "create_auction"
+	opPlaceBid      = "place_bid"
+	opCloseAuction  = "close_auction"
+	opAcceptPrice   = "accept_price"
+	opBuyNow        = "buy_now"
+	opRegister      = "register_participant"
+	opUpdateAuction = "update_auction"
+	opCancelAuction = "cancel_auction"
+
+	opCreateAuctionIdempotent = "create_auction_idempotent"
+	opPlaceBidIdempotent      = "place_bid_idempotent"
+	opSweepIdempotencyKeys    = "sweep_idempotency_keys"
+)
+
+// acceptArgs are the arguments of accept_price and buy_now commands
+type acceptArgs struct {
+	AuctionID     string `json:"auction_id"`
+	ParticipantID string `json:"participant_id"`
+}
+
+// updateArgs are the arguments of update_auction commands
+type updateArgs struct {
+	AuctionID string                `json:"auction_id"`
+	SellerID  string                `json:"seller_id"`
+	Update    auction.AuctionUpdate `json:"update"`
+}
+
+// cancelArgs are the arguments of cancel_auction commands
+type cancelArgs struct {
+	AuctionID string `json:"auction_id"`
+	SellerID  string `json:"seller_id"`
+	Reason    string `json:"reason"`
+}
+
+// createArgs are the arguments of create_auction_idempotent commands
+type createArgs struct {
+	Key  storage.IdempotencyKey `json:"key"`
+	Item auction.AuctionItem    `json:"item"`
+}
+
+// bidArgs are the arguments of place_bid_idempotent commands
+type bidArgs struct {
+	Key storage.IdempotencyKey `json:"key"`
+	Bid auction.Bid            `json:"bid"`
+}
+
+// command is a single store mutation recorded in the Raft log
+type command struct {
+	Op string `json:"op"`
+	// Time is taken on the node that received the request, so every replica
+	// evaluates expiry checks against the same clock
+	Time time.Time       `json:"time"`
+	Args json.RawMessage `json:"args"`
+}
+
+// commandResult is the outcome of applying a command to the state machine
+type commandResult struct {
+	Value json.RawMessage `json:"value,omitempty"`
+	Error string          `json:"error,omitempty"`
+	// Code carries the code of a storage.Error, so callers can still match
+	// it with errors.Is
+	Code string `json:"code,omitempty"`
+	// NextMinimumBid carries a storage.BidTooLowError across the log
+	NextMinimumBid *auction.Money `json:"next_minimum_bid,omitempty"`
+}
+
+// storeFSM applies store commands to a local MemoryStore
+type storeFSM struct {
+	store *storage.MemoryStore
+
+	clockMu   sync.Mutex
+	applyTime time.Time
+	applyIdx  uint64
+	idCounter int
+}
+
+func newStoreFSM() *storeFSM {
+	f := &storeFSM{}
+	f.store = storage.NewMemoryStoreWithClock(f.now, f.newID)
+	return f
+}
+
+// now returns the time of the command being applied, or the wall clock for reads
+func (f *storeFSM) now() time.Time {
+	f.clockMu.Lock()
+	defer f.clockMu.Unlock()
+
+	if f.applyTime.IsZero() {
+		return time.Now()
+	}
+	return f.applyTime
+}
+
+// newID derives IDs from the log index so every replica generates the same ones
+func (f *storeFSM) newID() string {
+	f.clockMu.Lock()
+	defer f.clockMu.Unlock()
+
+	f.idCounter++
+	name := fmt.Sprintf("%d/%d", f.applyIdx, f.idCounter)
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(name)).String()
+}
+
+// Apply executes a committed command against the local store
+func (f *storeFSM) Apply(entry LogEntry) []byte {
+	var cmd command
+	if err := json.Unmarshal(entry.Data, &cmd); err != nil {
+		return encodeResult(nil, err)
+	}
+
+	f.clockMu.Lock()
+	f.applyTime = cmd.Time
+	f.applyIdx = entry.Index
+	f.idCounter = 0
+	f.clockMu.Unlock()
+
+	defer func() {
+		f.clockMu.Lock()
+		f.applyTime = time.Time{}
+		f.clockMu.Unlock()
+	}()
+
+	// A committed command is applied on every replica, whatever became of
+	// the request that proposed it
+	ctx := context.Background()
+
+	switch cmd.Op {
+	case opCreateAuction:
+		var item auction.AuctionItem
+		if err := json.Unmarshal(cmd.Args, &item); err != nil {
+			return encodeResult(nil, err)
+		}
+		return encodeResult(f.store.CreateAuction(ctx, item))
+
+	case opPlaceBid:
+		var bid auction.Bid
+		if err := json.Unmarshal(cmd.Args, &bid); err != nil {
+			return encodeResult(nil, err)
+		}
+		return encodeResult(f.store.PlaceBid(ctx, bid))
+
+	case opCreateAuctionIdempotent:
+		var args createArgs
+		if err := json.Unmarshal(cmd.Args, &args); err != nil {
+			return encodeResult(nil, err)
+		}
+		return encodeResult(f.store.CreateAuctionIdempotent(ctx, args.Key, args.Item))
+
+	case opPlaceBidIdempotent:
+		var args bidArgs
+		if err := json.Unmarshal(cmd.Args, &args); err != nil {
+			return encodeResult(nil, err)
+		}
+		return encodeResult(f.store.PlaceBidIdempotent(ctx, args.Key, args.Bid))
+
+	case opCloseAuction:
+		var id string
+		if err := json.Unmarshal(cmd.Args, &id); err != nil {
+			return encodeResult(nil, err)
+		}
+		return encodeResult(f.store.CloseAuction(ctx, id))
+
+	case opAcceptPrice:
+		var args acceptArgs
+		if err := json.Unmarshal(cmd.Args, &args); err != nil {
+			return encodeResult(nil, err)
+		}
+		return encodeResult(f.store.AcceptPrice(ctx, args.AuctionID, args.ParticipantID))
+
+	case opBuyNow:
+		var args acceptArgs
+		if err := json.Unmarshal(cmd.Args, &args); err != nil {
+			return encodeResult(nil, err)
+		}
+		return encodeResult(f.store.BuyNow(ctx, args.AuctionID, args.ParticipantID))
+
+	case opRegister:
+		var participant auction.Participant
+		if err := json.Unmarshal(cmd.Args, &participant); err != nil {
+			return encodeResult(nil, err)
+		}
+		return encodeResult(f.store.RegisterParticipant(ctx, participant))
+
+	case opUpdateAuction:
+		var args updateArgs
+		if err := json.Unmarshal(cmd.Args, &args); err != nil {
+			return encodeResult(nil, err)
+		}
+		return encodeResult(f.store.UpdateAuction(ctx, args.AuctionID, args.SellerID, args.Update))
+
+	case opCancelAuction:
+		var args cancelArgs
+		if err := json.Unmarshal(cmd.Args, &args); err != nil {
+			return encodeResult(nil, err)
+		}
+		return encodeResult(f.store.CancelAuction(ctx, args.AuctionID, args.SellerID, args.Reason))
+
+	case opSweepIdempotencyKeys:
+		var before time.Time
+		if err := json.Unmarshal(cmd.Args, &before); err != nil {
+			return encodeResult(nil, err)
+		}
+		return encodeResult(f.store.SweepIdempotencyKeys(ctx, before))
+
+	default:
+		return encodeResult(nil, fmt.Errorf("unknown command %q", cmd.Op))
+	}
+}
+
+func (f *storeFSM) Snapshot() ([]byte, error) {
+	return f.store.Snapshot()
+}
+
+func (f *storeFSM) Restore(snapshot []byte) error {
+	return f.store.Restore(snapshot)
+}
+
+func encodeResult(value interface{}, err error) []byte {
+	var res commandResult
+	var tooLow *storage.BidTooLowError
+	if errors.As(err, &tooLow) {
+		res.NextMinimumBid = &tooLow.NextMinimumBid
+	}
+	var storeErr *storage.Error
+	if errors.As(err, &storeErr) {
+		res.Code = storeErr.Code
+	}
+	if err != nil {
+		res.Error = err.Error()
+	} else if value != nil {
+		res.Value, _ = json.Marshal(value)
+	}
+	data, _ := json.Marshal(res)
+	return data
+}
+
+// RaftStore provides a Raft-replicated implementation of auction storage.
+// Every server in the cluster keeps a full copy of the data; writes go
+// through the leader and reads are served locally after a read barrier.
+type RaftStore struct {
+	node *Node
+	fsm  *storeFSM
+}
+
+// NewRaftStore creates a store backed by a new Raft node
+func NewRaftStore(config Config, transport Transport, persister Persister) (*RaftStore, error) {
+	fsm := newStoreFSM()
+	node, err := NewNode(config, transport, persister, fsm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RaftStore{
+		node: node,
+		fsm:  fsm,
+	}, nil
+}
+
+// Node returns the Raft node, so it can be attached to a transport
+func (r *RaftStore) Node() *Node {
+	return r.node
+}
+
+// Close stops the Raft node
+func (r *RaftStore) Close() {
+	r.node.Shutdown()
+}
+
+// apply replicates a command and decodes its result into value, waiting
+// until ctx is done or defaultTimeout passes, whichever comes first
+func (r *RaftStore) apply(ctx context.Context, op string, args interface{}, value interface{}) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	cmd, err := json.Marshal(command{Op: op, Time: time.Now(), Args: data})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	out, err := r.node.Apply(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	var res commandResult
+	if err := json.Unmarshal(out, &res); err != nil {
+		return err
+	}
+	if res.NextMinimumBid != nil {
+		return &storage.BidTooLowError{NextMinimumBid: *res.NextMinimumBid}
+	}
+	if res.Code != "" {
+		return &storage.Error{Code: res.Code, Message: res.Error}
+	}
+	if res.Error != "" {
+		return errors.New(res.Error)
+	}
+	if value != nil && len(res.Value) > 0 {
+		return json.Unmarshal(res.Value, value)
+	}
+	return nil
+}
+
+// read waits until the local replica is up to date for a linearizable read,
+// until ctx is done or defaultTimeout passes at most
+func (r *RaftStore) read(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+	return r.node.ReadBarrier(ctx)
+}
+
+// CreateAuction adds a new auction item to the store
+func (r *RaftStore) CreateAuction(ctx context.Context, item auction.AuctionItem) (auction.AuctionItem, error) {
+	if item.ID == "" {
+		item.ID = uuid.New().String()
+	}
+
+	var created auction.AuctionItem
+	if err := r.apply(ctx, opCreateAuction, item, &created); err != nil {
+		return auction.AuctionItem{}, err
+	}
+	return created, nil
+}
+
+// CreateAuctionIdempotent creates an auction once per key. Every replica
+// records the key as it applies the command, so a retry through any node
+// returns the auction the first command created.
+func (r *RaftStore) CreateAuctionIdempotent(ctx context.Context, key storage.IdempotencyKey, item auction.AuctionItem) (auction.AuctionItem, error) {
+	if item.ID == "" {
+		item.ID = uuid.New().String()
+	}
+
+	var created auction.AuctionItem
+	if err := r.apply(ctx, opCreateAuctionIdempotent, createArgs{Key: key, Item: item}, &created); err != nil {
+		return auction.AuctionItem{}, err
+	}
+	return created, nil
+}
+
+// ListAuctions returns a page of the auctions matching the query
+func (r *RaftStore) ListAuctions(ctx context.Context, query auction.ListQuery) (auction.ListPage, error) {
+	if err := r.read(ctx); err != nil {
+		return auction.ListPage{}, err
+	}
+	return r.fsm.store.ListAuctions(ctx, query)
+}
+
+// SearchAuctions returns the auctions matching the text and the filters of
+// the query. Every replica indexes the auctions as it applies the log.
+func (r *RaftStore) SearchAuctions(ctx context.Context, text string, query auction.ListQuery) ([]auction.AuctionItem, error) {
+	if err := r.read(ctx); err != nil {
+		return nil, err
+	}
+	return r.fsm.store.SearchAuctions(ctx, text, query)
+}
+
+// GetAuction retrieves an auction by ID
+func (r *RaftStore) GetAuction(ctx context.Context, id string) (auction.AuctionItem, error) {
+	if err := r.read(ctx); err != nil {
+		return auction.AuctionItem{}, err
+	}
+	return r.fsm.store.GetAuction(ctx, id)
+}
+
+// PlaceBid adds a new bid to an auction item through the replicated log
+// and returns it as stored
+func (r *RaftStore) PlaceBid(ctx context.Context, bid auction.Bid) (auction.Bid, error) {
+	if bid.ID == "" {
+		bid.ID = uuid.New().String()
+	}
+
+	var placed auction.Bid
+	if err := r.apply(ctx, opPlaceBid, bid, &placed); err != nil {
+		return auction.Bid{}, err
+	}
+	return placed, nil
+}
+
+// PlaceBidIdempotent places a bid once per key through the replicated log
+// and returns it as stored
+func (r *RaftStore) PlaceBidIdempotent(ctx context.Context, key storage.IdempotencyKey, bid auction.Bid) (auction.Bid, error) {
+	if bid.ID == "" {
+		bid.ID = uuid.New().String()
+	}
+
+	var placed auction.Bid
+	if err := r.apply(ctx, opPlaceBidIdempotent, bidArgs{Key: key, Bid: bid}, &placed); err != nil {
+		return auction.Bid{}, err
+	}
+	return placed, nil
+}
+
+// GetHighestBid returns the highest bid for an auction
+func (r *RaftStore) GetHighestBid(ctx context.Context, auctionID string) (auction.Bid, error) {
+	if err := r.read(ctx); err != nil {
+		return auction.Bid{}, err
+	}
+	return r.fsm.store.GetHighestBid(ctx, auctionID)
+}
+
+// GetBidHistory returns all bids for an auction
+func (r *RaftStore) GetBidHistory(ctx context.Context, auctionID string) ([]auction.Bid, error) {
+	if err := r.read(ctx); err != nil {
+		return nil, err
+	}
+	return r.fsm.store.GetBidHistory(ctx, auctionID)
+}
+
+// CloseAuction settles an expired auction through the replicated log
+func (r *RaftStore) CloseAuction(ctx context.Context, id string) (auction.Settlement, error) {
+	var settlement auction.Settlement
+	if err := r.apply(ctx, opCloseAuction, id, &settlement); err != nil {
+		return auction.Settlement{}, err
+	}
+	return settlement, nil
+}
+
+// AcceptPrice accepts the current price of a Dutch auction through the replicated log
+func (r *RaftStore) AcceptPrice(ctx context.Context, auctionID, participantID string) (auction.Settlement, error) {
+	var settlement auction.Settlement
+	args := acceptArgs{AuctionID: auctionID, ParticipantID: participantID}
+	if err := r.apply(ctx, opAcceptPrice, args, &settlement); err != nil {
+		return auction.Settlement{}, err
+	}
+	return settlement, nil
+}
+
+// BuyNow buys an auction at its buy-now price through the replicated log
+func (r *RaftStore) BuyNow(ctx context.Context, auctionID, participantID string) (auction.Settlement, error) {
+	var settlement auction.Settlement
+	args := acceptArgs{AuctionID: auctionID, ParticipantID: participantID}
+	if err := r.apply(ctx, opBuyNow, args, &settlement); err != nil {
+		return auction.Settlement{}, err
+	}
+	return settlement, nil
+}
+
+// GetSettlement returns the outcome of a closed auction
+func (r *RaftStore) GetSettlement(ctx context.Context, id string) (auction.Settlement, error) {
+	if err := r.read(ctx); err != nil {
+		return auction.Settlement{}, err
+	}
+	return r.fsm.store.GetSettlement(ctx, id)
+}
+
+// UpdateAuction applies a seller's update through the replicated log
+func (r *RaftStore) UpdateAuction(ctx context.Context, id, sellerID string, update auction.AuctionUpdate) (auction.AuctionItem, error) {
+	var updated auction.AuctionItem
+	args := updateArgs{AuctionID: id, SellerID: sellerID, Update: update}
+	if err := r.apply(ctx, opUpdateAuction, args, &updated); err != nil {
+		return auction.AuctionItem{}, err
+	}
+	return updated, nil
+}
+
+// CancelAuction withdraws an auction through the replicated log
+func (r *RaftStore) CancelAuction(ctx context.Context, id, sellerID, reason string) (auction.Settlement, error) {
+	var settlement auction.Settlement
+	args := cancelArgs{AuctionID: id, SellerID: sellerID, Reason: reason}
+	if err := r.apply(ctx, opCancelAuction, args, &settlement); err != nil {
+		return auction.Settlement{}, err
+	}
+	return settlement, nil
+}
+
+// SweepIdempotencyKeys removes old idempotency records through the
+// replicated log, so every replica forgets the same keys
+func (r *RaftStore) SweepIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	var removed int
+	if err := r.apply(ctx, opSweepIdempotencyKeys, before, &removed); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// Subscribe streams the events of an auction as they are applied to the
+// local replica. Every replica applies the same commands in the same order,
+// so sequence numbers agree across servers.
+func (r *RaftStore) Subscribe(ctx context.Context, auctionID string, after uint64) (<-chan auction.Event, error) {
+	if err := r.read(ctx); err != nil {
+		return nil, err
+	}
+	return r.fsm.store.Subscribe(ctx, auctionID, after)
+}
+
+// RegisterParticipant adds a participant through the replicated log. Only
+// the hash of the API key is replicated.
+func (r *RaftStore) RegisterParticipant(ctx context.Context, participant auction.Participant) (auction.Participant, error) {
+	var registered auction.Participant
+	if err := r.apply(ctx, opRegister, participant, &registered); err != nil {
+		return auction.Participant{}, err
+	}
+	return registered, nil
+}
+
+// GetParticipant retrieves a participant by ID
+func (r *RaftStore) GetParticipant(ctx context.Context, id string) (auction.Participant, error) {
+	if err := r.read(ctx); err != nil {
+		return auction.Participant{}, err
+	}
+	return r.fsm.store.GetParticipant(ctx, id)
+}
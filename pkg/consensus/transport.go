@@ -0,0 +1,94 @@
+package consensus
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnreachable is returned by a transport when the target node cannot be contacted
+var ErrUnreachable = errors.New("raft: node unreachable")
+
+// RequestVoteRequest is sent by candidates to gather votes
+type RequestVoteRequest struct {
+	Term         uint64 `json:"term"`
+	CandidateID  string `json:"candidate_id"`
+	LastLogIndex uint64 `json:"last_log_index"`
+	LastLogTerm  uint64 `json:"last_log_term"`
+}
+
+// RequestVoteResponse is the reply to a RequestVoteRequest
+type RequestVoteResponse struct {
+	Term        uint64 `json:"term"`
+	VoteGranted bool   `json:"vote_granted"`
+}
+
+// AppendEntriesRequest is sent by the leader to replicate log entries and as a heartbeat
+type AppendEntriesRequest struct {
+	Term         uint64     `json:"term"`
+	LeaderID     string     `json:"leader_id"`
+	PrevLogIndex uint64     `json:"prev_log_index"`
+	PrevLogTerm  uint64     `json:"prev_log_term"`
+	Entries      []LogEntry `json:"entries"`
+	LeaderCommit uint64     `json:"leader_commit"`
+}
+
+// AppendEntriesResponse is the reply to an AppendEntriesRequest
+type AppendEntriesResponse struct {
+	Term    uint64 `json:"term"`
+	Success bool   `json:"success"`
+	// ConflictIndex lets the leader skip back over a whole mismatching term at once
+	ConflictIndex uint64 `json:"conflict_index"`
+}
+
+// InstallSnapshotRequest is sent by the leader to followers that have fallen
+// behind the start of its log
+type InstallSnapshotRequest struct {
+	Term              uint64 `json:"term"`
+	LeaderID          string `json:"leader_id"`
+	LastIncludedIndex uint64 `json:"last_included_index"`
+	LastIncludedTerm  uint64 `json:"last_included_term"`
+	Data              []byte `json:"data"`
+}
+
+// InstallSnapshotResponse is the reply to an InstallSnapshotRequest
+type InstallSnapshotResponse struct {
+	Term uint64 `json:"term"`
+	// Success reports that the follower holds the snapshot, having saved it
+	// or being ahead of it already
+	Success bool `json:"success"`
+}
+
+// ForwardRequest carries a command from a follower to the leader
+type ForwardRequest struct {
+	Command []byte `json:"command"`
+}
+
+// ForwardResponse carries the state machine result of a forwarded command
+type ForwardResponse struct {
+	Result []byte `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ReadIndexResponse carries the commit index a follower must apply before serving a read
+type ReadIndexResponse struct {
+	Index uint64 `json:"index"`
+	Error string `json:"error,omitempty"`
+}
+
+// RPCHandler is implemented by a Node to serve requests arriving over a Transport
+type RPCHandler interface {
+	HandleRequestVote(req *RequestVoteRequest) *RequestVoteResponse
+	HandleAppendEntries(req *AppendEntriesRequest) *AppendEntriesResponse
+	HandleInstallSnapshot(req *InstallSnapshotRequest) *InstallSnapshotResponse
+	HandleForward(ctx context.Context, req *ForwardRequest) *ForwardResponse
+	HandleReadIndex(ctx context.Context) *ReadIndexResponse
+}
+
+// Transport delivers Raft RPCs to other nodes in the cluster
+type Transport interface {
+	RequestVote(ctx context.Context, target string, req *RequestVoteRequest) (*RequestVoteResponse, error)
+	AppendEntries(ctx context.Context, target string, req *AppendEntriesRequest) (*AppendEntriesResponse, error)
+	InstallSnapshot(ctx context.Context, target string, req *InstallSnapshotRequest) (*InstallSnapshotResponse, error)
+	Forward(ctx context.Context, target string, req *ForwardRequest) (*ForwardResponse, error)
+	ReadIndex(ctx context.Context, target string) (*ReadIndexResponse, error)
+}
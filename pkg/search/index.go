@@ -0,0 +1,192 @@
+// Package search is an in-memory full-text index of auctions. It splits
+// the name, description, category and tags of each auction into terms and
+// keeps, for every term, the auctions containing it, so a query only looks
+// at the auctions sharing its terms.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+// Weights of a term by the field it appears in, so a match in the name
+// ranks above one buried in the description
+const (
+	nameWeight        = 3
+	labelWeight       = 2
+	descriptionWeight = 1
+)
+
+// stopWords are too common to tell auctions apart and are not indexed
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "for": true, "in": true,
+	"of": true, "on": true, "or": true, "the": true, "to": true, "with": true,
+}
+
+// Hit is an auction matching a search, and how well it matches
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// Index maps terms to the auctions containing them. It is safe for
+// concurrent use.
+type Index struct {
+	mu sync.RWMutex
+	// postings maps each term to the auctions containing it, and the weight
+	// of the term in each
+	postings map[string]map[string]float64
+	// terms maps each auction to its terms, so they can be removed when it
+	// changes
+	terms map[string][]string
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[string]float64),
+		terms:    make(map[string][]string),
+	}
+}
+
+// Put adds an auction to the index, replacing the terms of an earlier
+// version of it
+func (x *Index) Put(item auction.AuctionItem) {
+	weights := make(map[string]float64)
+	add := func(text string, weight float64) {
+		for _, term := range Terms(text) {
+			weights[term] += weight
+		}
+	}
+	add(item.Name, nameWeight)
+	add(item.Description, descriptionWeight)
+	add(item.Category, labelWeight)
+	for _, tag := range item.Tags {
+		add(tag, labelWeight)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.remove(item.ID)
+	terms := make([]string, 0, len(weights))
+	for term, weight := range weights {
+		postings := x.postings[term]
+		if postings == nil {
+			postings = make(map[string]float64)
+			x.postings[term] = postings
+		}
+		postings[item.ID] = weight
+		terms = append(terms, term)
+	}
+	x.terms[item.ID] = terms
+}
+
+// Remove drops an auction from the index
+func (x *Index) Remove(id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(id)
+}
+
+// remove drops an auction from the index. The caller must hold mu.
+func (x *Index) remove(id string) {
+	for _, term := range x.terms[id] {
+		delete(x.postings[term], id)
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	delete(x.terms, id)
+}
+
+// Len returns the number of auctions in the index
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.terms)
+}
+
+// Search returns the auctions containing every term of the text, best
+// match first. Each term scores its weight in the auction times its
+// inverse document frequency, so rare terms count for more than common
+// ones. Ties are broken by ID.
+func (x *Index) Search(text string) []Hit {
+	terms := Terms(text)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	// Start from the rarest term, which has the fewest candidates
+	sort.Slice(terms, func(i, j int) bool {
+		return len(x.postings[terms[i]]) < len(x.postings[terms[j]])
+	})
+
+	scores := make(map[string]float64)
+	for id := range x.postings[terms[0]] {
+		scores[id] = 0
+	}
+	for _, term := range terms {
+		postings := x.postings[term]
+		idf := math.Log(1 + float64(len(x.terms))/float64(len(postings)+1))
+		for id := range scores {
+			weight, ok := postings[id]
+			if !ok {
+				delete(scores, id)
+				continue
+			}
+			scores[id] += weight * idf
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return hits
+}
+
+// Terms splits text into the distinct terms it is indexed and searched by:
+// runs of letters and digits in lower case, without stop words, and with
+// a plural "s" removed so "lamps" finds "lamp"
+func Terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range words {
+		if stopWords[word] {
+			continue
+		}
+		term := stem(word)
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// stem removes a plural "s", leaving words ending in "ss" such as "glass"
+func stem(word string) string {
+	if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+		return word[:len(word)-1]
+	}
+	return word
+}
@@ -0,0 +1,58 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+func TestIndexSearch(t *testing.T) {
+	index := NewIndex()
+	index.Put(auction.AuctionItem{ID: "1", Name: "Brass desk lamp", Description: "Works, with a green shade", Category: "lighting"})
+	index.Put(auction.AuctionItem{ID: "2", Name: "Oak desk", Description: "Fits a lamp and two monitors", Category: "furniture"})
+	index.Put(auction.AuctionItem{ID: "3", Name: "Floor lamps", Tags: []string{"vintage", "brass"}, Category: "lighting"})
+	index.Put(auction.AuctionItem{ID: "4", Name: "Glass vase", Description: "Hand blown"})
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		// A match in the name ranks above one in the description
+		{"lamp", "[1 3 2]"},
+		{"Desk LAMP", "[1 2]"},
+		{"brass", "[1 3]"},
+		{"vintage lamp", "[3]"},
+		{"lighting", "[1 3]"},
+		{"glass", "[4]"},
+		{"the", "[]"},
+		{"lamp piano", "[]"},
+	}
+	for _, tt := range tests {
+		if got := ids(index.Search(tt.text)); got != tt.want {
+			t.Errorf("Search(%q): expected %s, got %s", tt.text, tt.want, got)
+		}
+	}
+
+	// Changing an auction replaces its terms
+	index.Put(auction.AuctionItem{ID: "1", Name: "Brass candlestick"})
+	if got := ids(index.Search("lamp")); got != "[3 2]" {
+		t.Errorf("Expected the renamed auction to drop out, got %s", got)
+	}
+	if got := ids(index.Search("candlestick")); got != "[1]" {
+		t.Errorf("Expected the new name to be found, got %s", got)
+	}
+
+	index.Remove("3")
+	if got := ids(index.Search("brass")); got != "[1]" || index.Len() != 3 {
+		t.Errorf("Expected the removed auction to drop out, got %s of %d", got, index.Len())
+	}
+}
+
+func ids(hits []Hit) string {
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	return fmt.Sprint(ids)
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+// Error is a store error with a stable code, so callers can tell failures
+// apart without matching on messages
+type Error struct {
+	// Code identifies the kind of failure, such as "auction_not_found"
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches any Error with the same code, so an error rebuilt from its
+// code and message, as the Raft store does, still matches the sentinel
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// withMessage returns an error matching e with a more specific message
+func (e *Error) withMessage(message string) *Error {
+	return &Error{Code: e.Code, Message: message}
+}
+
+// Errors returned by stores, compare them with errors.Is
+var (
+	ErrAuctionNotFound      = &Error{Code: "auction_not_found", Message: "auction not found"}
+	ErrNoBids               = &Error{Code: "no_bids", Message: "no bids found for this auction"}
+	ErrAuctionClosed        = &Error{Code: "auction_closed", Message: "auction is closed"}
+	ErrAuctionExpired       = &Error{Code: "auction_expired", Message: "auction has expired"}
+	ErrAuctionNotExpired    = &Error{Code: "auction_not_expired", Message: "auction has not expired"}
+	ErrAuctionNotClosed     = &Error{Code: "auction_not_closed", Message: "auction has not closed yet"}
+	ErrBidTooLow            = &Error{Code: "bid_too_low", Message: "bid price is lower than the next minimum bid"}
+	ErrCurrencyMismatch     = &Error{Code: "currency_mismatch", Message: "bid must be in the auction currency"}
+	ErrMaxBidTooLow         = &Error{Code: "max_bid_too_low", Message: "max bid is lower than bid price"}
+	ErrProxyBidSealed       = &Error{Code: "proxy_bid_not_allowed", Message: "proxy bids are not allowed in sealed auctions"}
+	ErrDutchBid             = &Error{Code: "bids_not_accepted", Message: "dutch auctions do not take bids, accept the current price instead"}
+	ErrNotDutch             = &Error{Code: "not_dutch_auction", Message: "only dutch auctions can be accepted"}
+	ErrNoBuyNow             = &Error{Code: "no_buy_now_price", Message: "auction has no buy-now price"}
+	ErrBuyNowReached        = &Error{Code: "buy_now_unavailable", Message: "bidding has reached the buy-now price"}
+	ErrParticipantExists    = &Error{Code: "participant_exists", Message: "participant ID is already registered"}
+	ErrParticipantNotFound  = &Error{Code: "participant_not_found", Message: "participant not found"}
+	ErrNotSeller            = &Error{Code: "not_seller", Message: "only the seller can change this auction"}
+	ErrSellerBid            = &Error{Code: "seller_bid", Message: "sellers cannot bid on their own auctions"}
+	ErrAuctionHasBids       = &Error{Code: "auction_has_bids", Message: "auction cannot be edited once it has bids"}
+	ErrExpiryNotExtended    = &Error{Code: "expiry_not_extended", Message: "expiry time can only be moved later"}
+	ErrIdempotencyKeyReused = &Error{Code: "idempotency_key_reused", Message: "idempotency key was already used for a different request"}
+	ErrBidConflict          = &Error{Code: "bid_conflict", Message: "other bids kept being placed first, try again"}
+	ErrSessionExpired       = &Error{Code: "session_expired", Message: "the ZooKeeper session expired, try again"}
+	ErrConnectionLost       = &Error{Code: "connection_lost", Message: "lost the connection to ZooKeeper, try again"}
+	ErrMemberExists         = &Error{Code: "member_exists", Message: "another live server is registered with this ID"}
+)
+
+// BidTooLowError is returned when a bid is below the lowest amount the
+// auction currently accepts. It matches ErrBidTooLow.
+type BidTooLowError struct {
+	// NextMinimumBid is the lowest bid that would have been accepted
+	NextMinimumBid auction.Money
+}
+
+func (e *BidTooLowError) Error() string {
+	return fmt.Sprintf("bid price is lower than the next minimum bid of %s", e.NextMinimumBid)
+}
+
+func (e *BidTooLowError) Unwrap() error {
+	return ErrBidTooLow
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+// eventRetryInterval is how long a subscription waits after failing to read events
+const eventRetryInterval = time.Second
+
+func bidPlacedEvent(bid auction.Bid) auction.Event {
+	return auction.Event{
+		Type:      auction.EventBidPlaced,
+		AuctionID: bid.AuctionItemID,
+		Time:      bid.Timestamp,
+		Bid:       &bid,
+	}
+}
+
+func extendedEvent(item auction.AuctionItem, now time.Time) auction.Event {
+	expiry := item.ExpiryTime
+	return auction.Event{
+		Type:       auction.EventExtended,
+		AuctionID:  item.ID,
+		Time:       now,
+		ExpiryTime: &expiry,
+	}
+}
+
+func updatedEvent(item auction.AuctionItem, now time.Time) auction.Event {
+	public := item.Public()
+	return auction.Event{
+		Type:      auction.EventUpdated,
+		AuctionID: item.ID,
+		Time:      now,
+		Auction:   &public,
+	}
+}
+
+func closedEvent(settlement auction.Settlement) auction.Event {
+	return auction.Event{
+		Type:       auction.EventClosed,
+		AuctionID:  settlement.AuctionItemID,
+		Time:       settlement.ClosedAt,
+		Settlement: &settlement,
+	}
+}
+
+// followEvents sends the events of an auction after the given sequence
+// number on the returned channel, until ctx is cancelled. load returns the
+// events after a sequence number, and a channel that fires once more may
+// have been recorded.
+func followEvents[T any](ctx context.Context, auctionID string, after uint64, load func(after uint64) ([]auction.Event, <-chan T, error)) <-chan auction.Event {
+	out := make(chan auction.Event)
+	go func() {
+		defer close(out)
+		for {
+			events, changed, err := load(after)
+			if err != nil {
+				log.Printf("Failed to read events of auction %s: %v", auctionID, err)
+				select {
+				case <-time.After(eventRetryInterval):
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			for _, event := range events {
+				select {
+				case out <- event:
+					after = event.Sequence
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
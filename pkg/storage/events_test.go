@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+// receive waits for the next n events of a subscription
+func receive(t *testing.T, events <-chan auction.Event, n int) []auction.Event {
+	t.Helper()
+	var received []auction.Event
+	for len(received) < n {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("Subscription closed after %d events, expected %d", len(received), n)
+			}
+			received = append(received, event)
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out after %d events, expected %d", len(received), n)
+		}
+	}
+	return received
+}
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+	store, advance := newClockedStore()
+	item, _ := store.CreateAuction(ctx, auction.AuctionItem{
+		Name:              "Clock",
+		MinimumBid:        usd(10),
+		ExpiryTime:        time.Now().Add(time.Hour),
+		ExtensionWindow:   auction.Duration(time.Minute),
+		ExtensionDuration: auction.Duration(2 * time.Minute),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := store.Subscribe(ctx, item.ID, 0)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	if _, err := store.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "alice", BidPrice: usd(20)}); err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+
+	// A bid in the final minute extends the auction
+	advance(time.Hour - 30*time.Second)
+	if _, err := store.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "bob", BidPrice: usd(30)}); err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+
+	advance(time.Hour)
+	if _, err := store.CloseAuction(ctx, item.ID); err != nil {
+		t.Fatalf("Failed to close auction: %v", err)
+	}
+
+	received := receive(t, events, 4)
+	want := []auction.EventType{auction.EventBidPlaced, auction.EventBidPlaced, auction.EventExtended, auction.EventClosed}
+	for i, event := range received {
+		if event.Sequence != uint64(i+1) || event.Type != want[i] || event.AuctionID != item.ID {
+			t.Errorf("Event %d: expected %s with sequence %d, got %+v", i, want[i], i+1, event)
+		}
+	}
+	if bid := received[1].Bid; bid == nil || bid.ParticipantID != "bob" || bid.BidPrice != usd(30) {
+		t.Errorf("Expected the second event to carry bob's bid, got %+v", bid)
+	}
+	if expiry := received[2].ExpiryTime; expiry == nil || !expiry.After(item.ExpiryTime) {
+		t.Errorf("Expected the extension to carry a later expiry, got %v", expiry)
+	}
+	if settlement := received[3].Settlement; settlement == nil || settlement.WinnerID != "bob" {
+		t.Errorf("Expected the close to carry bob's win, got %+v", settlement)
+	}
+
+	// A subscriber resuming after the second event only receives the rest
+	resumed, err := store.Subscribe(ctx, item.ID, 2)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	if received := receive(t, resumed, 2); received[0].Sequence != 3 || received[1].Sequence != 4 {
+		t.Errorf("Expected events 3 and 4 after resuming, got %+v", received)
+	}
+
+	// Events survive a snapshot, so replicas restored from one agree
+	data, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("Failed to snapshot: %v", err)
+	}
+	restored := NewMemoryStore()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	replayed, err := restored.Subscribe(ctx, item.ID, 3)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	if received := receive(t, replayed, 1); received[0].Type != auction.EventClosed {
+		t.Errorf("Expected the restored store to replay the close, got %+v", received)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Errorf("Expected no further events")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected the subscription to close when cancelled")
+	}
+
+	if _, err := store.Subscribe(context.Background(), "missing", 0); !errors.Is(err, ErrAuctionNotFound) {
+		t.Errorf("Expected subscribing to a missing auction to fail with ErrAuctionNotFound, got %v", err)
+	}
+}
+
+func TestBuyNowEvents(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newClockedStore()
+	item, _ := store.CreateAuction(ctx, auction.AuctionItem{
+		Name:        "Vase",
+		MinimumBid:  usd(10),
+		BuyNowPrice: usd(100),
+		ExpiryTime:  time.Now().Add(time.Hour),
+	})
+	if _, err := store.BuyNow(ctx, item.ID, "carol"); err != nil {
+		t.Fatalf("Failed to buy now: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := store.Subscribe(ctx, item.ID, 0)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	// The purchase is a winning bid followed by the close
+	received := receive(t, events, 2)
+	if received[0].Type != auction.EventBidPlaced || received[0].Bid.BidPrice != usd(100) {
+		t.Errorf("Expected the purchase as a bid at 100.00, got %+v", received[0])
+	}
+	if received[1].Type != auction.EventClosed || received[1].Settlement.WinnerID != "carol" {
+		t.Errorf("Expected carol to win on close, got %+v", received[1])
+	}
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+// IdempotencyRetention is how long a key is honoured after its first use.
+// Older records are removed by SweepIdempotencyKeys, after which the key
+// can be used for a new write.
+const IdempotencyRetention = 24 * time.Hour
+
+// IdempotencyKey names a write that a client may retry. The store records
+// the result of the first write made with a key in the same transaction as
+// the write, and returns that result to every later write with the key
+// instead of writing again.
+type IdempotencyKey struct {
+	// ParticipantID scopes the key, so participants cannot collide
+	ParticipantID string `json:"participant_id"`
+	// Key is chosen by the client, such as the Idempotency-Key header
+	Key string `json:"key"`
+	// Fingerprint identifies the request, so a key reused for a different
+	// request fails with ErrIdempotencyKeyReused
+	Fingerprint string `json:"fingerprint"`
+}
+
+// IsZero reports whether no key was given, in which case writes are not
+// deduplicated
+func (k IdempotencyKey) IsZero() bool {
+	return k.Key == ""
+}
+
+// name returns a fixed-length name for the key, usable as a map key or a
+// znode name whatever characters the client chose
+func (k IdempotencyKey) name() string {
+	sum := sha256.Sum256([]byte(k.ParticipantID + "\x00" + k.Key))
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotentResult is the recorded result of a write made with a key
+type idempotentResult struct {
+	Fingerprint string               `json:"fingerprint"`
+	CreatedAt   time.Time            `json:"created_at"`
+	Auction     *auction.AuctionItem `json:"auction,omitempty"`
+	Bid         *auction.Bid         `json:"bid,omitempty"`
+}
+
+// createdAuction returns the recorded auction of an auction creation, or
+// ErrIdempotencyKeyReused if the key was first used for another request
+func (r idempotentResult) createdAuction(key IdempotencyKey) (auction.AuctionItem, error) {
+	if r.Fingerprint != key.Fingerprint || r.Auction == nil {
+		return auction.AuctionItem{}, ErrIdempotencyKeyReused
+	}
+	return *r.Auction, nil
+}
+
+// placedBid returns the recorded bid of a bid placement, or
+// ErrIdempotencyKeyReused if the key was first used for another request
+func (r idempotentResult) placedBid(key IdempotencyKey) (auction.Bid, error) {
+	if r.Fingerprint != key.Fingerprint || r.Bid == nil {
+		return auction.Bid{}, ErrIdempotencyKeyReused
+	}
+	return *r.Bid, nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+func TestIdempotentWrites(t *testing.T) {
+	ctx := context.Background()
+	store, advance := newClockedStore()
+	createKey := IdempotencyKey{ParticipantID: "sam", Key: "create-1", Fingerprint: "lamp"}
+	lamp := auction.AuctionItem{Name: "Lamp", SellerID: "sam", MinimumBid: usd(10), ExpiryTime: time.Now().Add(time.Hour)}
+
+	item, err := store.CreateAuctionIdempotent(ctx, createKey, lamp)
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+	retried, err := store.CreateAuctionIdempotent(ctx, createKey, lamp)
+	if err != nil || retried.ID != item.ID {
+		t.Fatalf("Expected the retry to return auction %s, got %+v, %v", item.ID, retried, err)
+	}
+	if page, _ := store.ListAuctions(ctx, auction.ListQuery{}); len(page.Auctions) != 1 {
+		t.Fatalf("Expected a single auction, got %d", len(page.Auctions))
+	}
+
+	// The same key for another request, or from the creation, is refused
+	if _, err := store.CreateAuctionIdempotent(ctx, IdempotencyKey{ParticipantID: "sam", Key: "create-1", Fingerprint: "vase"}, lamp); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("Expected a different request to fail with ErrIdempotencyKeyReused, got %v", err)
+	}
+	if _, err := store.PlaceBidIdempotent(ctx, createKey, auction.Bid{AuctionItemID: item.ID, ParticipantID: "sam", BidPrice: usd(20)}); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("Expected a bid with the creation's key to fail with ErrIdempotencyKeyReused, got %v", err)
+	}
+
+	// A retried bid returns the first outcome, even though it no longer
+	// beats the highest bid, which is its own
+	bidKey := IdempotencyKey{ParticipantID: "alice", Key: "bid-1", Fingerprint: "20"}
+	bid := auction.Bid{AuctionItemID: item.ID, ParticipantID: "alice", BidPrice: usd(20)}
+	placed, err := store.PlaceBidIdempotent(ctx, bidKey, bid)
+	if err != nil || placed.ID == "" {
+		t.Fatalf("Failed to place bid: %+v, %v", placed, err)
+	}
+	advance(time.Second)
+	if again, err := store.PlaceBidIdempotent(ctx, bidKey, bid); err != nil || again.ID != placed.ID || !again.Timestamp.Equal(placed.Timestamp) {
+		t.Errorf("Expected the retry to return bid %+v, got %+v, %v", placed, again, err)
+	}
+
+	// Keys are scoped to the participant
+	if _, err := store.PlaceBidIdempotent(ctx, IdempotencyKey{ParticipantID: "bob", Key: "bid-1", Fingerprint: "30"}, auction.Bid{AuctionItemID: item.ID, ParticipantID: "bob", BidPrice: usd(30)}); err != nil {
+		t.Errorf("Expected bob's key to be independent of alice's, got %v", err)
+	}
+	if history, _ := store.GetBidHistory(ctx, item.ID); len(history) != 2 {
+		t.Errorf("Expected 2 bids, got %d", len(history))
+	}
+
+	// Failed writes are not recorded, so they can be retried
+	failKey := IdempotencyKey{ParticipantID: "carol", Key: "bid-1", Fingerprint: "25"}
+	low := auction.Bid{AuctionItemID: item.ID, ParticipantID: "carol", BidPrice: usd(25)}
+	if _, err := store.PlaceBidIdempotent(ctx, failKey, low); !errors.Is(err, ErrBidTooLow) {
+		t.Fatalf("Expected ErrBidTooLow, got %v", err)
+	}
+	if _, err := store.PlaceBidIdempotent(ctx, IdempotencyKey{ParticipantID: "carol", Key: "bid-1", Fingerprint: "35"}, auction.Bid{AuctionItemID: item.ID, ParticipantID: "carol", BidPrice: usd(35)}); err != nil {
+		t.Errorf("Expected the key of a failed bid to be reusable, got %v", err)
+	}
+
+	// Records survive a snapshot
+	snapshot, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("Failed to snapshot: %v", err)
+	}
+	restored := NewMemoryStore()
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if again, err := restored.PlaceBidIdempotent(ctx, bidKey, bid); err != nil || again.ID != placed.ID {
+		t.Errorf("Expected the restored store to return bid %s, got %+v, %v", placed.ID, again, err)
+	}
+}
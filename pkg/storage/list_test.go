@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+func TestListAuctions(t *testing.T) {
+	ctx := context.Background()
+	store, advance := newClockedStore()
+	create := func(name string, typ auction.AuctionType, expiry time.Duration) auction.AuctionItem {
+		item, err := store.CreateAuction(ctx, auction.AuctionItem{
+			Name:        name,
+			SellerID:    "sam",
+			AuctionType: typ,
+			MinimumBid:  usd(10),
+			ExpiryTime:  time.Now().Add(expiry),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create auction: %v", err)
+		}
+		advance(time.Second)
+		return item
+	}
+	lamp := create("Lamp", auction.TypeEnglish, time.Hour)
+	vase := create("Vase", auction.TypeSealedFirstPrice, 2*time.Hour)
+	create("Chair", auction.TypeEnglish, time.Minute)
+
+	for _, bid := range []auction.Bid{
+		{AuctionItemID: lamp.ID, ParticipantID: "alice", BidPrice: usd(30)},
+		{AuctionItemID: vase.ID, ParticipantID: "alice", BidPrice: usd(50)},
+	} {
+		if _, err := store.PlaceBid(ctx, bid); err != nil {
+			t.Fatalf("Failed to place bid: %v", err)
+		}
+	}
+
+	// Open auctions list their highest bid, sealed ones keep it hidden
+	page, err := store.ListAuctions(ctx, auction.ListQuery{Sort: auction.SortHighestBid})
+	if err != nil {
+		t.Fatalf("Failed to list auctions: %v", err)
+	}
+	if len(page.Auctions) != 3 || page.Auctions[0].ID != lamp.ID || page.Auctions[0].CurrentBid != usd(30) {
+		t.Fatalf("Expected the lamp's bid to rank it first, got %+v", page.Auctions)
+	}
+	for _, item := range page.Auctions[1:] {
+		if !item.CurrentBid.IsZero() {
+			t.Errorf("Expected %s to have no current bid, got %v", item.Name, item.CurrentBid)
+		}
+	}
+
+	advance(5 * time.Minute)
+	page, err = store.ListAuctions(ctx, auction.ListQuery{Status: auction.StatusActive, Sort: auction.SortEndingSoonest, Limit: 1})
+	if err != nil {
+		t.Fatalf("Failed to list auctions: %v", err)
+	}
+	if len(page.Auctions) != 1 || page.Auctions[0].ID != lamp.ID || page.NextCursor == "" {
+		t.Fatalf("Expected the lamp on the first page of active auctions, got %+v", page)
+	}
+
+	next, more := auction.ListQuery{Status: auction.StatusActive, Sort: auction.SortEndingSoonest, Limit: 1}.Next(page)
+	if page, err = store.ListAuctions(ctx, next); err != nil || !more {
+		t.Fatalf("Failed to list the next page: %v", err)
+	}
+	if len(page.Auctions) != 1 || page.Auctions[0].ID != vase.ID || page.NextCursor != "" {
+		t.Errorf("Expected the vase on the last page, got %+v", page)
+	}
+}
@@ -1,152 +1,446 @@
 package storage
 
 import (
-	"errors"
+	"context"
+	"encoding/json"
 	"sync"
 	"time"
 
 	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/search"
 	"github.com/google/uuid"
 )
 
-// MemoryStore provides an in-memory implementation of auction storage
+// MemoryStore provides an in-memory implementation of auction storage. Its
+// operations never wait on anything but its own mutexes, so they ignore
+// their contexts, except to follow events.
 type MemoryStore struct {
 	auctionsMutex sync.RWMutex
 	auctions      map[string]auction.AuctionItem
+	settlements   map[string]auction.Settlement          // Guarded by auctionsMutex
+	proxies       map[string]map[string]auction.ProxyBid // Guarded by auctionsMutex
+	events        map[string][]auction.Event             // Guarded by auctionsMutex
+	participants  map[string]auction.Participant         // Guarded by auctionsMutex
+	idempotency   map[string]idempotentResult            // Guarded by auctionsMutex
+	index         *search.Index                          // Replaced under auctionsMutex
+	// eventsChanged is closed and replaced whenever an event is recorded
+	eventsChanged chan struct{}
 
 	bidsMutex sync.RWMutex
 	bids      map[string][]auction.Bid // Map auction ID to its bids
+	// bidSequences holds the sequence number of the last bid on each auction
+	bidSequences map[string]uint64
+
+	// now and newID are injectable so that replicated state machines can
+	// apply the same commands deterministically on every replica
+	now   func() time.Time
+	newID func() string
+}
+
+// memorySnapshot is the serialized form of a MemoryStore
+type memorySnapshot struct {
+	Auctions     map[string]auction.AuctionItem         `json:"auctions"`
+	Bids         map[string][]auction.Bid               `json:"bids"`
+	BidSequences map[string]uint64                      `json:"bid_sequences"`
+	Settlements  map[string]auction.Settlement          `json:"settlements"`
+	Proxies      map[string]map[string]auction.ProxyBid `json:"proxies"`
+	Events       map[string][]auction.Event             `json:"events"`
+	Participants map[string]auction.Participant         `json:"participants"`
+	Idempotency  map[string]idempotentResult            `json:"idempotency"`
 }
 
 // NewMemoryStore creates a new in-memory store
 func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithClock(time.Now, func() string { return uuid.New().String() })
+}
+
+// NewMemoryStoreWithClock creates a new in-memory store that reads the
+// current time and generates IDs using the given functions
+func NewMemoryStoreWithClock(now func() time.Time, newID func() string) *MemoryStore {
 	return &MemoryStore{
-		auctions: make(map[string]auction.AuctionItem),
-		bids:     make(map[string][]auction.Bid),
+		auctions:      make(map[string]auction.AuctionItem),
+		settlements:   make(map[string]auction.Settlement),
+		proxies:       make(map[string]map[string]auction.ProxyBid),
+		events:        make(map[string][]auction.Event),
+		participants:  make(map[string]auction.Participant),
+		idempotency:   make(map[string]idempotentResult),
+		index:         search.NewIndex(),
+		eventsChanged: make(chan struct{}),
+		bids:          make(map[string][]auction.Bid),
+		bidSequences:  make(map[string]uint64),
+		now:           now,
+		newID:         newID,
+	}
+}
+
+// Snapshot serializes the full contents of the store
+func (m *MemoryStore) Snapshot() ([]byte, error) {
+	m.auctionsMutex.RLock()
+	defer m.auctionsMutex.RUnlock()
+	m.bidsMutex.RLock()
+	defer m.bidsMutex.RUnlock()
+
+	return json.Marshal(memorySnapshot{
+		Auctions:     m.auctions,
+		Bids:         m.bids,
+		BidSequences: m.bidSequences,
+		Settlements:  m.settlements,
+		Proxies:      m.proxies,
+		Events:       m.events,
+		Participants: m.participants,
+		Idempotency:  m.idempotency,
+	})
+}
+
+// Restore replaces the contents of the store with a snapshot
+func (m *MemoryStore) Restore(data []byte) error {
+	var snap memorySnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if snap.Auctions == nil {
+		snap.Auctions = make(map[string]auction.AuctionItem)
+	}
+	if snap.Bids == nil {
+		snap.Bids = make(map[string][]auction.Bid)
+	}
+	if snap.BidSequences == nil {
+		// Snapshots taken before bids were numbered start after the bids
+		// already stored
+		snap.BidSequences = make(map[string]uint64)
+		for id, bids := range snap.Bids {
+			snap.BidSequences[id] = uint64(len(bids))
+		}
+	}
+	if snap.Settlements == nil {
+		snap.Settlements = make(map[string]auction.Settlement)
+	}
+	if snap.Proxies == nil {
+		snap.Proxies = make(map[string]map[string]auction.ProxyBid)
+	}
+	if snap.Events == nil {
+		snap.Events = make(map[string][]auction.Event)
+	}
+	if snap.Participants == nil {
+		snap.Participants = make(map[string]auction.Participant)
+	}
+	if snap.Idempotency == nil {
+		snap.Idempotency = make(map[string]idempotentResult)
+	}
+
+	m.auctionsMutex.Lock()
+	defer m.auctionsMutex.Unlock()
+	m.bidsMutex.Lock()
+	defer m.bidsMutex.Unlock()
+
+	m.auctions = snap.Auctions
+	m.bids = snap.Bids
+	m.bidSequences = snap.BidSequences
+	m.settlements = snap.Settlements
+	m.proxies = snap.Proxies
+	m.events = snap.Events
+	m.participants = snap.Participants
+	m.idempotency = snap.Idempotency
+	m.index = search.NewIndex()
+	for _, item := range m.auctions {
+		m.index.Put(item)
 	}
+	m.notifyEvents()
+	return nil
 }
 
 // CreateAuction adds a new auction item to the store
-func (m *MemoryStore) CreateAuction(item auction.AuctionItem) (auction.AuctionItem, error) {
+func (m *MemoryStore) CreateAuction(ctx context.Context, item auction.AuctionItem) (auction.AuctionItem, error) {
+	return m.CreateAuctionIdempotent(ctx, IdempotencyKey{}, item)
+}
+
+// CreateAuctionIdempotent creates an auction once per key. A retry with the
+// same key returns the auction the first call created.
+func (m *MemoryStore) CreateAuctionIdempotent(ctx context.Context, key IdempotencyKey, item auction.AuctionItem) (auction.AuctionItem, error) {
 	m.auctionsMutex.Lock()
 	defer m.auctionsMutex.Unlock()
 
+	if result, ok := m.recordedResult(key); ok {
+		return result.createdAuction(key)
+	}
+
 	// Generate a UUID if not provided
 	if item.ID == "" {
-		item.ID = uuid.New().String()
+		item.ID = m.newID()
 	}
 
-	item.CreatedAt = time.Now()
+	item.CreatedAt = m.now()
 	m.auctions[item.ID] = item
+	m.index.Put(item)
 
 	// Initialize an empty bid list for this auction
 	m.bidsMutex.Lock()
 	m.bids[item.ID] = []auction.Bid{}
 	m.bidsMutex.Unlock()
+	m.recordIdempotent(key, idempotentResult{Auction: &item})
 
 	return item, nil
 }
 
-// ListAuctions returns all auction items in the store
-func (m *MemoryStore) ListAuctions() ([]auction.AuctionItem, error) {
+// recordedResult returns the result recorded for a key, if any. The
+// caller must hold auctionsMutex.
+func (m *MemoryStore) recordedResult(key IdempotencyKey) (idempotentResult, bool) {
+	if key.IsZero() {
+		return idempotentResult{}, false
+	}
+	result, ok := m.idempotency[key.name()]
+	return result, ok
+}
+
+// recordIdempotent records the result of a write made with a key. The
+// caller must hold auctionsMutex.
+func (m *MemoryStore) recordIdempotent(key IdempotencyKey, result idempotentResult) {
+	if key.IsZero() {
+		return
+	}
+	result.Fingerprint = key.Fingerprint
+	result.CreatedAt = m.now()
+	m.idempotency[key.name()] = result
+}
+
+// SweepIdempotencyKeys removes the records of keys first used before the
+// given time
+func (m *MemoryStore) SweepIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	m.auctionsMutex.Lock()
+	defer m.auctionsMutex.Unlock()
+
+	removed := 0
+	for name, result := range m.idempotency {
+		if result.CreatedAt.Before(before) {
+			delete(m.idempotency, name)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// ListAuctions returns a page of the auctions matching the query
+func (m *MemoryStore) ListAuctions(ctx context.Context, query auction.ListQuery) (auction.ListPage, error) {
 	m.auctionsMutex.RLock()
 	defer m.auctionsMutex.RUnlock()
 
-	auctions := make([]auction.AuctionItem, 0, len(m.auctions))
+	pager := query.Pager(m.now())
 	for _, item := range m.auctions {
-		auctions = append(auctions, item)
+		pager.Add(item)
 	}
-	return auctions, nil
+	return pager.Page(), nil
+}
+
+// SearchAuctions returns the auctions matching the text and the filters of
+// the query, best match first
+func (m *MemoryStore) SearchAuctions(ctx context.Context, text string, query auction.ListQuery) ([]auction.AuctionItem, error) {
+	m.auctionsMutex.RLock()
+	defer m.auctionsMutex.RUnlock()
+
+	var auctions []auction.AuctionItem
+	for _, hit := range m.index.Search(text) {
+		auctions = append(auctions, m.auctions[hit.ID])
+	}
+	return query.Filter(auctions, m.now()), nil
 }
 
 // GetAuction retrieves an auction by ID
-func (m *MemoryStore) GetAuction(id string) (auction.AuctionItem, error) {
+func (m *MemoryStore) GetAuction(ctx context.Context, id string) (auction.AuctionItem, error) {
 	m.auctionsMutex.RLock()
 	defer m.auctionsMutex.RUnlock()
 
 	item, exists := m.auctions[id]
 	if !exists {
-		return auction.AuctionItem{}, errors.New("auction not found")
+		return auction.AuctionItem{}, ErrAuctionNotFound
 	}
 
 	return item, nil
 }
 
-// PlaceBid adds a new bid to an auction item
-func (m *MemoryStore) PlaceBid(bid auction.Bid) error {
+// PlaceBid adds a new bid to an auction item and returns it as stored
+func (m *MemoryStore) PlaceBid(ctx context.Context, bid auction.Bid) (auction.Bid, error) {
+	return m.PlaceBidIdempotent(ctx, IdempotencyKey{}, bid)
+}
+
+// PlaceBidIdempotent places a bid once per key and returns it as stored. A
+// retry with the same key returns the recorded bid without bidding again.
+func (m *MemoryStore) PlaceBidIdempotent(ctx context.Context, key IdempotencyKey, bid auction.Bid) (auction.Bid, error) {
+	// Hold the auction lock throughout so the auction cannot close underneath
+	// us, and so an expiry extension is applied atomically with the bid
+	m.auctionsMutex.Lock()
+	defer m.auctionsMutex.Unlock()
+
+	if result, ok := m.recordedResult(key); ok {
+		return result.placedBid(key)
+	}
+
 	// Check if auction exists
-	m.auctionsMutex.RLock()
 	auctionItem, exists := m.auctions[bid.AuctionItemID]
-	m.auctionsMutex.RUnlock()
-
 	if !exists {
-		return errors.New("auction not found")
+		return auction.Bid{}, ErrAuctionNotFound
 	}
 
-	// Check if auction has expired
-	if time.Now().After(auctionItem.ExpiryTime) {
-		return errors.New("auction has expired")
+	// Check if auction has been closed or has expired
+	if auctionItem.ClosedAt != nil {
+		return auction.Bid{}, ErrAuctionClosed
+	}
+	if m.now().After(auctionItem.ExpiryTime) {
+		return auction.Bid{}, ErrAuctionExpired
+	}
+	if err := checkBidder(auctionItem, bid.ParticipantID); err != nil {
+		return auction.Bid{}, err
 	}
 
 	m.bidsMutex.Lock()
 	defer m.bidsMutex.Unlock()
 
+	if auctionItem.Type() == auction.TypeDutch {
+		return auction.Bid{}, ErrDutchBid
+	}
+
 	// Check if the bid is higher than the minimum bid
-	if bid.BidPrice < auctionItem.MinimumBid {
-		return errors.New("bid price is lower than minimum bid")
+	if err := auctionItem.CheckBidCurrency(bid); err != nil {
+		return auction.Bid{}, ErrCurrencyMismatch.withMessage(err.Error())
+	}
+	if bid.BidPrice.Less(auctionItem.MinimumBid) {
+		return auction.Bid{}, &BidTooLowError{NextMinimumBid: auctionItem.MinimumBid}
 	}
 
-	// Check if there are existing bids and if current bid is higher
-	bids := m.bids[bid.AuctionItemID]
-	if len(bids) > 0 {
-		highestBid := bids[len(bids)-1]
-		if bid.BidPrice <= highestBid.BidPrice {
-			return errors.New("bid price is not higher than current highest bid")
+	// Sealed bids are hidden, so they are never compared with other bids
+	if auctionItem.IsSealed() {
+		if !bid.MaxBid.IsZero() {
+			return auction.Bid{}, ErrProxyBidSealed
 		}
+		placed := m.placeSealedBid(bid)
+		m.recordEvent(bidPlacedEvent(placed))
+		m.recordIdempotent(key, idempotentResult{Bid: &placed})
+		return placed, nil
+	}
+
+	// A proxy maximum must cover the bid itself
+	if !bid.MaxBid.IsZero() && bid.MaxBid.Less(bid.BidPrice) {
+		return auction.Bid{}, ErrMaxBidTooLow
+	}
+
+	// Check if there are existing bids and if current bid beats the highest by the increment
+	var highestBid *auction.Bid
+	if bids := m.bids[bid.AuctionItemID]; len(bids) > 0 {
+		highestBid = &bids[len(bids)-1]
+	}
+	if next := auctionItem.NextMinimumBid(highestBid); bid.BidPrice.Less(next) {
+		return auction.Bid{}, &BidTooLowError{NextMinimumBid: next}
 	}
 
 	// Generate a UUID if not provided
 	if bid.ID == "" {
-		bid.ID = uuid.New().String()
+		bid.ID = m.newID()
 	}
 
-	// Set timestamp if not provided
-	if bid.Timestamp.IsZero() {
-		bid.Timestamp = time.Now()
-	}
+	// Bids are ordered and checked against the expiry by the server's clock,
+	// never the client's
+	bid.Timestamp = m.now()
+
+	// Push the expiry out if the bid arrived in the final window
+	extended := auctionItem.ExtendForBid(m.now())
 
-	// Add bid to the list (acting as a queue where newest bid is at the end)
+	// Record the participant's hidden maximum, then let proxies respond to the bid
+	proxies := m.proxies[bid.AuctionItemID]
+	if !bid.MaxBid.IsZero() {
+		if proxies == nil {
+			proxies = make(map[string]auction.ProxyBid)
+			m.proxies[bid.AuctionItemID] = proxies
+		}
+		proxies[bid.ParticipantID] = auction.ProxyBid{
+			ParticipantID: bid.ParticipantID,
+			MaxBid:        bid.MaxBid,
+			CreatedAt:     m.now(),
+		}
+	}
+	bid.MaxBid = auction.Money{}
+	bid.Automatic = false
+	automatic := resolveProxyBids(bid, proxies, auctionItem.ProxyIncrement, auctionItem.ReservePrice, m.now(), m.newID)
+
+	// Add bids to the list (acting as a queue where newest bid is at the end)
+	bid.Sequence = m.nextBidSequence(bid.AuctionItemID)
+	for i := range automatic {
+		automatic[i].Sequence = m.nextBidSequence(bid.AuctionItemID)
+	}
 	m.bids[bid.AuctionItemID] = append(m.bids[bid.AuctionItemID], bid)
+	m.bids[bid.AuctionItemID] = append(m.bids[bid.AuctionItemID], automatic...)
 
-	return nil
+	// Bids only go up, so the newest one is the current bid
+	bids := m.bids[bid.AuctionItemID]
+	auctionItem.CurrentBid = bids[len(bids)-1].BidPrice
+	m.auctions[auctionItem.ID] = auctionItem
+
+	for _, placed := range append([]auction.Bid{bid}, automatic...) {
+		m.recordEvent(bidPlacedEvent(placed))
+	}
+	if extended {
+		m.recordEvent(extendedEvent(auctionItem, m.now()))
+	}
+	m.recordIdempotent(key, idempotentResult{Bid: &bid})
+
+	return bid, nil
+}
+
+// placeSealedBid records a participant's sealed bid, replacing any earlier
+// bid of theirs, and returns the bid as stored. The caller must hold bidsMutex.
+func (m *MemoryStore) placeSealedBid(bid auction.Bid) auction.Bid {
+	if bid.ID == "" {
+		bid.ID = m.newID()
+	}
+
+	// Ties are broken by time, so always use the server's clock
+	bid.Timestamp = m.now()
+	bid.Automatic = false
+	bid.Sequence = m.nextBidSequence(bid.AuctionItemID)
+
+	bids := m.bids[bid.AuctionItemID]
+	revised := make([]auction.Bid, 0, len(bids)+1)
+	for _, existing := range bids {
+		if existing.ParticipantID != bid.ParticipantID {
+			revised = append(revised, existing)
+		}
+	}
+	m.bids[bid.AuctionItemID] = append(revised, bid)
+	return bid
+}
+
+// nextBidSequence returns the sequence number of the next bid on an
+// auction. The caller must hold bidsMutex.
+func (m *MemoryStore) nextBidSequence(auctionID string) uint64 {
+	m.bidSequences[auctionID]++
+	return m.bidSequences[auctionID]
 }
 
 // GetHighestBid returns the highest bid for an auction
-func (m *MemoryStore) GetHighestBid(auctionID string) (auction.Bid, error) {
+func (m *MemoryStore) GetHighestBid(ctx context.Context, auctionID string) (auction.Bid, error) {
 	m.bidsMutex.RLock()
 	defer m.bidsMutex.RUnlock()
 
 	bids, exists := m.bids[auctionID]
 	if !exists {
-		return auction.Bid{}, errors.New("auction not found")
+		return auction.Bid{}, ErrAuctionNotFound
 	}
 
 	if len(bids) == 0 {
-		return auction.Bid{}, errors.New("no bids found for this auction")
+		return auction.Bid{}, ErrNoBids
 	}
 
-	// Return the last bid (highest bid)
-	return bids[len(bids)-1], nil
+	// In open auctions this is the last bid, sealed bids can arrive in any order
+	highest, _ := highestBid(bids)
+	return highest, nil
 }
 
 // GetBidHistory returns all bids for an auction
-func (m *MemoryStore) GetBidHistory(auctionID string) ([]auction.Bid, error) {
+func (m *MemoryStore) GetBidHistory(ctx context.Context, auctionID string) ([]auction.Bid, error) {
 	m.bidsMutex.RLock()
 	defer m.bidsMutex.RUnlock()
 
 	bids, exists := m.bids[auctionID]
 	if !exists {
-		return nil, errors.New("auction not found")
+		return nil, ErrAuctionNotFound
 	}
 
 	// Return a copy of the bids slice to prevent modification
@@ -155,3 +449,253 @@ func (m *MemoryStore) GetBidHistory(auctionID string) ([]auction.Bid, error) {
 
 	return result, nil
 }
+
+// CloseAuction settles an expired auction. Closing an auction that has
+// already been closed returns the existing settlement.
+func (m *MemoryStore) CloseAuction(ctx context.Context, id string) (auction.Settlement, error) {
+	m.auctionsMutex.Lock()
+	defer m.auctionsMutex.Unlock()
+
+	item, exists := m.auctions[id]
+	if !exists {
+		return auction.Settlement{}, ErrAuctionNotFound
+	}
+
+	if settlement, closed := m.settlements[id]; closed {
+		return settlement, nil
+	}
+
+	now := m.now()
+	if now.Before(item.ExpiryTime) {
+		return auction.Settlement{}, ErrAuctionNotExpired
+	}
+
+	m.bidsMutex.RLock()
+	settlement := settle(item, m.bids[id], now)
+	m.bidsMutex.RUnlock()
+
+	m.settlements[id] = settlement
+	item.ClosedAt = &now
+	m.auctions[id] = item
+	m.recordEvent(closedEvent(settlement))
+
+	return settlement, nil
+}
+
+// AcceptPrice accepts the current price of a Dutch auction, which closes
+// the auction immediately with the participant as the winner
+func (m *MemoryStore) AcceptPrice(ctx context.Context, auctionID, participantID string) (auction.Settlement, error) {
+	m.auctionsMutex.Lock()
+	defer m.auctionsMutex.Unlock()
+
+	item, exists := m.auctions[auctionID]
+	if !exists {
+		return auction.Settlement{}, ErrAuctionNotFound
+	}
+
+	if item.Type() != auction.TypeDutch {
+		return auction.Settlement{}, ErrNotDutch
+	}
+	if item.ClosedAt != nil {
+		return auction.Settlement{}, ErrAuctionClosed
+	}
+	if err := checkBidder(item, participantID); err != nil {
+		return auction.Settlement{}, err
+	}
+
+	now := m.now()
+	if now.After(item.ExpiryTime) {
+		return auction.Settlement{}, ErrAuctionExpired
+	}
+
+	return m.sellAt(item, participantID, item.CurrentPrice(now), now), nil
+}
+
+// BuyNow buys an English auction at its buy-now price, which closes the
+// auction immediately with the participant as the winner. It is refused
+// once a bid has reached the buy-now price.
+func (m *MemoryStore) BuyNow(ctx context.Context, auctionID, participantID string) (auction.Settlement, error) {
+	m.auctionsMutex.Lock()
+	defer m.auctionsMutex.Unlock()
+
+	item, exists := m.auctions[auctionID]
+	if !exists {
+		return auction.Settlement{}, ErrAuctionNotFound
+	}
+
+	if item.BuyNowPrice.IsZero() {
+		return auction.Settlement{}, ErrNoBuyNow
+	}
+	if item.ClosedAt != nil {
+		return auction.Settlement{}, ErrAuctionClosed
+	}
+	if err := checkBidder(item, participantID); err != nil {
+		return auction.Settlement{}, err
+	}
+
+	now := m.now()
+	if now.After(item.ExpiryTime) {
+		return auction.Settlement{}, ErrAuctionExpired
+	}
+
+	m.bidsMutex.RLock()
+	highest, ok := highestBid(m.bids[auctionID])
+	m.bidsMutex.RUnlock()
+	if ok && !highest.BidPrice.Less(item.BuyNowPrice) {
+		return auction.Settlement{}, ErrBuyNowReached
+	}
+
+	return m.sellAt(item, participantID, item.BuyNowPrice, now), nil
+}
+
+// sellAt closes the auction with a winning bid by the participant at price.
+// The caller must hold auctionsMutex.
+func (m *MemoryStore) sellAt(item auction.AuctionItem, participantID string, price auction.Money, now time.Time) auction.Settlement {
+	bid := auction.Bid{
+		ID:            m.newID(),
+		ParticipantID: participantID,
+		AuctionItemID: item.ID,
+		BidPrice:      price,
+		Timestamp:     now,
+	}
+
+	m.bidsMutex.Lock()
+	bid.Sequence = m.nextBidSequence(item.ID)
+	m.bids[item.ID] = append(m.bids[item.ID], bid)
+	m.bidsMutex.Unlock()
+
+	settlement := settle(item, []auction.Bid{bid}, now)
+	m.settlements[item.ID] = settlement
+	item.CurrentBid = price
+	item.ClosedAt = &now
+	m.auctions[item.ID] = item
+	m.recordEvent(bidPlacedEvent(bid))
+	m.recordEvent(closedEvent(settlement))
+
+	return settlement
+}
+
+// GetSettlement returns the outcome of a closed auction
+func (m *MemoryStore) GetSettlement(ctx context.Context, id string) (auction.Settlement, error) {
+	m.auctionsMutex.RLock()
+	defer m.auctionsMutex.RUnlock()
+
+	if _, exists := m.auctions[id]; !exists {
+		return auction.Settlement{}, ErrAuctionNotFound
+	}
+
+	settlement, closed := m.settlements[id]
+	if !closed {
+		return auction.Settlement{}, ErrAuctionNotClosed
+	}
+
+	return settlement, nil
+}
+
+// UpdateAuction applies a seller's update to an auction that has no bids yet
+func (m *MemoryStore) UpdateAuction(ctx context.Context, id, sellerID string, update auction.AuctionUpdate) (auction.AuctionItem, error) {
+	m.auctionsMutex.Lock()
+	defer m.auctionsMutex.Unlock()
+
+	item, exists := m.auctions[id]
+	if !exists {
+		return auction.AuctionItem{}, ErrAuctionNotFound
+	}
+
+	now := m.now()
+	m.bidsMutex.RLock()
+	bids := len(m.bids[id])
+	m.bidsMutex.RUnlock()
+	if err := checkUpdate(item, sellerID, update, bids, now); err != nil {
+		return auction.AuctionItem{}, err
+	}
+
+	item = update.Apply(item)
+	m.auctions[id] = item
+	m.index.Put(item)
+	m.recordEvent(updatedEvent(item, now))
+
+	return item, nil
+}
+
+// CancelAuction withdraws an open auction, closing it without a sale
+func (m *MemoryStore) CancelAuction(ctx context.Context, id, sellerID, reason string) (auction.Settlement, error) {
+	m.auctionsMutex.Lock()
+	defer m.auctionsMutex.Unlock()
+
+	item, exists := m.auctions[id]
+	if !exists {
+		return auction.Settlement{}, ErrAuctionNotFound
+	}
+
+	now := m.now()
+	if err := checkCancel(item, sellerID, now); err != nil {
+		return auction.Settlement{}, err
+	}
+
+	settlement := cancelSettlement(item, reason, now)
+	m.settlements[id] = settlement
+	item.ClosedAt = &now
+	m.auctions[id] = item
+	m.recordEvent(closedEvent(settlement))
+
+	return settlement, nil
+}
+
+// recordEvent appends an event to the auction's log and wakes subscribers.
+// The caller must hold auctionsMutex.
+func (m *MemoryStore) recordEvent(event auction.Event) {
+	event.Sequence = uint64(len(m.events[event.AuctionID])) + 1
+	m.events[event.AuctionID] = append(m.events[event.AuctionID], event)
+	m.notifyEvents()
+}
+
+// notifyEvents wakes subscribers waiting for events. The caller must hold
+// auctionsMutex for writing.
+func (m *MemoryStore) notifyEvents() {
+	close(m.eventsChanged)
+	m.eventsChanged = make(chan struct{})
+}
+
+// Subscribe streams the events of an auction with a sequence number above after
+func (m *MemoryStore) Subscribe(ctx context.Context, auctionID string, after uint64) (<-chan auction.Event, error) {
+	if _, err := m.GetAuction(ctx, auctionID); err != nil {
+		return nil, err
+	}
+
+	return followEvents(ctx, auctionID, after, func(after uint64) ([]auction.Event, <-chan struct{}, error) {
+		m.auctionsMutex.RLock()
+		defer m.auctionsMutex.RUnlock()
+
+		var events []auction.Event
+		if log := m.events[auctionID]; after < uint64(len(log)) {
+			events = append(events, log[after:]...)
+		}
+		return events, m.eventsChanged, nil
+	}), nil
+}
+
+// RegisterParticipant adds a participant to the registry
+func (m *MemoryStore) RegisterParticipant(ctx context.Context, participant auction.Participant) (auction.Participant, error) {
+	m.auctionsMutex.Lock()
+	defer m.auctionsMutex.Unlock()
+
+	if _, exists := m.participants[participant.ID]; exists {
+		return auction.Participant{}, ErrParticipantExists
+	}
+	participant.CreatedAt = m.now()
+	m.participants[participant.ID] = participant
+	return participant, nil
+}
+
+// GetParticipant retrieves a participant by ID
+func (m *MemoryStore) GetParticipant(ctx context.Context, id string) (auction.Participant, error) {
+	m.auctionsMutex.RLock()
+	defer m.auctionsMutex.RUnlock()
+
+	participant, exists := m.participants[id]
+	if !exists {
+		return auction.Participant{}, ErrParticipantNotFound
+	}
+	return participant, nil
+}
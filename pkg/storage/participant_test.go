@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+func TestParticipants(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	registered, err := store.RegisterParticipant(ctx, auction.Participant{ID: "alice", APIKeyHash: "hash"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	if registered.CreatedAt.IsZero() {
+		t.Errorf("Expected the registration time to be set")
+	}
+
+	if _, err := store.RegisterParticipant(ctx, auction.Participant{ID: "alice", APIKeyHash: "other"}); !errors.Is(err, ErrParticipantExists) {
+		t.Errorf("Expected a second alice to fail with ErrParticipantExists, got %v", err)
+	}
+	if _, err := store.GetParticipant(ctx, "bob"); !errors.Is(err, ErrParticipantNotFound) {
+		t.Errorf("Expected ErrParticipantNotFound, got %v", err)
+	}
+
+	// Participants survive a snapshot, so every Raft replica can check keys
+	data, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("Failed to snapshot: %v", err)
+	}
+	restored := NewMemoryStore()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if participant, err := restored.GetParticipant(ctx, "alice"); err != nil || participant.APIKeyHash != "hash" {
+		t.Errorf("Expected the restored store to keep alice's key hash, got %+v, %v", participant, err)
+	}
+}
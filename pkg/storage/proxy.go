@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+// resolveProxyBids returns the automatic bids placed on behalf of proxy
+// bidders after leader became the highest bid. Each round pits the current
+// leader against the strongest other proxy: the weaker side is bid up to its
+// maximum and the stronger side beats it by the increment at that price,
+// capped at its own maximum. Equal maximums go to the proxy registered first.
+// Every round exhausts one proxy, so the loop ends after at most len(proxies) rounds.
+// Once nobody can challenge, a leading proxy whose maximum covers the reserve
+// price is bid up to the reserve so the item will sell.
+func resolveProxyBids(leader auction.Bid, proxies map[string]auction.ProxyBid, increment func(price auction.Money) auction.Money, reserve auction.Money, now time.Time, newID func() string) []auction.Bid {
+	var placed []auction.Bid
+
+	place := func(participantID string, price auction.Money) {
+		leader = auction.Bid{
+			ID:            newID(),
+			ParticipantID: participantID,
+			AuctionItemID: leader.AuctionItemID,
+			BidPrice:      price,
+			Timestamp:     now,
+			Automatic:     true,
+		}
+		placed = append(placed, leader)
+	}
+
+	for {
+		// Find the strongest proxy that could still beat the current price
+		var challenger *auction.ProxyBid
+		for id := range proxies {
+			proxy := proxies[id]
+			if proxy.ParticipantID == leader.ParticipantID || proxy.MaxBid.Less(leader.BidPrice.Add(increment(leader.BidPrice))) {
+				continue
+			}
+			if challenger == nil || challenger.MaxBid.Less(proxy.MaxBid) ||
+				(proxy.MaxBid == challenger.MaxBid && proxy.CreatedAt.Before(challenger.CreatedAt)) {
+				challenger = &proxy
+			}
+		}
+		if challenger == nil {
+			if proxy, ok := proxies[leader.ParticipantID]; ok && leader.BidPrice.Less(reserve) && !proxy.MaxBid.Less(reserve) {
+				place(leader.ParticipantID, reserve)
+			}
+			return placed
+		}
+
+		// Without a proxy the leader is only committed to their current bid
+		defender, hasProxy := proxies[leader.ParticipantID]
+		if !hasProxy || defender.MaxBid.Less(leader.BidPrice) {
+			defender = auction.ProxyBid{ParticipantID: leader.ParticipantID, MaxBid: leader.BidPrice}
+			hasProxy = false
+		}
+
+		challengerWins := defender.MaxBid.Less(challenger.MaxBid) ||
+			(challenger.MaxBid == defender.MaxBid && hasProxy && challenger.CreatedAt.Before(defender.CreatedAt))
+
+		switch {
+		case challengerWins && challenger.MaxBid == defender.MaxBid:
+			place(challenger.ParticipantID, challenger.MaxBid)
+		case challengerWins:
+			if leader.BidPrice.Less(defender.MaxBid) {
+				place(defender.ParticipantID, defender.MaxBid)
+			}
+			place(challenger.ParticipantID, challenger.MaxBid.Min(defender.MaxBid.Add(increment(defender.MaxBid))))
+		case challenger.MaxBid == defender.MaxBid:
+			place(defender.ParticipantID, defender.MaxBid)
+		default:
+			place(challenger.ParticipantID, challenger.MaxBid)
+			place(defender.ParticipantID, defender.MaxBid.Min(challenger.MaxBid.Add(increment(challenger.MaxBid))))
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+func TestProxyBidding(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	item, _ := store.CreateAuction(ctx, auction.AuctionItem{Name: "Lamp", MinimumBid: usd(10), ExpiryTime: time.Now().Add(time.Hour)})
+
+	// alice bids 10 with a hidden max of 50
+	if _, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: item.ID, BidPrice: usd(10), MaxBid: usd(50)}); err != nil {
+		t.Fatalf("Failed to place proxy bid: %v", err)
+	}
+
+	// bob bids 20 and is immediately outbid by alice's proxy
+	if _, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "bob", AuctionItemID: item.ID, BidPrice: usd(20)}); err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+	highest, _ := store.GetHighestBid(ctx, item.ID)
+	if highest.ParticipantID != "alice" || highest.BidPrice != usd(21) || !highest.Automatic {
+		t.Fatalf("Expected automatic bid of 21 by alice, got %+v", highest)
+	}
+
+	// carol's proxy of 80 beats alice's 50: alice is bid up to 50, carol wins at 51
+	if _, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "carol", AuctionItemID: item.ID, BidPrice: usd(25), MaxBid: usd(80)}); err != nil {
+		t.Fatalf("Failed to place proxy bid: %v", err)
+	}
+	history, _ := store.GetBidHistory(ctx, item.ID)
+	var prices []auction.Money
+	for _, bid := range history {
+		prices = append(prices, bid.BidPrice)
+		if !bid.MaxBid.IsZero() {
+			t.Fatalf("Stored bid leaks its maximum: %+v", bid)
+		}
+	}
+	want := []auction.Money{usd(10), usd(20), usd(21), usd(25), usd(50), usd(51)}
+	if len(prices) != len(want) {
+		t.Fatalf("Expected prices %v, got %v", want, prices)
+	}
+	for i := range want {
+		if prices[i] != want[i] {
+			t.Fatalf("Expected prices %v, got %v", want, prices)
+		}
+	}
+	if history[5].ParticipantID != "carol" || !history[5].Automatic {
+		t.Fatalf("Expected carol to lead with an automatic bid, got %+v", history[5])
+	}
+
+	// A maximum below the bid itself is rejected
+	if _, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "dave", AuctionItemID: item.ID, BidPrice: usd(60), MaxBid: usd(55)}); !errors.Is(err, ErrMaxBidTooLow) {
+		t.Fatalf("Expected max bid below bid price to be rejected with ErrMaxBidTooLow, got %v", err)
+	}
+}
+
+func TestProxyBiddingTieGoesToEarliest(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	item, _ := store.CreateAuction(ctx, auction.AuctionItem{Name: "Vase", MinimumBid: usd(10), ExpiryTime: time.Now().Add(time.Hour)})
+
+	store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: item.ID, BidPrice: usd(10), MaxBid: usd(40)})
+	store.PlaceBid(ctx, auction.Bid{ParticipantID: "bob", AuctionItemID: item.ID, BidPrice: usd(15), MaxBid: usd(40)})
+
+	highest, _ := store.GetHighestBid(ctx, item.ID)
+	if highest.ParticipantID != "alice" || highest.BidPrice != usd(40) {
+		t.Fatalf("Expected alice to hold the lead at 40, got %+v", highest)
+	}
+}
+
+func TestIncrementRuleEnforced(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	item, _ := store.CreateAuction(ctx, auction.AuctionItem{
+		Name:          "Guitar",
+		MinimumBid:    usd(10),
+		ExpiryTime:    time.Now().Add(time.Hour),
+		IncrementRule: &auction.IncrementRule{Type: auction.IncrementFixed, Amount: usd(5)},
+	})
+
+	if _, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: item.ID, BidPrice: usd(20)}); err != nil {
+		t.Fatalf("Failed to place first bid: %v", err)
+	}
+
+	_, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "bob", AuctionItemID: item.ID, BidPrice: usd(22)})
+	var tooLow *BidTooLowError
+	if !errors.As(err, &tooLow) || tooLow.NextMinimumBid != usd(25) {
+		t.Fatalf("Expected the bid to be rejected with a next minimum of 25, got %v", err)
+	}
+
+	// Proxies step by the auction's increment too
+	store.PlaceBid(ctx, auction.Bid{ParticipantID: "bob", AuctionItemID: item.ID, BidPrice: usd(25), MaxBid: usd(100)})
+	store.PlaceBid(ctx, auction.Bid{ParticipantID: "carol", AuctionItemID: item.ID, BidPrice: usd(40)})
+	highest, _ := store.GetHighestBid(ctx, item.ID)
+	if highest.ParticipantID != "bob" || highest.BidPrice != usd(45) {
+		t.Fatalf("Expected bob's proxy to lead at 45, got %+v", highest)
+	}
+}
+
+func TestAnyHigherBidWithoutIncrementRule(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	item, _ := store.CreateAuction(ctx, auction.AuctionItem{Name: "Kettle", MinimumBid: usd(10), ExpiryTime: time.Now().Add(time.Hour)})
+
+	if _, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: item.ID, BidPrice: usd(20)}); err != nil {
+		t.Fatalf("Failed to place first bid: %v", err)
+	}
+	if _, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "bob", AuctionItemID: item.ID, BidPrice: usd(20.01)}); err != nil {
+		t.Fatalf("Expected a bid one cent higher to be accepted, got %v", err)
+	}
+	_, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "carol", AuctionItemID: item.ID, BidPrice: usd(20.01)})
+	var tooLow *BidTooLowError
+	if !errors.As(err, &tooLow) || tooLow.NextMinimumBid != usd(20.02) {
+		t.Fatalf("Expected an equal bid to be rejected with a next minimum of 20.02, got %v", err)
+	}
+}
+
+func TestBidCurrencyMustMatch(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	item, _ := store.CreateAuction(ctx, auction.AuctionItem{
+		Name:       "Watch",
+		MinimumBid: auction.NewMoney(1000, "EUR"),
+		ExpiryTime: time.Now().Add(time.Hour),
+	})
+
+	if _, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: item.ID, BidPrice: usd(20)}); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("Expected a bid in another currency to be rejected with ErrCurrencyMismatch, got %v", err)
+	}
+	if _, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: item.ID, BidPrice: auction.NewMoney(2000, "EUR")}); err != nil {
+		t.Fatalf("Failed to place bid in the auction currency: %v", err)
+	}
+}
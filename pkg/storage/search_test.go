@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+func TestSearchAuctions(t *testing.T) {
+	ctx := context.Background()
+	store, advance := newClockedStore()
+	create := func(item auction.AuctionItem) auction.AuctionItem {
+		item.SellerID = "sam"
+		item.MinimumBid = usd(10)
+		item.ExpiryTime = time.Now().Add(time.Hour)
+		created, err := store.CreateAuction(ctx, item)
+		if err != nil {
+			t.Fatalf("Failed to create auction: %v", err)
+		}
+		return created
+	}
+	desk := create(auction.AuctionItem{Name: "Oak desk", Description: "Room for a lamp", Category: "furniture"})
+	lamp := create(auction.AuctionItem{Name: "Desk lamp", Category: "lighting", Tags: []string{"brass"}})
+
+	results, err := store.SearchAuctions(ctx, "desk lamp", auction.ListQuery{})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != lamp.ID {
+		t.Fatalf("Expected the lamp to rank first, got %+v", results)
+	}
+	if results, _ := store.SearchAuctions(ctx, "desk", auction.ListQuery{Category: "furniture"}); len(results) != 1 || results[0].ID != desk.ID {
+		t.Errorf("Expected the category to narrow the results, got %+v", results)
+	}
+
+	// Edits are indexed straight away
+	tags := []string{"walnut"}
+	if _, err := store.UpdateAuction(ctx, desk.ID, "sam", auction.AuctionUpdate{Tags: &tags}); err != nil {
+		t.Fatalf("Failed to update auction: %v", err)
+	}
+	if results, _ := store.SearchAuctions(ctx, "walnut", auction.ListQuery{}); len(results) != 1 || results[0].ID != desk.ID {
+		t.Errorf("Expected the new tag to be found, got %+v", results)
+	}
+
+	// A restored snapshot is indexed too
+	snapshot, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("Failed to snapshot: %v", err)
+	}
+	restored := NewMemoryStore()
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if results, _ := restored.SearchAuctions(ctx, "brass", auction.ListQuery{}); len(results) != 1 || results[0].ID != lamp.ID {
+		t.Errorf("Expected the restored store to find the lamp, got %+v", results)
+	}
+
+	advance(2 * time.Hour)
+	if results, _ := store.SearchAuctions(ctx, "desk", auction.ListQuery{Status: auction.StatusActive}); len(results) != 0 {
+		t.Errorf("Expected no active auctions after expiry, got %+v", results)
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+// checkSeller checks that sellerID may change the auction. Auctions created
+// before sellers were recorded have no seller, so nobody may change them.
+func checkSeller(item auction.AuctionItem, sellerID string) error {
+	if item.SellerID == "" || item.SellerID != sellerID {
+		return ErrNotSeller
+	}
+	return nil
+}
+
+// checkBidder checks that a participant may bid on or buy the auction,
+// which its seller may not
+func checkBidder(item auction.AuctionItem, participantID string) error {
+	if item.SellerID != "" && item.SellerID == participantID {
+		return ErrSellerBid
+	}
+	return nil
+}
+
+// checkUpdate checks that the seller may apply update to an auction with
+// the given number of bids. Edits are only allowed before the first bid,
+// so nobody bids on terms that later change.
+func checkUpdate(item auction.AuctionItem, sellerID string, update auction.AuctionUpdate, bids int, now time.Time) error {
+	if err := checkCancel(item, sellerID, now); err != nil {
+		return err
+	}
+	if bids > 0 {
+		return ErrAuctionHasBids
+	}
+	if update.ExpiryTime != nil && !update.ExpiryTime.After(item.ExpiryTime) {
+		return ErrExpiryNotExtended
+	}
+	return nil
+}
+
+// checkCancel checks that the seller may cancel the auction, which must
+// still be open
+func checkCancel(item auction.AuctionItem, sellerID string, now time.Time) error {
+	if err := checkSeller(item, sellerID); err != nil {
+		return err
+	}
+	if item.ClosedAt != nil {
+		return ErrAuctionClosed
+	}
+	if now.After(item.ExpiryTime) {
+		return ErrAuctionExpired
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+func TestSellerActions(t *testing.T) {
+	ctx := context.Background()
+	store, advance := newClockedStore()
+	item, _ := store.CreateAuction(ctx, auction.AuctionItem{
+		Name:         "Clock",
+		SellerID:     "sam",
+		MinimumBid:   usd(10),
+		ReservePrice: usd(50),
+		ExpiryTime:   time.Now().Add(time.Hour),
+	})
+
+	description := "Working grandfather clock"
+	later := item.ExpiryTime.Add(time.Hour)
+	if _, err := store.UpdateAuction(ctx, item.ID, "alice", auction.AuctionUpdate{Description: &description}); !errors.Is(err, ErrNotSeller) {
+		t.Errorf("Expected ErrNotSeller, got %v", err)
+	}
+	if _, err := store.UpdateAuction(ctx, item.ID, "sam", auction.AuctionUpdate{ExpiryTime: &item.ExpiryTime}); !errors.Is(err, ErrExpiryNotExtended) {
+		t.Errorf("Expected ErrExpiryNotExtended, got %v", err)
+	}
+	updated, err := store.UpdateAuction(ctx, item.ID, "sam", auction.AuctionUpdate{Description: &description, ExpiryTime: &later})
+	if err != nil {
+		t.Fatalf("Failed to update auction: %v", err)
+	}
+	if updated.Description != description || !updated.ExpiryTime.Equal(later) || updated.Name != "Clock" {
+		t.Errorf("Expected only the description and expiry to change, got %+v", updated)
+	}
+
+	if _, err := store.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "sam", BidPrice: usd(60)}); !errors.Is(err, ErrSellerBid) {
+		t.Errorf("Expected the seller's bid to fail with ErrSellerBid, got %v", err)
+	}
+
+	advance(time.Second)
+	if _, err := store.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "alice", BidPrice: usd(60)}); err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+	if _, err := store.UpdateAuction(ctx, item.ID, "sam", auction.AuctionUpdate{Description: &description}); !errors.Is(err, ErrAuctionHasBids) {
+		t.Errorf("Expected ErrAuctionHasBids, got %v", err)
+	}
+
+	// Cancelling ends the auction without a sale, even with a bid above the reserve
+	settlement, err := store.CancelAuction(ctx, item.ID, "sam", "Sold elsewhere")
+	if err != nil {
+		t.Fatalf("Failed to cancel auction: %v", err)
+	}
+	if settlement.Outcome != auction.OutcomeCancelled || settlement.Reason != "Sold elsewhere" || settlement.WinnerID != "" {
+		t.Errorf("Expected a cancellation without a winner, got %+v", settlement)
+	}
+	if _, err := store.CancelAuction(ctx, item.ID, "sam", "Again"); !errors.Is(err, ErrAuctionClosed) {
+		t.Errorf("Expected a second cancellation to fail with ErrAuctionClosed, got %v", err)
+	}
+	if _, err := store.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "bob", BidPrice: usd(70)}); !errors.Is(err, ErrAuctionClosed) {
+		t.Errorf("Expected a bid on a cancelled auction to fail with ErrAuctionClosed, got %v", err)
+	}
+
+	// The closer leaves the cancellation in place once the auction expires
+	advance(3 * time.Hour)
+	if closed, err := store.CloseAuction(ctx, item.ID); err != nil || closed.Outcome != auction.OutcomeCancelled {
+		t.Errorf("Expected closing to return the cancellation, got %+v, %v", closed, err)
+	}
+
+	// Subscribers see the edit and the cancellation, but not the reserve
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := store.Subscribe(ctx, item.ID, 0)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	var types []auction.EventType
+	for i := 0; i < 3; i++ {
+		event := <-events
+		types = append(types, event.Type)
+		if event.Auction != nil && !event.Auction.ReservePrice.IsZero() {
+			t.Errorf("Expected the updated event to hide the reserve, got %+v", event.Auction)
+		}
+	}
+	want := []auction.EventType{auction.EventUpdated, auction.EventBidPlaced, auction.EventClosed}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("Expected events %v, got %v", want, types)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+func TestBidSequence(t *testing.T) {
+	ctx := context.Background()
+	store, advance := newClockedStore()
+	item, _ := store.CreateAuction(ctx, auction.AuctionItem{Name: "Lamp", SellerID: "sam", MinimumBid: usd(10), BuyNowPrice: usd(100), ExpiryTime: time.Now().Add(time.Hour)})
+
+	placed, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: item.ID, BidPrice: usd(10), MaxBid: usd(50)})
+	if err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+	if placed.ID == "" || placed.Timestamp.IsZero() || placed.Sequence != 1 || !placed.MaxBid.IsZero() {
+		t.Errorf("Expected the stored bid with sequence 1 and no maximum, got %+v", placed)
+	}
+
+	// Automatic bids are numbered after the bid they answer
+	placed, _ = store.PlaceBid(ctx, auction.Bid{ParticipantID: "bob", AuctionItemID: item.ID, BidPrice: usd(20)})
+	if placed.Sequence != 2 {
+		t.Errorf("Expected sequence 2, got %d", placed.Sequence)
+	}
+	if _, err := store.BuyNow(ctx, item.ID, "carol"); err != nil {
+		t.Fatalf("Failed to buy: %v", err)
+	}
+	history, _ := store.GetBidHistory(ctx, item.ID)
+	for i, bid := range history {
+		if bid.Sequence != uint64(i+1) {
+			t.Errorf("Expected bid %d to have sequence %d, got %d", i, i+1, bid.Sequence)
+		}
+	}
+	if len(history) != 4 || !history[2].Automatic || history[3].ParticipantID != "carol" {
+		t.Errorf("Expected bob's bid, alice's automatic answer and carol's purchase, got %+v", history)
+	}
+
+	// A revised sealed bid takes the next number, so numbers can skip
+	sealed, _ := store.CreateAuction(ctx, auction.AuctionItem{Name: "Vase", SellerID: "sam", AuctionType: auction.TypeSealedFirstPrice, MinimumBid: usd(10), ExpiryTime: time.Now().Add(time.Hour)})
+	store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: sealed.ID, BidPrice: usd(20)})
+	store.PlaceBid(ctx, auction.Bid{ParticipantID: "bob", AuctionItemID: sealed.ID, BidPrice: usd(30)})
+	advance(time.Second)
+	revised, _ := store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: sealed.ID, BidPrice: usd(40)})
+	if revised.Sequence != 3 {
+		t.Errorf("Expected the revised bid to have sequence 3, got %d", revised.Sequence)
+	}
+	history, _ = store.GetBidHistory(ctx, sealed.ID)
+	if len(history) != 2 || history[0].Sequence != 2 || history[1].Sequence != 3 {
+		t.Errorf("Expected bids 2 and 3, got %+v", history)
+	}
+
+	// Numbering carries on after a snapshot
+	snapshot, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("Failed to snapshot: %v", err)
+	}
+	restored := NewMemoryStore()
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if placed, err := restored.PlaceBid(ctx, auction.Bid{ParticipantID: "carol", AuctionItemID: sealed.ID, BidPrice: usd(50)}); err != nil || placed.Sequence != 4 {
+		t.Errorf("Expected the restored store to number the next bid 4, got %+v, %v", placed, err)
+	}
+}
+
+func TestBidSequenceFromZnodeName(t *testing.T) {
+	tests := []struct {
+		child string
+		want  uint64
+		ok    bool
+	}{
+		{"bid-0000000000", 1, true},
+		{"bid-0000000041", 42, true},
+		{"sealed-alice-0000000007", 8, true},
+		{"sealed-a-b-0000000007", 8, true},
+		// Sealed bids stored before they were numbered
+		{"sealed-alice", 0, false},
+		{"sealed-1234567890", 0, false},
+		{"bid-12", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := bidSequence(tt.child); got != tt.want || ok != tt.ok {
+			t.Errorf("bidSequence(%q): expected %d, %t, got %d, %t", tt.child, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestBidTimestampFromStoreClock(t *testing.T) {
+	ctx := context.Background()
+	store, advance := newClockedStore()
+	now := store.now()
+	item, _ := store.CreateAuction(ctx, auction.AuctionItem{Name: "Lamp", MinimumBid: usd(10), ExpiryTime: now.Add(time.Hour)})
+
+	// A bid claiming an earlier time is stamped with the store's
+	backdated := now.Add(-30 * time.Minute)
+	placed, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: item.ID, BidPrice: usd(10), Timestamp: backdated})
+	if err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+	if !placed.Timestamp.Equal(now) {
+		t.Errorf("Expected the bid to be stamped %v, got %v", now, placed.Timestamp)
+	}
+
+	// Nor does it get a bid in after the expiry
+	advance(2 * time.Hour)
+	_, err = store.PlaceBid(ctx, auction.Bid{ParticipantID: "bob", AuctionItemID: item.ID, BidPrice: usd(20), Timestamp: backdated})
+	if !errors.Is(err, ErrAuctionExpired) {
+		t.Errorf("Expected a backdated bid after the expiry to fail with ErrAuctionExpired, got %v", err)
+	}
+}
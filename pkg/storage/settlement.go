@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+)
+
+// highestBid returns the highest of bids, ties going to the earliest one.
+// The second result is false if there are no bids.
+func highestBid(bids []auction.Bid) (auction.Bid, bool) {
+	var winner *auction.Bid
+	for i := range bids {
+		if winner == nil || winner.BidPrice.Less(bids[i].BidPrice) {
+			winner = &bids[i]
+		}
+	}
+	if winner == nil {
+		return auction.Bid{}, false
+	}
+	return *winner, true
+}
+
+// settle computes the outcome of an auction from its bid history. The
+// highest bid wins, and ties go to the earliest bid. The winner pays their
+// own bid, except in second-price auctions where they pay the second highest
+// bid, or the minimum bid if nobody else bid. If the highest bid is below the
+// reserve price the auction closes without a sale.
+func settle(item auction.AuctionItem, bids []auction.Bid, closedAt time.Time) auction.Settlement {
+	settlement := auction.Settlement{
+		AuctionItemID: item.ID,
+		Outcome:       auction.OutcomeNoSale,
+		ClearingPrice: auction.NewMoney(0, item.Currency()),
+		ClosedAt:      closedAt,
+	}
+
+	winner, ok := highestBid(bids)
+	if !ok {
+		settlement.Reason = auction.ReasonNoBids
+		return settlement
+	}
+	if !item.ReserveMet(winner.BidPrice) {
+		settlement.Reason = auction.ReasonReserveNotMet
+		return settlement
+	}
+
+	settlement.Outcome = auction.OutcomeSold
+	settlement.WinnerID = winner.ParticipantID
+	settlement.WinningBidID = winner.ID
+	settlement.ClearingPrice = winner.BidPrice
+
+	if item.Type() == auction.TypeSealedSecondPrice {
+		// The winner never pays less than the reserve they cleared
+		settlement.ClearingPrice = item.MinimumBid.Max(item.ReservePrice)
+		for _, bid := range bids {
+			if bid.ID != winner.ID && settlement.ClearingPrice.Less(bid.BidPrice) {
+				settlement.ClearingPrice = bid.BidPrice
+			}
+		}
+	}
+
+	return settlement
+}
+
+// cancelSettlement records a seller withdrawing an auction, with the reason
+// they gave
+func cancelSettlement(item auction.AuctionItem, reason string, closedAt time.Time) auction.Settlement {
+	return auction.Settlement{
+		AuctionItemID: item.ID,
+		Outcome:       auction.OutcomeCancelled,
+		Reason:        reason,
+		ClearingPrice: auction.NewMoney(0, item.Currency()),
+		ClosedAt:      closedAt,
+	}
+}
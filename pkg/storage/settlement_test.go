@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/google/uuid"
+)
+
+// usd returns an amount of US dollars given in major units
+func usd(dollars float64) auction.Money {
+	return auction.FromMajor(dollars, "USD")
+}
+
+// newClockedStore returns a MemoryStore whose clock is advanced by the returned function
+func newClockedStore() (*MemoryStore, func(time.Duration)) {
+	now := time.Now()
+	store := NewMemoryStoreWithClock(
+		func() time.Time { return now },
+		func() string { return uuid.New().String() },
+	)
+	return store, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestSealedBidSettlement(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		auctionType auction.AuctionType
+		wantPrice   float64
+	}{
+		{auction.TypeSealedFirstPrice, 40},
+		{auction.TypeSealedSecondPrice, 35},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.auctionType), func(t *testing.T) {
+			store, advance := newClockedStore()
+			item, _ := store.CreateAuction(ctx, auction.AuctionItem{
+				Name:        "Painting",
+				AuctionType: tt.auctionType,
+				MinimumBid:  usd(10),
+				ExpiryTime:  time.Now().Add(time.Hour),
+			})
+
+			bids := []auction.Bid{
+				{ParticipantID: "alice", BidPrice: usd(30)},
+				{ParticipantID: "bob", BidPrice: usd(40)},
+				{ParticipantID: "carol", BidPrice: usd(20)},
+				// alice revises her bid, a lower bid is fine in a sealed auction
+				{ParticipantID: "alice", BidPrice: usd(35)},
+			}
+			for _, bid := range bids {
+				bid.AuctionItemID = item.ID
+				if _, err := store.PlaceBid(ctx, bid); err != nil {
+					t.Fatalf("Failed to place sealed bid: %v", err)
+				}
+				advance(time.Second)
+			}
+
+			history, _ := store.GetBidHistory(ctx, item.ID)
+			if len(history) != 3 {
+				t.Fatalf("Expected one bid per participant, got %d", len(history))
+			}
+
+			advance(time.Hour)
+			settlement, err := store.CloseAuction(ctx, item.ID)
+			if err != nil {
+				t.Fatalf("Failed to close auction: %v", err)
+			}
+			if settlement.WinnerID != "bob" || settlement.ClearingPrice != usd(tt.wantPrice) {
+				t.Fatalf("Expected bob to win at %.2f, got %+v", tt.wantPrice, settlement)
+			}
+		})
+	}
+}
+
+func TestSecondPriceWithSingleBidPaysMinimum(t *testing.T) {
+	ctx := context.Background()
+	store, advance := newClockedStore()
+	item, _ := store.CreateAuction(ctx, auction.AuctionItem{
+		Name:        "Chair",
+		AuctionType: auction.TypeSealedSecondPrice,
+		MinimumBid:  usd(10),
+		ExpiryTime:  time.Now().Add(time.Hour),
+	})
+	store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: item.ID, BidPrice: usd(50)})
+
+	advance(2 * time.Hour)
+	settlement, _ := store.CloseAuction(ctx, item.ID)
+	if settlement.WinnerID != "alice" || settlement.ClearingPrice != usd(10) {
+		t.Fatalf("Expected alice to win at the minimum bid, got %+v", settlement)
+	}
+}
+
+func TestDutchAcceptanceHasSingleWinner(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	item, _ := store.CreateAuction(ctx, auction.AuctionItem{
+		Name:        "Tulips",
+		AuctionType: auction.TypeDutch,
+		MinimumBid:  usd(10),
+		ExpiryTime:  time.Now().Add(time.Hour),
+		Dutch:       &auction.DutchSchedule{StartPrice: usd(50), FloorPrice: usd(10), Decrement: usd(5), Interval: auction.Duration(time.Minute)},
+	})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var winners []auction.Settlement
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			settlement, err := store.AcceptPrice(ctx, item.ID, fmt.Sprintf("buyer-%d", i))
+			if err == nil {
+				mu.Lock()
+				winners = append(winners, settlement)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(winners) != 1 {
+		t.Fatalf("Expected exactly one acceptance to win, got %d", len(winners))
+	}
+	if winners[0].ClearingPrice != usd(50) {
+		t.Fatalf("Expected the start price to be paid, got %v", winners[0].ClearingPrice)
+	}
+	if _, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "late", AuctionItemID: item.ID, BidPrice: usd(60)}); err == nil {
+		t.Fatalf("Dutch auctions should not take bids")
+	}
+}
+
+func TestReservePrice(t *testing.T) {
+	ctx := context.Background()
+	store, advance := newClockedStore()
+	unmet, _ := store.CreateAuction(ctx, auction.AuctionItem{
+		Name:         "Vase",
+		MinimumBid:   usd(10),
+		ReservePrice: usd(100),
+		ExpiryTime:   time.Now().Add(time.Hour),
+	})
+	met, _ := store.CreateAuction(ctx, auction.AuctionItem{
+		Name:         "Lamp",
+		MinimumBid:   usd(10),
+		ReservePrice: usd(100),
+		ExpiryTime:   time.Now().Add(time.Hour),
+	})
+
+	// Bids below the reserve are still accepted
+	if _, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: unmet.ID, BidPrice: usd(50)}); err != nil {
+		t.Fatalf("Bid below the reserve should be accepted: %v", err)
+	}
+
+	// A proxy that covers the reserve is bid straight up to it
+	store.PlaceBid(ctx, auction.Bid{ParticipantID: "bob", AuctionItemID: met.ID, BidPrice: usd(20), MaxBid: usd(150)})
+	highest, _ := store.GetHighestBid(ctx, met.ID)
+	if highest.ParticipantID != "bob" || highest.BidPrice != usd(100) {
+		t.Fatalf("Expected bob's proxy to meet the reserve, got %+v", highest)
+	}
+
+	advance(2 * time.Hour)
+	settlement, _ := store.CloseAuction(ctx, unmet.ID)
+	if settlement.Outcome != auction.OutcomeNoSale || settlement.Reason != auction.ReasonReserveNotMet || settlement.WinnerID != "" {
+		t.Fatalf("Expected no sale with the reserve not met, got %+v", settlement)
+	}
+	settlement, _ = store.CloseAuction(ctx, met.ID)
+	if settlement.Outcome != auction.OutcomeSold || settlement.WinnerID != "bob" || settlement.ClearingPrice != usd(100) {
+		t.Fatalf("Expected bob to buy at the reserve, got %+v", settlement)
+	}
+}
+
+func TestBuyNow(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	newItem := func() auction.AuctionItem {
+		item, _ := store.CreateAuction(ctx, auction.AuctionItem{
+			Name:        "Bike",
+			MinimumBid:  usd(10),
+			BuyNowPrice: usd(100),
+			ExpiryTime:  time.Now().Add(time.Hour),
+		})
+		return item
+	}
+
+	// Buying below the buy-now price closes the auction for the buyer
+	bought := newItem()
+	store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: bought.ID, BidPrice: usd(50)})
+	settlement, err := store.BuyNow(ctx, bought.ID, "bob")
+	if err != nil {
+		t.Fatalf("Failed to buy now: %v", err)
+	}
+	if settlement.WinnerID != "bob" || settlement.ClearingPrice != usd(100) {
+		t.Fatalf("Expected bob to buy at 100, got %+v", settlement)
+	}
+	if _, err := store.BuyNow(ctx, bought.ID, "carol"); !errors.Is(err, ErrAuctionClosed) {
+		t.Fatalf("Expected a second buy to be rejected with ErrAuctionClosed, got %v", err)
+	}
+	if _, err := store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: bought.ID, BidPrice: usd(120)}); err == nil {
+		t.Fatalf("Expected bids after buy-now to be rejected")
+	}
+
+	// Once a bid reaches the buy-now price it is no longer offered
+	reached := newItem()
+	store.PlaceBid(ctx, auction.Bid{ParticipantID: "alice", AuctionItemID: reached.ID, BidPrice: usd(100)})
+	if _, err := store.BuyNow(ctx, reached.ID, "bob"); !errors.Is(err, ErrBuyNowReached) {
+		t.Fatalf("Expected buy-now to be rejected with ErrBuyNowReached after bidding reached it, got %v", err)
+	}
+}
@@ -1,15 +1,104 @@
 package storage
 
 import (
+	"context"
+	"time"
+
 	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
 )
 
-// Store defines the interface for auction storage implementations
+// Store defines the interface for auction storage implementations. Every
+// method takes a context: a store that waits, on a lock or on other servers,
+// gives up with the context's error once it is done.
 type Store interface {
-	CreateAuction(auction.AuctionItem) (auction.AuctionItem, error)
-	ListAuctions() ([]auction.AuctionItem, error)
-	GetAuction(id string) (auction.AuctionItem, error)
-	PlaceBid(bid auction.Bid) error
-	GetHighestBid(auctionID string) (auction.Bid, error)
-	GetBidHistory(auctionID string) ([]auction.Bid, error)
+	CreateAuction(ctx context.Context, item auction.AuctionItem) (auction.AuctionItem, error)
+	// CreateAuctionIdempotent creates an auction once per key. A retry with
+	// the same key, on any server, returns the auction the first call
+	// created.
+	CreateAuctionIdempotent(ctx context.Context, key IdempotencyKey, item auction.AuctionItem) (auction.AuctionItem, error)
+	// ListAuctions returns a page of the auctions matching the query, in
+	// its sort order
+	ListAuctions(ctx context.Context, query auction.ListQuery) (auction.ListPage, error)
+	// SearchAuctions returns the auctions whose name, description, category
+	// or tags contain every term of the text, best match first, keeping
+	// those that match the filters of the query
+	SearchAuctions(ctx context.Context, text string, query auction.ListQuery) ([]auction.AuctionItem, error)
+	GetAuction(ctx context.Context, id string) (auction.AuctionItem, error)
+	// PlaceBid places a bid and returns it as stored, with its ID, timestamp
+	// and sequence number
+	PlaceBid(ctx context.Context, bid auction.Bid) (auction.Bid, error)
+	// PlaceBidIdempotent places a bid once per key and returns it as stored.
+	// A retry with the same key, on any server, returns the recorded bid
+	// without bidding again.
+	PlaceBidIdempotent(ctx context.Context, key IdempotencyKey, bid auction.Bid) (auction.Bid, error)
+	GetHighestBid(ctx context.Context, auctionID string) (auction.Bid, error)
+	GetBidHistory(ctx context.Context, auctionID string) ([]auction.Bid, error)
+	CloseAuction(ctx context.Context, id string) (auction.Settlement, error)
+	AcceptPrice(ctx context.Context, auctionID, participantID string) (auction.Settlement, error)
+	BuyNow(ctx context.Context, auctionID, participantID string) (auction.Settlement, error)
+	GetSettlement(ctx context.Context, id string) (auction.Settlement, error)
+	// UpdateAuction applies a seller's update to an open auction without
+	// bids, failing with ErrNotSeller for anyone else
+	UpdateAuction(ctx context.Context, id, sellerID string, update auction.AuctionUpdate) (auction.AuctionItem, error)
+	// CancelAuction withdraws an open auction for its seller, closing it
+	// without a sale
+	CancelAuction(ctx context.Context, id, sellerID, reason string) (auction.Settlement, error)
+	// Subscribe streams the events of an auction with a sequence number
+	// above after, until ctx is cancelled and the channel is closed
+	Subscribe(ctx context.Context, auctionID string, after uint64) (<-chan auction.Event, error)
+	// RegisterParticipant adds a participant, failing with
+	// ErrParticipantExists if the ID is taken
+	RegisterParticipant(ctx context.Context, participant auction.Participant) (auction.Participant, error)
+	GetParticipant(ctx context.Context, id string) (auction.Participant, error)
+}
+
+// HealthChecker is implemented by stores that depend on a connection to
+// another service, and can tell whether it is up
+type HealthChecker interface {
+	Health() Health
+}
+
+// Health describes the connection of a store to the service it depends on
+type Health struct {
+	// Connected is false while the store cannot serve requests
+	Connected bool `json:"connected"`
+	// State describes the connection, such as "connected" or "expired"
+	State string `json:"state"`
+	// Since is when the connection entered its state
+	Since time.Time `json:"since"`
+	// SessionID identifies the ZooKeeper session, if there is one
+	SessionID string `json:"session_id,omitempty"`
+	// Expirations counts the sessions that expired since the store started
+	Expirations uint64 `json:"expirations"`
+}
+
+// IdempotencySweeper is implemented by stores that record the results of
+// idempotency keys, so old records can be removed
+type IdempotencySweeper interface {
+	// SweepIdempotencyKeys removes the records of keys first used before
+	// the given time, and returns how many it removed
+	SweepIdempotencyKeys(ctx context.Context, before time.Time) (int, error)
+}
+
+// Member is an auction server that is part of a cluster
+type Member struct {
+	// ID identifies the server within the cluster
+	ID string `json:"id"`
+	// URL is the base URL clients reach the server at
+	URL string `json:"url"`
+	// Version is the version of the server
+	Version string `json:"version"`
+	// StartedAt is when the server started
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Registry is implemented by stores shared by several servers, which can
+// tell the servers that are alive
+type Registry interface {
+	// Join registers a server as long as it stays connected to the store
+	Join(ctx context.Context, member Member) error
+	// Leave removes the registration of a server
+	Leave(ctx context.Context, id string) error
+	// Members returns the servers that are registered, ordered by ID
+	Members(ctx context.Context) ([]Member, error)
 }
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"encoding/json"
+	"log"
+	"path"
+	"sync"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/search"
+	"github.com/go-zookeeper/zk"
+)
+
+// auctionCache keeps a local copy of every auction znode and a search
+// index over them, so listing and searching auctions does not read each
+// one. A child watch on the auctions znode reports new auctions, and a
+// data watch on each auction reports changes, whichever server made them.
+// Watches fire once, so a fired watch marks the entry stale and the cache
+// reads it again straight away, setting a new watch.
+type auctionCache struct {
+	conn         zkConn
+	auctionsPath string
+
+	mu    sync.Mutex
+	items map[string]auction.AuctionItem
+	index *search.Index
+	// stale holds the auctions to read again, including new ones
+	stale map[string]bool
+	// watched holds the auctions with a data watch that has not fired yet
+	watched map[string]bool
+	// childrenWatched is cleared when the list of auctions may have changed
+	childrenWatched bool
+}
+
+func newAuctionCache(conn zkConn, basePath string) *auctionCache {
+	return &auctionCache{
+		conn:         conn,
+		auctionsPath: path.Join(basePath, "auctions"),
+		items:        make(map[string]auction.AuctionItem),
+		index:        search.NewIndex(),
+		stale:        make(map[string]bool),
+		watched:      make(map[string]bool),
+	}
+}
+
+// invalidate marks an auction changed by this server, so the change shows
+// up in the next listing without waiting for its watch to fire
+func (c *auctionCache) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stale[id] = true
+}
+
+// page offers every auction to the pager, reading only those that changed
+// since the last sync
+func (c *auctionCache) page(pager *auction.Pager) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.sync(); err != nil {
+		return err
+	}
+	for _, item := range c.items {
+		pager.Add(item)
+	}
+	return nil
+}
+
+// search returns the auctions matching the text, best match first
+func (c *auctionCache) search(text string) ([]auction.AuctionItem, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.sync(); err != nil {
+		return nil, err
+	}
+
+	var auctions []auction.AuctionItem
+	for _, hit := range c.index.Search(text) {
+		auctions = append(auctions, c.items[hit.ID])
+	}
+	return auctions, nil
+}
+
+// update syncs the cache after a watch fires, so the search index follows
+// the auctions znode between reads. A failure is logged and left for the
+// next read to retry.
+func (c *auctionCache) update() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.sync(); err != nil {
+		log.Printf("Failed to sync auctions from ZooKeeper: %v", err)
+	}
+}
+
+// sync reads the auctions that were added or changed since the last sync.
+// The caller must hold mu.
+func (c *auctionCache) sync() error {
+	if !c.childrenWatched {
+		children, _, watch, err := c.conn.ChildrenW(c.auctionsPath)
+		if err != nil {
+			return err
+		}
+		c.childrenWatched = true
+		go func() {
+			event := <-watch
+			c.mu.Lock()
+			c.childrenWatched = false
+			c.mu.Unlock()
+			if event.Type != zk.EventNotWatching {
+				c.update()
+			}
+		}()
+
+		present := make(map[string]bool, len(children))
+		for _, id := range children {
+			present[id] = true
+			if _, known := c.items[id]; !known {
+				c.stale[id] = true
+			}
+		}
+		for id := range c.items {
+			if !present[id] {
+				c.remove(id)
+			}
+		}
+	}
+
+	for id := range c.stale {
+		if err := c.refresh(id); err != nil {
+			return err
+		}
+		delete(c.stale, id)
+	}
+	return nil
+}
+
+// refresh reads an auction and watches it for the next change, unless a
+// watch is already set. The caller must hold mu.
+func (c *auctionCache) refresh(id string) error {
+	var data []byte
+	var watch <-chan zk.Event
+	var err error
+	if c.watched[id] {
+		data, _, err = c.conn.Get(path.Join(c.auctionsPath, id))
+	} else {
+		data, _, watch, err = c.conn.GetW(path.Join(c.auctionsPath, id))
+	}
+	if err == zk.ErrNoNode {
+		c.remove(id)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var item auction.AuctionItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		// Skip items we can't unmarshal, and keep watching for a fix
+		c.remove(id)
+	} else {
+		c.items[id] = item
+		c.index.Put(item)
+	}
+
+	if watch != nil {
+		c.watched[id] = true
+		go func() {
+			event := <-watch
+			c.mu.Lock()
+			c.stale[id] = true
+			delete(c.watched, id)
+			c.mu.Unlock()
+			if event.Type != zk.EventNotWatching {
+				c.update()
+			}
+		}()
+	}
+	return nil
+}
+
+// remove drops an auction from the cache. The caller must hold mu.
+func (c *auctionCache) remove(id string) {
+	delete(c.items, id)
+	c.index.Remove(id)
+}
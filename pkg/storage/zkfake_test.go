@@ -0,0 +1,362 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// fakeZK is an in-memory ZooKeeper for tests. Every connection to it has a
+// session of its own. Versions, sequence numbers and ephemeral znodes
+// follow ZooKeeper, transactions are atomic and watches fire once.
+type fakeZK struct {
+	mu           sync.Mutex
+	nodes        map[string]*fakeZNode
+	sessions     int64
+	protected    int
+	dataWatches  map[string][]chan zk.Event
+	childWatches map[string][]chan zk.Event
+
+	// beforeMulti is called before every transaction, without holding mu,
+	// so a test can commit a racing write between the reads of a store and
+	// its transaction
+	beforeMulti func(ops []interface{})
+}
+
+type fakeZNode struct {
+	data     []byte
+	stat     zk.Stat
+	children map[string]bool
+}
+
+// fakeZKEvent is a watch event to fire once its operation commits
+type fakeZKEvent struct {
+	path  string
+	typ   zk.EventType
+	child bool
+}
+
+func newFakeZK() *fakeZK {
+	return &fakeZK{
+		nodes:        map[string]*fakeZNode{"/": {children: map[string]bool{}}},
+		dataWatches:  make(map[string][]chan zk.Event),
+		childWatches: make(map[string][]chan zk.Event),
+	}
+}
+
+// connect opens a connection with a new session
+func (f *fakeZK) connect() *fakeZKConn {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions++
+	return &fakeZKConn{zk: f, session: f.sessions}
+}
+
+// newStore returns a store connected to the fake with a session of its own
+func (f *fakeZK) newStore(t *testing.T, opts ...ZKOption) *ZKStore {
+	t.Helper()
+	store := newZKStore("/auction", opts...)
+	if err := store.start(f.connect()); err != nil {
+		t.Fatalf("Failed to start store: %v", err)
+	}
+	t.Cleanup(store.Close)
+	return store
+}
+
+// setBeforeMulti sets the hook called before every transaction
+func (f *fakeZK) setBeforeMulti(hook func(ops []interface{})) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.beforeMulti = hook
+}
+
+func (f *fakeZK) create(session int64, p string, data []byte, flags int32) (string, []fakeZKEvent, error) {
+	parentPath := path.Dir(p)
+	parent, ok := f.nodes[parentPath]
+	if !ok {
+		return "", nil, zk.ErrNoNode
+	}
+	if parent.stat.EphemeralOwner != 0 {
+		return "", nil, zk.ErrNoChildrenForEphemerals
+	}
+	if flags&zk.FlagSequence != 0 {
+		p += fmt.Sprintf("%010d", parent.stat.Cversion)
+	}
+	if _, exists := f.nodes[p]; exists {
+		return "", nil, zk.ErrNodeExists
+	}
+
+	now := time.Now().UnixMilli()
+	node := &fakeZNode{
+		data:     append([]byte(nil), data...),
+		stat:     zk.Stat{Ctime: now, Mtime: now},
+		children: map[string]bool{},
+	}
+	if flags&zk.FlagEphemeral != 0 {
+		node.stat.EphemeralOwner = session
+	}
+	f.nodes[p] = node
+	parent.children[path.Base(p)] = true
+	parent.stat.Cversion++
+	parent.stat.NumChildren++
+	return p, []fakeZKEvent{{p, zk.EventNodeCreated, false}, {parentPath, zk.EventNodeChildrenChanged, true}}, nil
+}
+
+func (f *fakeZK) delete(p string, version int32) ([]fakeZKEvent, error) {
+	node, ok := f.nodes[p]
+	if !ok {
+		return nil, zk.ErrNoNode
+	}
+	if version != -1 && version != node.stat.Version {
+		return nil, zk.ErrBadVersion
+	}
+	if len(node.children) > 0 {
+		return nil, zk.ErrNotEmpty
+	}
+	delete(f.nodes, p)
+	parent := f.nodes[path.Dir(p)]
+	delete(parent.children, path.Base(p))
+	parent.stat.Cversion++
+	parent.stat.NumChildren--
+	return []fakeZKEvent{{p, zk.EventNodeDeleted, false}, {p, zk.EventNodeDeleted, true}, {path.Dir(p), zk.EventNodeChildrenChanged, true}}, nil
+}
+
+func (f *fakeZK) set(p string, data []byte, version int32) (*zk.Stat, []fakeZKEvent, error) {
+	node, ok := f.nodes[p]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	if version != -1 && version != node.stat.Version {
+		return nil, nil, zk.ErrBadVersion
+	}
+	node.data = append([]byte(nil), data...)
+	node.stat.Version++
+	node.stat.Mtime = time.Now().UnixMilli()
+	stat := node.stat
+	return &stat, []fakeZKEvent{{p, zk.EventNodeDataChanged, false}}, nil
+}
+
+func (f *fakeZK) check(p string, version int32) error {
+	node, ok := f.nodes[p]
+	if !ok {
+		return zk.ErrNoNode
+	}
+	if version != -1 && version != node.stat.Version {
+		return zk.ErrBadVersion
+	}
+	return nil
+}
+
+// fire sends the events of a committed operation to their watches
+func (f *fakeZK) fire(events []fakeZKEvent) {
+	for _, event := range events {
+		watches := f.dataWatches
+		if event.child {
+			watches = f.childWatches
+		}
+		for _, ch := range watches[event.path] {
+			ch <- zk.Event{Type: event.typ, State: zk.StateHasSession, Path: event.path}
+		}
+		delete(watches, event.path)
+	}
+}
+
+func (f *fakeZK) watch(watches map[string][]chan zk.Event, p string) <-chan zk.Event {
+	ch := make(chan zk.Event, 1)
+	watches[p] = append(watches[p], ch)
+	return ch
+}
+
+// copyNodes returns a deep copy of the tree, to roll back a transaction
+func (f *fakeZK) copyNodes() map[string]*fakeZNode {
+	nodes := make(map[string]*fakeZNode, len(f.nodes))
+	for p, node := range f.nodes {
+		copied := *node
+		copied.children = make(map[string]bool, len(node.children))
+		for child := range node.children {
+			copied.children[child] = true
+		}
+		nodes[p] = &copied
+	}
+	return nodes
+}
+
+// expire ends a session, deleting its ephemeral znodes
+func (f *fakeZK) expire(session int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var owned []string
+	for p, node := range f.nodes {
+		if node.stat.EphemeralOwner == session {
+			owned = append(owned, p)
+		}
+	}
+	for _, p := range owned {
+		events, _ := f.delete(p, -1)
+		f.fire(events)
+	}
+}
+
+// fakeZKConn is a connection to a fakeZK
+type fakeZKConn struct {
+	zk      *fakeZK
+	session int64
+}
+
+func (c *fakeZKConn) Create(p string, data []byte, flags int32, acl []zk.ACL) (string, error) {
+	c.zk.mu.Lock()
+	defer c.zk.mu.Unlock()
+	created, events, err := c.zk.create(c.session, p, data, flags)
+	c.zk.fire(events)
+	return created, err
+}
+
+func (c *fakeZKConn) CreateProtectedEphemeralSequential(p string, data []byte, acl []zk.ACL) (string, error) {
+	c.zk.mu.Lock()
+	c.zk.protected++
+	guid := c.zk.protected
+	c.zk.mu.Unlock()
+	protected := path.Join(path.Dir(p), fmt.Sprintf("_c_%032x-%s", guid, path.Base(p)))
+	return c.Create(protected, data, zk.FlagEphemeral|zk.FlagSequence, acl)
+}
+
+func (c *fakeZKConn) Delete(p string, version int32) error {
+	c.zk.mu.Lock()
+	defer c.zk.mu.Unlock()
+	events, err := c.zk.delete(p, version)
+	c.zk.fire(events)
+	return err
+}
+
+func (c *fakeZKConn) Exists(p string) (bool, *zk.Stat, error) {
+	c.zk.mu.Lock()
+	defer c.zk.mu.Unlock()
+	node, ok := c.zk.nodes[p]
+	if !ok {
+		return false, &zk.Stat{}, nil
+	}
+	stat := node.stat
+	return true, &stat, nil
+}
+
+func (c *fakeZKConn) ExistsW(p string) (bool, *zk.Stat, <-chan zk.Event, error) {
+	c.zk.mu.Lock()
+	defer c.zk.mu.Unlock()
+	watch := c.zk.watch(c.zk.dataWatches, p)
+	node, ok := c.zk.nodes[p]
+	if !ok {
+		return false, &zk.Stat{}, watch, nil
+	}
+	stat := node.stat
+	return true, &stat, watch, nil
+}
+
+func (c *fakeZKConn) Get(p string) ([]byte, *zk.Stat, error) {
+	c.zk.mu.Lock()
+	defer c.zk.mu.Unlock()
+	node, ok := c.zk.nodes[p]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	stat := node.stat
+	return append([]byte(nil), node.data...), &stat, nil
+}
+
+func (c *fakeZKConn) GetW(p string) ([]byte, *zk.Stat, <-chan zk.Event, error) {
+	c.zk.mu.Lock()
+	defer c.zk.mu.Unlock()
+	node, ok := c.zk.nodes[p]
+	if !ok {
+		return nil, nil, nil, zk.ErrNoNode
+	}
+	stat := node.stat
+	return append([]byte(nil), node.data...), &stat, c.zk.watch(c.zk.dataWatches, p), nil
+}
+
+func (c *fakeZKConn) children(p string) ([]string, *zk.Stat, error) {
+	node, ok := c.zk.nodes[p]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	children := make([]string, 0, len(node.children))
+	for child := range node.children {
+		children = append(children, child)
+	}
+	sort.Strings(children)
+	stat := node.stat
+	return children, &stat, nil
+}
+
+func (c *fakeZKConn) Children(p string) ([]string, *zk.Stat, error) {
+	c.zk.mu.Lock()
+	defer c.zk.mu.Unlock()
+	return c.children(p)
+}
+
+func (c *fakeZKConn) ChildrenW(p string) ([]string, *zk.Stat, <-chan zk.Event, error) {
+	c.zk.mu.Lock()
+	defer c.zk.mu.Unlock()
+	children, stat, err := c.children(p)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return children, stat, c.zk.watch(c.zk.childWatches, p), nil
+}
+
+func (c *fakeZKConn) Sync(p string) (string, error) {
+	return p, nil
+}
+
+func (c *fakeZKConn) Multi(ops ...interface{}) ([]zk.MultiResponse, error) {
+	c.zk.mu.Lock()
+	hook := c.zk.beforeMulti
+	c.zk.mu.Unlock()
+	if hook != nil {
+		hook(ops)
+	}
+
+	c.zk.mu.Lock()
+	defer c.zk.mu.Unlock()
+
+	saved := c.zk.copyNodes()
+	resps := make([]zk.MultiResponse, len(ops))
+	var fired []fakeZKEvent
+	for i, op := range ops {
+		var events []fakeZKEvent
+		var err error
+		switch op := op.(type) {
+		case *zk.CreateRequest:
+			resps[i].String, events, err = c.zk.create(c.session, op.Path, op.Data, op.Flags)
+		case *zk.SetDataRequest:
+			resps[i].Stat, events, err = c.zk.set(op.Path, op.Data, op.Version)
+		case *zk.DeleteRequest:
+			events, err = c.zk.delete(op.Path, op.Version)
+		case *zk.CheckVersionRequest:
+			err = c.zk.check(op.Path, op.Version)
+		default:
+			err = fmt.Errorf("unknown operation type %T", op)
+		}
+		if err != nil {
+			c.zk.nodes = saved
+			resps[i].Error = err
+			return resps, err
+		}
+		fired = append(fired, events...)
+	}
+	c.zk.fire(fired)
+	return resps, nil
+}
+
+func (c *fakeZKConn) SessionID() int64 {
+	return c.session
+}
+
+func (c *fakeZKConn) Close() {
+	c.zk.expire(c.session)
+}
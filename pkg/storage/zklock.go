@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// zkLock is an auction lock taken under a session. ZooKeeper releases it
+// when the session expires, so the holder checks it before committing.
+type zkLock struct {
+	conn    zkConn
+	path    string
+	session *zkSession
+	epoch   uint64
+}
+
+// lockAuction acquires the distributed lock that serializes changes to an
+// auction, giving up with the error of ctx once it is done. It follows the
+// recipe of zk.Lock, so servers running either wait for each other: every
+// waiter creates a sequential znode, the lowest one holds the lock, and the
+// others watch the znode just before theirs.
+func (z *ZKStore) lockAuction(ctx context.Context, auctionID string) (*zkLock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	lockPath := path.Join(z.basePath, "locks", auctionID)
+	lock := &zkLock{
+		conn:    z.conn,
+		session: z.session,
+		epoch:   z.session.current(),
+	}
+
+	// The protected create finds its znode again if the connection drops
+	// before the reply arrives
+	node, err := z.conn.CreateProtectedEphemeralSequential(lockPath+"/lock-", nil, zk.WorldACL(zk.PermAll))
+	if err == zk.ErrNoNode {
+		_, err = z.conn.Create(lockPath, []byte{}, 0, zk.WorldACL(zk.PermAll))
+		if err != nil && err != zk.ErrNodeExists {
+			return nil, err
+		}
+		node, err = z.conn.CreateProtectedEphemeralSequential(lockPath+"/lock-", nil, zk.WorldACL(zk.PermAll))
+	}
+	if err != nil {
+		return nil, err
+	}
+	lock.path = node
+
+	seq, err := lockSequence(node)
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+
+	for {
+		children, _, err := z.conn.Children(lockPath)
+		if err != nil {
+			lock.Unlock()
+			return nil, err
+		}
+
+		previous := ""
+		previousSeq := -1
+		for _, child := range children {
+			childSeq, err := lockSequence(child)
+			if err != nil {
+				lock.Unlock()
+				return nil, err
+			}
+			if childSeq < seq && childSeq > previousSeq {
+				previous, previousSeq = child, childSeq
+			}
+		}
+		if previous == "" {
+			return lock, nil
+		}
+
+		// Wait for the znode before ours to go, then look again, since its
+		// owner may have given up rather than held the lock
+		exists, _, watch, err := z.conn.ExistsW(path.Join(lockPath, previous))
+		if err != nil {
+			lock.Unlock()
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		select {
+		case event := <-watch:
+			if event.Err != nil {
+				lock.Unlock()
+				return nil, sessionError(event.Err)
+			}
+		case <-ctx.Done():
+			lock.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// lockSequence returns the sequence number of a lock znode, parsed as
+// zk.Lock does
+func lockSequence(name string) (int, error) {
+	parts := strings.Split(name, "lock-")
+	return strconv.Atoi(parts[len(parts)-1])
+}
+
+// Unlock releases the lock
+func (l *zkLock) Unlock() error {
+	return l.conn.Delete(l.path, -1)
+}
+
+// held fails with the error of ctx once it is done, so that work the
+// caller gave up on is not committed, and with ErrSessionExpired if the
+// session that took the lock has expired. A nil lock, for writes that take
+// none, only checks ctx.
+func (l *zkLock) held(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if l == nil {
+		return nil
+	}
+	return l.session.check(l.epoch)
+}
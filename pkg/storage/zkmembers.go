@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"path"
+	"sort"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// Join registers a server in an ephemeral znode under members. ZooKeeper
+// deletes it if the server loses its session, and the store creates it
+// again when a new session starts. Joining with the ID of another live
+// server fails with ErrMemberExists.
+func (z *ZKStore) Join(ctx context.Context, member Member) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(member)
+	if err != nil {
+		return err
+	}
+	err = z.registerEphemeral(z.memberPath(member.ID), data)
+	if err == zk.ErrNodeExists {
+		return ErrMemberExists
+	}
+	return err
+}
+
+// Leave deletes the registration of a server
+func (z *ZKStore) Leave(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return z.unregisterEphemeral(z.memberPath(id))
+}
+
+// Members returns the servers registered under members, ordered by ID
+func (z *ZKStore) Members(ctx context.Context) ([]Member, error) {
+	membersPath := path.Join(z.basePath, "members")
+	if _, err := z.conn.Sync(membersPath); err != nil {
+		return nil, err
+	}
+	children, _, err := z.conn.Children(membersPath)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]Member, 0, len(children))
+	for _, child := range children {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data, _, err := z.conn.Get(path.Join(membersPath, child))
+		if err == zk.ErrNoNode {
+			// The server left since the children were listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var member Member
+		if err := json.Unmarshal(data, &member); err != nil {
+			continue
+		}
+		members = append(members, member)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].ID < members[j].ID
+	})
+	return members, nil
+}
+
+// memberPath returns the znode registering a server. IDs are escaped, since
+// they may be addresses.
+func (z *ZKStore) memberPath(id string) string {
+	return path.Join(z.basePath, "members", url.PathEscape(id))
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestZKJoinRejectsDuplicateID(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeZK()
+	first, second := fake.newStore(t), fake.newStore(t)
+	second.staleWait = 20 * time.Millisecond
+
+	if err := first.Join(ctx, Member{ID: "s1", URL: "http://first"}); err != nil {
+		t.Fatalf("Failed to join: %v", err)
+	}
+
+	// A live server keeps its ID
+	if err := second.Join(ctx, Member{ID: "s1", URL: "http://second"}); !errors.Is(err, ErrMemberExists) {
+		t.Fatalf("Expected ErrMemberExists, got %v", err)
+	}
+	members, _ := second.Members(ctx)
+	if len(members) != 1 || members[0].URL != "http://first" {
+		t.Fatalf("Expected the first server to stay registered, got %+v", members)
+	}
+
+	// A server that stopped without leaving gives its ID up once its
+	// session expires
+	second.staleWait = time.Second
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		fake.expire(first.conn.SessionID())
+	}()
+	if err := second.Join(ctx, Member{ID: "s1", URL: "http://second"}); err != nil {
+		t.Fatalf("Expected to take over the ID of an expired server, got %v", err)
+	}
+	members, _ = second.Members(ctx)
+	if len(members) != 1 || members[0].URL != "http://second" {
+		t.Fatalf("Expected the second server to be registered, got %+v", members)
+	}
+}
+
+func TestZKJoinReplacesOwnStaleRegistration(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeZK()
+	store := fake.newStore(t)
+	store.staleWait = 20 * time.Millisecond
+	if err := store.Join(ctx, Member{ID: "s1", URL: "http://old"}); err != nil {
+		t.Fatalf("Failed to join: %v", err)
+	}
+
+	// A new session starts before ZooKeeper removes the znode of the old one
+	conn := store.conn.zkClient.(*fakeZKConn)
+	fake.mu.Lock()
+	fake.sessions++
+	conn.session = fake.sessions
+	fake.mu.Unlock()
+
+	if err := store.createEphemerals(); err != nil {
+		t.Fatalf("Expected the old registration to be replaced, got %v", err)
+	}
+	_, stat, _ := conn.Exists(store.memberPath("s1"))
+	if stat.EphemeralOwner != conn.session {
+		t.Fatalf("Expected the registration to belong to session %d, got %d", conn.session, stat.EphemeralOwner)
+	}
+}
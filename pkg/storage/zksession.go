@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// zkSession follows the state of the ZooKeeper session from the events of
+// the connection. When a session expires, ZooKeeper drops its ephemeral
+// znodes, including the locks it held, and the connection starts a new
+// session. Each expiry starts a new epoch, so work begun under a lock can
+// tell that the lock is gone.
+type zkSession struct {
+	// renewed is called, without holding mu, when a session starts after
+	// one expired
+	renewed func()
+
+	mu    sync.Mutex
+	state zk.State
+	since time.Time
+	epoch uint64
+	// expired is set from the expiry of a session until the next one starts
+	expired bool
+}
+
+func newZKSession(renewed func()) *zkSession {
+	return &zkSession{
+		renewed: renewed,
+		state:   zk.StateConnecting,
+		since:   time.Now(),
+	}
+}
+
+// handle records a change in the state of the session. It is called by the
+// connection for every event, so it must not block.
+func (s *zkSession) handle(event zk.Event) {
+	if event.Type != zk.EventSession {
+		return
+	}
+
+	s.mu.Lock()
+	if event.State == s.state {
+		s.mu.Unlock()
+		return
+	}
+	s.state = event.State
+	s.since = time.Now()
+	renewed := false
+	switch event.State {
+	case zk.StateExpired:
+		s.epoch++
+		s.expired = true
+	case zk.StateHasSession:
+		renewed = s.expired
+		s.expired = false
+	}
+	s.mu.Unlock()
+
+	log.Printf("ZooKeeper session %s", sessionStateName(event.State))
+	if renewed && s.renewed != nil {
+		s.renewed()
+	}
+}
+
+// current returns the epoch of the current session
+func (s *zkSession) current() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.epoch
+}
+
+// check fails with ErrSessionExpired if the session of the given epoch has
+// expired since
+func (s *zkSession) check(epoch uint64) error {
+	if s.current() != epoch {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// health reports the state of the session
+func (s *zkSession) health() Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Health{
+		Connected:   s.state == zk.StateHasSession,
+		State:       sessionStateName(s.state),
+		Since:       s.since,
+		Expirations: s.epoch,
+	}
+}
+
+// sessionStateName describes a session state for logs and health checks
+func sessionStateName(state zk.State) string {
+	switch state {
+	case zk.StateHasSession:
+		return "connected"
+	case zk.StateConnecting, zk.StateConnected:
+		return "connecting"
+	case zk.StateDisconnected:
+		return "disconnected"
+	case zk.StateExpired:
+		return "expired"
+	case zk.StateAuthFailed:
+		return "auth_failed"
+	default:
+		return "unknown"
+	}
+}
+
+// sessionError replaces the errors of the zk package that mean the session
+// or the connection was lost with store errors, which the API reports as
+// unavailable so that clients try again
+func sessionError(err error) error {
+	switch err {
+	case zk.ErrSessionExpired:
+		return ErrSessionExpired
+	case zk.ErrConnectionClosed, zk.ErrNoServer, zk.ErrClosing:
+		return ErrConnectionLost
+	}
+	return err
+}
+
+// zkClient is the part of a ZooKeeper connection the store uses, as
+// implemented by *zk.Conn
+type zkClient interface {
+	Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error)
+	CreateProtectedEphemeralSequential(path string, data []byte, acl []zk.ACL) (string, error)
+	Delete(path string, version int32) error
+	Exists(path string) (bool, *zk.Stat, error)
+	ExistsW(path string) (bool, *zk.Stat, <-chan zk.Event, error)
+	Get(path string) ([]byte, *zk.Stat, error)
+	GetW(path string) ([]byte, *zk.Stat, <-chan zk.Event, error)
+	Children(path string) ([]string, *zk.Stat, error)
+	ChildrenW(path string) ([]string, *zk.Stat, <-chan zk.Event, error)
+	Sync(path string) (string, error)
+	Multi(ops ...interface{}) ([]zk.MultiResponse, error)
+	SessionID() int64
+	Close()
+}
+
+// zkConn is a ZooKeeper connection whose operations fail with
+// ErrSessionExpired or ErrConnectionLost when the session or the
+// connection is lost
+type zkConn struct {
+	zkClient
+}
+
+func (c zkConn) Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error) {
+	created, err := c.zkClient.Create(path, data, flags, acl)
+	return created, sessionError(err)
+}
+
+func (c zkConn) Delete(path string, version int32) error {
+	return sessionError(c.zkClient.Delete(path, version))
+}
+
+func (c zkConn) Exists(path string) (bool, *zk.Stat, error) {
+	exists, stat, err := c.zkClient.Exists(path)
+	return exists, stat, sessionError(err)
+}
+
+func (c zkConn) CreateProtectedEphemeralSequential(path string, data []byte, acl []zk.ACL) (string, error) {
+	created, err := c.zkClient.CreateProtectedEphemeralSequential(path, data, acl)
+	return created, sessionError(err)
+}
+
+func (c zkConn) ExistsW(path string) (bool, *zk.Stat, <-chan zk.Event, error) {
+	exists, stat, watch, err := c.zkClient.ExistsW(path)
+	return exists, stat, watch, sessionError(err)
+}
+
+func (c zkConn) Get(path string) ([]byte, *zk.Stat, error) {
+	data, stat, err := c.zkClient.Get(path)
+	return data, stat, sessionError(err)
+}
+
+func (c zkConn) GetW(path string) ([]byte, *zk.Stat, <-chan zk.Event, error) {
+	data, stat, watch, err := c.zkClient.GetW(path)
+	return data, stat, watch, sessionError(err)
+}
+
+func (c zkConn) Children(path string) ([]string, *zk.Stat, error) {
+	children, stat, err := c.zkClient.Children(path)
+	return children, stat, sessionError(err)
+}
+
+func (c zkConn) ChildrenW(path string) ([]string, *zk.Stat, <-chan zk.Event, error) {
+	children, stat, watch, err := c.zkClient.ChildrenW(path)
+	return children, stat, watch, sessionError(err)
+}
+
+func (c zkConn) Sync(path string) (string, error) {
+	synced, err := c.zkClient.Sync(path)
+	return synced, sessionError(err)
+}
+
+func (c zkConn) Multi(ops ...interface{}) ([]zk.MultiResponse, error) {
+	resps, err := c.zkClient.Multi(ops...)
+	return resps, sessionError(err)
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-zookeeper/zk"
+)
+
+func TestZKSessionState(t *testing.T) {
+	ctx := context.Background()
+	renewals := 0
+	session := newZKSession(func() { renewals++ })
+	if session.health().Connected {
+		t.Error("Expected no session before connecting")
+	}
+
+	session.handle(zk.Event{Type: zk.EventSession, State: zk.StateHasSession})
+	if health := session.health(); !health.Connected || health.State != "connected" {
+		t.Errorf("Expected a connected session, got %+v", health)
+	}
+	lock := &zkLock{session: session, epoch: session.current()}
+
+	// Watch events and reconnecting with the same session change nothing
+	session.handle(zk.Event{Type: zk.EventNodeDataChanged, State: zk.StateDisconnected})
+	session.handle(zk.Event{Type: zk.EventSession, State: zk.StateDisconnected})
+	if health := session.health(); health.Connected || health.State != "disconnected" {
+		t.Errorf("Expected a disconnected session, got %+v", health)
+	}
+	session.handle(zk.Event{Type: zk.EventSession, State: zk.StateHasSession})
+	if err := lock.held(ctx); err != nil || renewals != 0 {
+		t.Errorf("Expected the lock to survive a reconnection, got %v after %d renewals", err, renewals)
+	}
+
+	// An expired session loses its locks, and the next one is a renewal
+	session.handle(zk.Event{Type: zk.EventSession, State: zk.StateDisconnected})
+	session.handle(zk.Event{Type: zk.EventSession, State: zk.StateExpired})
+	if err := lock.held(ctx); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("Expected ErrSessionExpired, got %v", err)
+	}
+	session.handle(zk.Event{Type: zk.EventSession, State: zk.StateConnecting})
+	session.handle(zk.Event{Type: zk.EventSession, State: zk.StateHasSession})
+	if renewals != 1 {
+		t.Errorf("Expected 1 renewal, got %d", renewals)
+	}
+	if health := session.health(); !health.Connected || health.Expirations != 1 {
+		t.Errorf("Expected a connected session after 1 expiration, got %+v", health)
+	}
+	if err := lock.held(ctx); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("Expected the lock to stay lost in the new session, got %v", err)
+	}
+
+	// Writes without a lock do not depend on the session, only on ctx
+	var unlocked *zkLock
+	if err := unlocked.held(ctx); err != nil {
+		t.Errorf("Expected no error without a lock, got %v", err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := unlocked.held(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSessionError(t *testing.T) {
+	cases := map[error]error{
+		zk.ErrSessionExpired:   ErrSessionExpired,
+		zk.ErrConnectionClosed: ErrConnectionLost,
+		zk.ErrNoServer:         ErrConnectionLost,
+		zk.ErrBadVersion:       zk.ErrBadVersion,
+		nil:                    nil,
+	}
+	for err, want := range cases {
+		if got := sessionError(err); got != want {
+			t.Errorf("sessionError(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
@@ -1,10 +1,18 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/url"
 	"path"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
@@ -12,58 +20,262 @@ import (
 	"github.com/google/uuid"
 )
 
+// maxBidAttempts bounds how often a bid is retried when other bids keep
+// committing first
+const maxBidAttempts = 20
+
+// zkSessionTimeout is the timeout of the ZooKeeper session, after which a
+// server that lost its connection loses its ephemeral znodes
+const zkSessionTimeout = 10 * time.Second
+
 // ZKStore provides a ZooKeeper-backed implementation of auction storage
 type ZKStore struct {
-	conn     *zk.Conn
+	conn     zkConn
 	basePath string
+	auctions *auctionCache
+	session  *zkSession
+	// closed is closed by Close, to stop restoring registrations
+	closed chan struct{}
+
+	// ephemeralsMu guards ephemerals, which maps the path of each ephemeral
+	// registration to its data, to be created again in a new session, and
+	// sessions, which holds the IDs of every session the store has had
+	ephemeralsMu sync.Mutex
+	ephemerals   map[string][]byte
+	sessions     map[int64]bool
+	// staleWait is how long an ephemeral znode of another session is given
+	// to disappear, in case its session has ended but not yet expired
+	staleWait time.Duration
+
+	// lockedBids makes bids take the auction lock instead of retrying
+	// when another bid commits first
+	lockedBids bool
+}
+
+// ZKOption configures a ZKStore
+type ZKOption func(*ZKStore)
+
+// WithLockedBids makes bids take the auction lock, like every other change
+// to an auction. By default bids take no lock: they are written in a
+// transaction that checks the version of the auction and its highest bid,
+// and retried if another bid committed first.
+func WithLockedBids() ZKOption {
+	return func(z *ZKStore) {
+		z.lockedBids = true
+	}
 }
 
 // NewZKStore creates a new ZooKeeper-backed store
-func NewZKStore(zkHosts []string, basePath string) (*ZKStore, error) {
-	conn, _, err := zk.Connect(zkHosts, time.Second*10)
+func NewZKStore(zkHosts []string, basePath string, opts ...ZKOption) (*ZKStore, error) {
+	store := newZKStore(basePath, opts...)
+	conn, _, err := zk.Connect(zkHosts, zkSessionTimeout, zk.WithEventCallback(store.session.handle))
 	if err != nil {
 		return nil, err
 	}
+	if err := store.start(conn); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
 
+// newZKStore creates a store that is not connected yet
+func newZKStore(basePath string, opts ...ZKOption) *ZKStore {
 	store := &ZKStore{
-		conn:     conn,
-		basePath: basePath,
+		basePath:   basePath,
+		closed:     make(chan struct{}),
+		ephemerals: make(map[string][]byte),
+		sessions:   make(map[int64]bool),
+		staleWait:  zkSessionTimeout,
 	}
+	store.session = newZKSession(store.renewed)
+	for _, opt := range opts {
+		opt(store)
+	}
+	return store
+}
+
+// start uses the connection, creating the base paths of the store
+func (z *ZKStore) start(conn zkClient) error {
+	z.conn = zkConn{conn}
+	z.auctions = newAuctionCache(z.conn, z.basePath)
 
 	// Ensure base paths exist
 	paths := []string{
-		basePath,
-		path.Join(basePath, "auctions"),
-		path.Join(basePath, "bids"),
-		path.Join(basePath, "locks"),
+		z.basePath,
+		path.Join(z.basePath, "auctions"),
+		path.Join(z.basePath, "bids"),
+		path.Join(z.basePath, "highest"),
+		path.Join(z.basePath, "locks"),
+		path.Join(z.basePath, "settlements"),
+		path.Join(z.basePath, "proxies"),
+		path.Join(z.basePath, "events"),
+		path.Join(z.basePath, "participants"),
+		path.Join(z.basePath, "members"),
+		path.Join(z.basePath, "idempotency"),
 	}
 
 	for _, p := range paths {
-		exists, _, err := conn.Exists(p)
+		exists, _, err := z.conn.Exists(p)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		if !exists {
-			_, err := conn.Create(p, []byte{}, 0, zk.WorldACL(zk.PermAll))
+			_, err := z.conn.Create(p, []byte{}, 0, zk.WorldACL(zk.PermAll))
 			if err != nil && err != zk.ErrNodeExists {
-				return nil, err
+				return err
 			}
 		}
 	}
 
-	return store, nil
+	// Load the auctions and start watching them, so the search index is
+	// ready before the first query
+	go z.auctions.update()
+
+	return nil
 }
 
 // Close closes the ZooKeeper connection
 func (z *ZKStore) Close() {
-	if z.conn != nil {
+	close(z.closed)
+	if z.conn.zkClient != nil {
 		z.conn.Close()
 	}
 }
 
+// Health reports the state of the ZooKeeper session
+func (z *ZKStore) Health() Health {
+	health := z.session.health()
+	if health.Connected {
+		health.SessionID = fmt.Sprintf("0x%x", z.conn.SessionID())
+	}
+	return health
+}
+
+// renewed is called when a session starts after one expired. The watches
+// of the cache were dropped with the old session, and so were the
+// ephemeral registrations.
+func (z *ZKStore) renewed() {
+	go z.auctions.update()
+	go z.restoreEphemerals()
+}
+
+// registerEphemeral creates an ephemeral znode, which disappears if the
+// server loses its session, and records it to be created again when a new
+// session starts
+func (z *ZKStore) registerEphemeral(p string, data []byte) error {
+	z.ephemeralsMu.Lock()
+	defer z.ephemeralsMu.Unlock()
+
+	if err := z.createEphemeral(p, data); err != nil {
+		return err
+	}
+	z.ephemerals[p] = data
+	return nil
+}
+
+// unregisterEphemeral deletes an ephemeral registration
+func (z *ZKStore) unregisterEphemeral(p string) error {
+	z.ephemeralsMu.Lock()
+	defer z.ephemeralsMu.Unlock()
+
+	delete(z.ephemerals, p)
+	if err := z.conn.Delete(p, -1); err != nil && err != zk.ErrNoNode {
+		return err
+	}
+	return nil
+}
+
+// createEphemeral creates an ephemeral znode owned by the current session.
+// A znode left by an earlier session of this store may not have been
+// removed yet, so it is replaced. A znode of another session is given
+// staleWait to disappear, as it would if its server stopped without
+// deleting it; if it is still there, it belongs to a live server and
+// creating it fails with zk.ErrNodeExists. The caller must hold
+// ephemeralsMu.
+func (z *ZKStore) createEphemeral(p string, data []byte) error {
+	z.sessions[z.conn.SessionID()] = true
+	deadline := time.After(z.staleWait)
+	for {
+		_, err := z.conn.Create(p, data, zk.FlagEphemeral, zk.WorldACL(zk.PermAll))
+		if err != zk.ErrNodeExists {
+			return err
+		}
+
+		exists, stat, watch, err := z.conn.ExistsW(p)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if z.sessions[stat.EphemeralOwner] {
+			if err := z.conn.Delete(p, stat.Version); err != nil && err != zk.ErrNoNode && err != zk.ErrBadVersion {
+				return err
+			}
+			continue
+		}
+		select {
+		case <-watch:
+		case <-deadline:
+			return zk.ErrNodeExists
+		case <-z.closed:
+			return zk.ErrNodeExists
+		}
+	}
+}
+
+// restoreEphemerals creates the ephemeral registrations again in a new
+// session, retrying until it succeeds, the session expires again or the
+// store is closed
+func (z *ZKStore) restoreEphemerals() {
+	epoch := z.session.current()
+	for {
+		err := z.createEphemerals()
+		if err == nil {
+			return
+		}
+		log.Printf("Failed to restore ephemeral znodes: %v", err)
+
+		select {
+		case <-time.After(eventRetryInterval):
+		case <-z.closed:
+			return
+		}
+		if z.session.current() != epoch {
+			return
+		}
+	}
+}
+
+// createEphemerals creates every ephemeral registration
+func (z *ZKStore) createEphemerals() error {
+	z.ephemeralsMu.Lock()
+	defer z.ephemeralsMu.Unlock()
+
+	for p, data := range z.ephemerals {
+		if err := z.createEphemeral(p, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // CreateAuction adds a new auction item to the store
-func (z *ZKStore) CreateAuction(item auction.AuctionItem) (auction.AuctionItem, error) {
+func (z *ZKStore) CreateAuction(ctx context.Context, item auction.AuctionItem) (auction.AuctionItem, error) {
+	return z.CreateAuctionIdempotent(ctx, IdempotencyKey{}, item)
+}
+
+// CreateAuctionIdempotent creates an auction once per key. The record of
+// the key is written in the same transaction as the auction, so a retry
+// through any server returns the auction the first call created.
+func (z *ZKStore) CreateAuctionIdempotent(ctx context.Context, key IdempotencyKey, item auction.AuctionItem) (auction.AuctionItem, error) {
+	if result, ok, err := z.recordedResult(key); err != nil {
+		return auction.AuctionItem{}, err
+	} else if ok {
+		return result.createdAuction(key)
+	}
+
 	if item.ID == "" {
 		item.ID = uuid.New().String()
 	}
@@ -75,127 +287,194 @@ func (z *ZKStore) CreateAuction(item auction.AuctionItem) (auction.AuctionItem,
 		return auction.AuctionItem{}, err
 	}
 
+	// Create the auction, its bids path and its empty highest bid together,
+	// then the events path
 	auctionPath := path.Join(z.basePath, "auctions", item.ID)
-	_, err = z.conn.Create(auctionPath, data, 0, zk.WorldACL(zk.PermAll))
+	bidsPath := path.Join(z.basePath, "bids", item.ID)
+	recorded, replayed, err := z.multiIdempotent(key, idempotentResult{Auction: &item},
+		&zk.CreateRequest{Path: auctionPath, Data: data, Acl: zk.WorldACL(zk.PermAll)},
+		&zk.CreateRequest{Path: bidsPath, Data: []byte{}, Acl: zk.WorldACL(zk.PermAll)},
+		&zk.CreateRequest{Path: z.highestPath(item.ID), Data: []byte{}, Acl: zk.WorldACL(zk.PermAll)},
+	)
+	if replayed {
+		return recorded.createdAuction(key)
+	}
 	if err != nil {
 		return auction.AuctionItem{}, err
 	}
-
-	// Create the bids path for this auction
-	bidsPath := path.Join(z.basePath, "bids", item.ID)
-	_, err = z.conn.Create(bidsPath, []byte{}, 0, zk.WorldACL(zk.PermAll))
-	if err != nil {
-		// Try to clean up the auction node
-		z.conn.Delete(auctionPath, 0)
+	if err := z.ensureEventsPath(item.ID); err != nil {
 		return auction.AuctionItem{}, err
 	}
+	z.auctions.invalidate(item.ID)
 
 	return item, nil
 }
 
-// ListAuctions returns all auction items in the store
-func (z *ZKStore) ListAuctions() ([]auction.AuctionItem, error) {
-	auctionsPath := path.Join(z.basePath, "auctions")
-	children, _, err := z.conn.Children(auctionsPath)
-	if err != nil {
-		return nil, err
+// ListAuctions returns a page of the auctions matching the query. Auctions
+// are served from a cache kept fresh by watches, so a change made through
+// another server shows up once its watch fires.
+func (z *ZKStore) ListAuctions(ctx context.Context, query auction.ListQuery) (auction.ListPage, error) {
+	pager := query.Pager(time.Now())
+	if err := z.auctions.page(pager); err != nil {
+		return auction.ListPage{}, err
 	}
+	return pager.Page(), nil
+}
 
-	auctions := make([]auction.AuctionItem, 0, len(children))
-	for _, child := range children {
-		itemPath := path.Join(auctionsPath, child)
-		data, _, err := z.conn.Get(itemPath)
-		if err != nil {
-			continue // Skip items we can't retrieve
-		}
-
-		var item auction.AuctionItem
-		if err := json.Unmarshal(data, &item); err != nil {
-			continue // Skip items we can't unmarshal
-		}
-
-		auctions = append(auctions, item)
+// SearchAuctions returns the auctions matching the text and the filters of
+// the query, best match first. Each server indexes the auctions in its
+// cache, which follows the auctions znode through its watches.
+func (z *ZKStore) SearchAuctions(ctx context.Context, text string, query auction.ListQuery) ([]auction.AuctionItem, error) {
+	auctions, err := z.auctions.search(text)
+	if err != nil {
+		return nil, err
 	}
-
-	return auctions, nil
+	return query.Filter(auctions, time.Now()), nil
 }
 
 // GetAuction retrieves an auction by ID
-func (z *ZKStore) GetAuction(id string) (auction.AuctionItem, error) {
+func (z *ZKStore) GetAuction(ctx context.Context, id string) (auction.AuctionItem, error) {
+	item, _, err := z.getAuctionWithStat(id)
+	return item, err
+}
+
+// getAuctionWithStat retrieves an auction along with its znode stat, so it
+// can be updated with a version check
+func (z *ZKStore) getAuctionWithStat(id string) (auction.AuctionItem, *zk.Stat, error) {
 	auctionPath := path.Join(z.basePath, "auctions", id)
 	_, err := z.conn.Sync(auctionPath)
+	if err == zk.ErrNoNode {
+		return auction.AuctionItem{}, nil, ErrAuctionNotFound
+	}
 	if err != nil {
-		return auction.AuctionItem{}, err
+		return auction.AuctionItem{}, nil, err
+	}
+	data, stat, err := z.conn.Get(auctionPath)
+	if err == zk.ErrNoNode {
+		return auction.AuctionItem{}, nil, ErrAuctionNotFound
 	}
-	data, _, err := z.conn.Get(auctionPath)
 	if err != nil {
-		return auction.AuctionItem{}, errors.New("auction not found")
+		return auction.AuctionItem{}, nil, err
 	}
 
 	var item auction.AuctionItem
 	if err := json.Unmarshal(data, &item); err != nil {
-		return auction.AuctionItem{}, err
+		return auction.AuctionItem{}, nil, err
 	}
 
-	return item, nil
+	return item, stat, nil
+}
+
+// PlaceBid adds a new bid to an auction item and returns it as stored
+func (z *ZKStore) PlaceBid(ctx context.Context, bid auction.Bid) (auction.Bid, error) {
+	return z.PlaceBidIdempotent(ctx, IdempotencyKey{}, bid)
 }
 
-// PlaceBid adds a new bid to an auction item with distributed locking
-func (z *ZKStore) PlaceBid(bid auction.Bid) error {
+// PlaceBidIdempotent places a bid once per key and returns it as stored.
+// The record of the key is written in the same transaction as the bid, so
+// a retry through any server returns the recorded bid instead of bidding
+// again.
+func (z *ZKStore) PlaceBidIdempotent(ctx context.Context, key IdempotencyKey, bid auction.Bid) (auction.Bid, error) {
+	if result, ok, err := z.recordedResult(key); err != nil {
+		return auction.Bid{}, err
+	} else if ok {
+		return result.placedBid(key)
+	}
+
 	// Get the auction to check if it exists and hasn't expired, syncs to get the latest data
-	auctionItem, err := z.GetAuction(bid.AuctionItemID)
+	auctionItem, err := z.GetAuction(ctx, bid.AuctionItemID)
 	if err != nil {
-		return err
+		return auction.Bid{}, err
 	}
 
-	// Check if auction has expired
+	// Check if auction has been closed or has expired
+	if auctionItem.ClosedAt != nil {
+		return auction.Bid{}, ErrAuctionClosed
+	}
 	if time.Now().After(auctionItem.ExpiryTime) {
-		return errors.New("auction has expired")
+		return auction.Bid{}, ErrAuctionExpired
+	}
+	if err := checkBidder(auctionItem, bid.ParticipantID); err != nil {
+		return auction.Bid{}, err
+	}
+
+	if auctionItem.Type() == auction.TypeDutch {
+		return auction.Bid{}, ErrDutchBid
 	}
 
 	// Check if the bid is higher than the minimum bid
-	if bid.BidPrice < auctionItem.MinimumBid {
-		return errors.New("bid price is lower than minimum bid")
+	if err := auctionItem.CheckBidCurrency(bid); err != nil {
+		return auction.Bid{}, ErrCurrencyMismatch.withMessage(err.Error())
+	}
+	if bid.BidPrice.Less(auctionItem.MinimumBid) {
+		return auction.Bid{}, &BidTooLowError{NextMinimumBid: auctionItem.MinimumBid}
 	}
 
-	// Create lock path
-	lockPath := path.Join(z.basePath, "locks", bid.AuctionItemID)
+	if auctionItem.IsSealed() && !bid.MaxBid.IsZero() {
+		return auction.Bid{}, ErrProxyBidSealed
+	}
 
-	// Ensure parent lock path exists
-	lockParentPath := path.Join(z.basePath, "locks")
-	exists, _, err := z.conn.Exists(lockParentPath)
-	if err != nil {
-		return err
+	// A proxy maximum must cover the bid itself
+	if !bid.MaxBid.IsZero() && bid.MaxBid.Less(bid.BidPrice) {
+		return auction.Bid{}, ErrMaxBidTooLow
 	}
 
-	if !exists {
-		_, err = z.conn.Create(lockParentPath, []byte{}, 0, zk.WorldACL(zk.PermAll))
-		if err != nil && err != zk.ErrNodeExists {
-			return err
+	if z.lockedBids {
+		lock, err := z.lockAuction(ctx, bid.AuctionItemID)
+		if err != nil {
+			return auction.Bid{}, err
 		}
+
+		// Make sure we release the lock when done
+		defer lock.Unlock()
+
+		return z.placeBid(ctx, key, bid, lock)
+	}
+
+	// Without the lock, a bid committed by someone else since we read the
+	// auction fails the transaction, so read everything again and retry
+	return retryConflicts(ctx, func() (auction.Bid, error) {
+		return z.placeBid(ctx, key, bid, nil)
+	})
+}
+
+// placeBid reads the auction and its highest bid, and places the bid in a
+// transaction that fails if either changed since. Only the version checks
+// serialize concurrent bids, unless the caller holds the auction lock,
+// which is nil otherwise.
+func (z *ZKStore) placeBid(ctx context.Context, key IdempotencyKey, bid auction.Bid, lock *zkLock) (auction.Bid, error) {
+	// A retry may have been placed through another server in the meantime
+	if result, ok, err := z.recordedResult(key); err != nil {
+		return auction.Bid{}, err
+	} else if ok {
+		return result.placedBid(key)
 	}
 
-	// Create a distributed lock using the proper API
-	lock := zk.NewLock(z.conn, lockPath, zk.WorldACL(zk.PermAll))
+	// Re-read the auction, it may have been closed or extended since
+	auctionItem, auctionStat, err := z.getAuctionWithStat(bid.AuctionItemID)
+	if err != nil {
+		return auction.Bid{}, err
+	}
 
-	// Acquire the lock (this will block until lock is acquired)
-	if err := lock.Lock(); err != nil {
-		return err
+	if auctionItem.ClosedAt != nil {
+		return auction.Bid{}, ErrAuctionClosed
+	}
+	if time.Now().After(auctionItem.ExpiryTime) {
+		return auction.Bid{}, ErrAuctionExpired
 	}
 
-	// Make sure we release the lock when done
-	defer lock.Unlock()
+	// Sealed bids are hidden, so they are never compared with other bids
+	if auctionItem.IsSealed() {
+		return z.placeSealedBid(ctx, key, bid, auctionStat, lock)
+	}
 
-	// Check if there are existing bids and if the current bid is higher
-	highestBid, err := z.GetHighestBid(bid.AuctionItemID)
-	if err == nil {
-		// There is a highest bid, check if the new bid is higher
-		if bid.BidPrice <= highestBid.BidPrice {
-			return errors.New("bid price is not higher than current highest bid")
-		}
-	} else if err.Error() != "no bids found for this auction" {
-		// An error occurred that's not just "no bids"
-		return err
+	// Check if there are existing bids and if the current bid beats the highest by the increment
+	current, highestStat, err := z.getHighestWithStat(ctx, bid.AuctionItemID)
+	if err != nil {
+		return auction.Bid{}, err
+	}
+	if next := auctionItem.NextMinimumBid(current); bid.BidPrice.Less(next) {
+		return auction.Bid{}, &BidTooLowError{NextMinimumBid: next}
 	}
 
 	// Generate a UUID if not provided
@@ -203,88 +482,1158 @@ func (z *ZKStore) PlaceBid(bid auction.Bid) error {
 		bid.ID = uuid.New().String()
 	}
 
-	// Set timestamp if not provided
-	if bid.Timestamp.IsZero() {
-		bid.Timestamp = time.Now()
+	// Bids are ordered and checked against the expiry by the server's clock,
+	// never the client's
+	now := time.Now()
+	bid.Timestamp = now
+
+	// Record the participant's hidden maximum, then let proxies respond to the bid
+	proxies, proxiesStat, err := z.getProxies(bid.AuctionItemID)
+	if err != nil {
+		return auction.Bid{}, err
 	}
 
-	// Serialize bid data
-	bidData, err := json.Marshal(bid)
+	if !bid.MaxBid.IsZero() {
+		proxies[bid.ParticipantID] = auction.ProxyBid{
+			ParticipantID: bid.ParticipantID,
+			MaxBid:        bid.MaxBid,
+			CreatedAt:     now,
+		}
+	}
+	proxiesChanged := !bid.MaxBid.IsZero()
+	bid.MaxBid = auction.Money{}
+	bid.Automatic = false
+	automatic := resolveProxyBids(bid, proxies, auctionItem.ProxyIncrement, auctionItem.ReservePrice, now, func() string {
+		return uuid.New().String()
+	})
+
+	// Number the bids as ZooKeeper will name their znodes
+	sequence, err := z.nextBidSequence(bid.AuctionItemID)
 	if err != nil {
-		return err
+		return auction.Bid{}, err
+	}
+	bid.Sequence = sequence
+	for i := range automatic {
+		automatic[i].Sequence = sequence + uint64(i) + 1
+	}
+
+	if err := z.ensureEventsPath(bid.AuctionItemID); err != nil {
+		return auction.Bid{}, err
 	}
 
-	// Create a sequential node for this bid
+	// Create a sequential node for each bid, in the order they were placed,
+	// along with the event announcing it
 	bidPath := path.Join(z.basePath, "bids", bid.AuctionItemID, "bid-")
-	_, err = z.conn.Create(bidPath, bidData, zk.FlagSequence, zk.WorldACL(zk.PermAll))
+	ops := make([]interface{}, 0, 2*len(automatic)+5)
+	for _, placed := range append([]auction.Bid{bid}, automatic...) {
+		bidData, err := json.Marshal(placed)
+		if err != nil {
+			return auction.Bid{}, err
+		}
+		event, err := z.eventRequest(bidPlacedEvent(placed))
+		if err != nil {
+			return auction.Bid{}, err
+		}
+		ops = append(ops, &zk.CreateRequest{
+			Path:  bidPath,
+			Data:  bidData,
+			Acl:   zk.WorldACL(zk.PermAll),
+			Flags: zk.FlagSequence,
+		}, event)
+	}
 
-	return err
-}
+	if proxiesChanged {
+		proxiesData, err := json.Marshal(proxies)
+		if err != nil {
+			return auction.Bid{}, err
+		}
 
-// GetHighestBid returns the highest bid for an auction
-func (z *ZKStore) GetHighestBid(auctionID string) (auction.Bid, error) {
-	bids, err := z.GetBidHistory(auctionID)
+		proxiesPath := path.Join(z.basePath, "proxies", bid.AuctionItemID)
+		if proxiesStat == nil {
+			ops = append(ops, &zk.CreateRequest{Path: proxiesPath, Data: proxiesData, Acl: zk.WorldACL(zk.PermAll)})
+		} else {
+			ops = append(ops, &zk.SetDataRequest{Path: proxiesPath, Data: proxiesData, Version: proxiesStat.Version})
+		}
+	}
+
+	// Push the expiry out if the bid arrived in the final window
+	if auctionItem.ExtendForBid(now) {
+		event, err := z.eventRequest(extendedEvent(auctionItem, now))
+		if err != nil {
+			return auction.Bid{}, err
+		}
+		ops = append(ops, event)
+	}
+
+	// Bids only go up, so the last one placed is the current bid
+	highest := bid
+	if len(automatic) > 0 {
+		highest = automatic[len(automatic)-1]
+	}
+	highestRequest, err := z.highestRequest(highest, highestStat)
 	if err != nil {
 		return auction.Bid{}, err
 	}
+	ops = append(ops, highestRequest)
 
-	if len(bids) == 0 {
-		return auction.Bid{}, errors.New("no bids found for this auction")
+	auctionItem.CurrentBid = highest.BidPrice
+	itemData, err := json.Marshal(auctionItem)
+	if err != nil {
+		return auction.Bid{}, err
 	}
-
-	// Return the highest bid by price
-	sort.Slice(bids, func(i, j int) bool {
-		return bids[i].BidPrice < bids[j].BidPrice
+	ops = append(ops, &zk.SetDataRequest{
+		Path:    path.Join(z.basePath, "auctions", bid.AuctionItemID),
+		Data:    itemData,
+		Version: auctionStat.Version,
 	})
 
-	return bids[len(bids)-1], nil
+	// Write the bids, highest bid, proxies, auction, events and the record of
+	// the key in a single transaction
+	if err := lock.held(ctx); err != nil {
+		return auction.Bid{}, err
+	}
+	recorded, replayed, err := z.multiIdempotent(key, idempotentResult{Bid: &bid}, ops...)
+	if replayed {
+		return recorded.placedBid(key)
+	}
+	if err != nil {
+		return auction.Bid{}, err
+	}
+	z.auctions.invalidate(bid.AuctionItemID)
+	return bid, nil
 }
 
-// GetBidHistory returns all bids for an auction
-func (z *ZKStore) GetBidHistory(auctionID string) ([]auction.Bid, error) {
-	bidsPath := path.Join(z.basePath, "bids", auctionID)
+// placeSealedBid records a participant's sealed bid, replacing any earlier
+// bid of theirs, and returns the bid as stored. Each participant has a
+// single sequential znode, which a revision replaces with a new one so that
+// it gets the next sequence number. The transaction fails if the auction
+// changed since it was read with auctionStat, or if another bid was placed.
+func (z *ZKStore) placeSealedBid(ctx context.Context, key IdempotencyKey, bid auction.Bid, auctionStat *zk.Stat, lock *zkLock) (auction.Bid, error) {
+	if bid.ID == "" {
+		bid.ID = uuid.New().String()
+	}
 
-	// Check if the auction exists
-	exists, _, err := z.conn.Exists(bidsPath)
+	// Ties are broken by time, so always use the server's clock
+	bid.Timestamp = time.Now()
+	bid.Automatic = false
+
+	// Read the highest bid before numbering the bid and listing the others,
+	// so that a bid placed after any of these reads fails the transaction
+	current, highestStat, err := z.getHighestWithStat(ctx, bid.AuctionItemID)
 	if err != nil {
-		return nil, err
+		return auction.Bid{}, err
 	}
 
-	// Sync the path with ZooKeeper to ensure we have the latest view
-	if exists {
-		_, err = z.conn.Sync(bidsPath)
-		if err != nil {
-			return nil, err
-		}
+	sequence, err := z.nextBidSequence(bid.AuctionItemID)
+	if err != nil {
+		return auction.Bid{}, err
 	}
+	bid.Sequence = sequence
 
-	if !exists {
-		return nil, errors.New("auction not found")
+	bidData, err := json.Marshal(bid)
+	if err != nil {
+		return auction.Bid{}, err
 	}
 
-	children, _, err := z.conn.Children(bidsPath)
+	if err := z.ensureEventsPath(bid.AuctionItemID); err != nil {
+		return auction.Bid{}, err
+	}
+	event, err := z.eventRequest(bidPlacedEvent(bid))
 	if err != nil {
-		return nil, err
+		return auction.Bid{}, err
 	}
 
-	// Sort the children by sequence number to get chronological order
-	sort.Strings(children)
-
-	bids := make([]auction.Bid, 0, len(children))
+	// Create the new bid before deleting the earlier one, so that it is
+	// named after the child version read above
+	bidsPath := path.Join(z.basePath, "bids", bid.AuctionItemID)
+	name := sealedBidName(bid.ParticipantID)
+	ops := []interface{}{
+		&zk.CreateRequest{Path: path.Join(bidsPath, name+"-"), Data: bidData, Acl: zk.WorldACL(zk.PermAll), Flags: zk.FlagSequence},
+		event,
+		&zk.CheckVersionRequest{Path: path.Join(z.basePath, "auctions", bid.AuctionItemID), Version: auctionStat.Version},
+	}
+	children, _, err := z.conn.Children(bidsPath)
+	if err != nil {
+		return auction.Bid{}, err
+	}
 	for _, child := range children {
-		bidPath := path.Join(bidsPath, child)
-		data, _, err := z.conn.Get(bidPath)
+		if child == name || (strings.HasPrefix(child, name+"-") && isSequenceSuffix(child[len(name)+1:])) {
+			ops = append(ops, &zk.DeleteRequest{Path: path.Join(bidsPath, child), Version: -1})
+		}
+	}
+
+	// Ties go to the earliest bid, which the new one never is. A revision
+	// by the highest bidder may lower their bid, so the highest is then
+	// found again among all the bids.
+	highest := bid
+	switch {
+	case current != nil && current.ParticipantID == bid.ParticipantID:
+		bids, err := z.GetBidHistory(ctx, bid.AuctionItemID)
 		if err != nil {
-			continue // Skip bids we can't retrieve
+			return auction.Bid{}, err
+		}
+		others := make([]auction.Bid, 0, len(bids))
+		for _, other := range bids {
+			if other.ParticipantID != bid.ParticipantID {
+				others = append(others, other)
+			}
 		}
+		highest, _ = highestBid(append(others, bid))
+	case current != nil && !current.BidPrice.Less(bid.BidPrice):
+		highest = *current
+	}
+	highestRequest, err := z.highestRequest(highest, highestStat)
+	if err != nil {
+		return auction.Bid{}, err
+	}
+	ops = append(ops, highestRequest)
 
-		var bid auction.Bid
-		if err := json.Unmarshal(data, &bid); err != nil {
-			continue // Skip bids we can't unmarshal
+	if err := lock.held(ctx); err != nil {
+		return auction.Bid{}, err
+	}
+	recorded, replayed, err := z.multiIdempotent(key, idempotentResult{Bid: &bid}, ops...)
+	if replayed {
+		return recorded.placedBid(key)
+	}
+	if err != nil {
+		return auction.Bid{}, err
+	}
+	return bid, nil
+}
+
+// nextBidSequence returns the sequence number of the next bid znode created
+// under an auction. ZooKeeper names a sequential znode after the child
+// version of its parent, which changes whenever a child is created or
+// deleted, and bids are numbered from 1. Every bid updates the highest bid
+// znode, so a transaction that checks the version of that znode, read
+// before calling this, is sure to get the number.
+func (z *ZKStore) nextBidSequence(auctionID string) (uint64, error) {
+	exists, stat, err := z.conn.Exists(path.Join(z.basePath, "bids", auctionID))
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrAuctionNotFound
+	}
+	return uint64(stat.Cversion) + 1, nil
+}
+
+// sealedBidName returns the prefix of the names of a participant's sealed
+// bid znodes, which end in a sequence suffix
+func sealedBidName(participantID string) string {
+	return "sealed-" + url.PathEscape(participantID)
+}
+
+// bidSequence returns the sequence number of a bid from the suffix of its
+// znode, which is missing on sealed bids stored before they were numbered
+func bidSequence(child string) (uint64, bool) {
+	i := strings.LastIndex(child, "-")
+	if i < 0 || child[:i] == "sealed" || !isSequenceSuffix(child[i+1:]) {
+		return 0, false
+	}
+	suffix, err := strconv.ParseUint(child[i+1:], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return suffix + 1, true
+}
+
+// isSequenceSuffix reports whether s is the zero padded suffix ZooKeeper
+// appends to sequential znodes
+func isSequenceSuffix(s string) bool {
+	if len(s) != 10 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
 		}
+	}
+	return true
+}
 
-		bids = append(bids, bid)
+// getProxies returns the proxy bids registered on an auction, along with the
+// stat of their znode, which is nil if no proxy has been registered yet
+func (z *ZKStore) getProxies(auctionID string) (map[string]auction.ProxyBid, *zk.Stat, error) {
+	proxies := make(map[string]auction.ProxyBid)
+
+	data, stat, err := z.conn.Get(path.Join(z.basePath, "proxies", auctionID))
+	if err == zk.ErrNoNode {
+		return proxies, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return bids, nil
+	if err := json.Unmarshal(data, &proxies); err != nil {
+		return nil, nil, err
+	}
+	return proxies, stat, nil
+}
+
+// GetHighestBid returns the highest bid for an auction, kept in its own
+// znode so that it takes a single read
+func (z *ZKStore) GetHighestBid(ctx context.Context, auctionID string) (auction.Bid, error) {
+	// Sync with ZooKeeper to ensure we have the latest view
+	if _, err := z.conn.Sync(path.Join(z.basePath, "highest")); err != nil {
+		return auction.Bid{}, err
+	}
+
+	highest, _, err := z.getHighestWithStat(ctx, auctionID)
+	if err != nil {
+		return auction.Bid{}, err
+	}
+	if highest == nil {
+		return auction.Bid{}, ErrNoBids
+	}
+	return *highest, nil
+}
+
+// highestPath returns the znode holding the highest bid on an auction
+func (z *ZKStore) highestPath(auctionID string) string {
+	return path.Join(z.basePath, "highest", auctionID)
+}
+
+// getHighestWithStat returns the highest bid on an auction, which is nil
+// before the first bid, along with the stat of its znode. Auctions created
+// before the znode was kept have none, so their highest bid is found in
+// the history and the stat is nil.
+func (z *ZKStore) getHighestWithStat(ctx context.Context, auctionID string) (*auction.Bid, *zk.Stat, error) {
+	data, stat, err := z.conn.Get(z.highestPath(auctionID))
+	if err == zk.ErrNoNode {
+		bids, err := z.GetBidHistory(ctx, auctionID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if highest, ok := highestBid(bids); ok {
+			return &highest, nil, nil
+		}
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) == 0 {
+		return nil, stat, nil
+	}
+
+	var highest auction.Bid
+	if err := json.Unmarshal(data, &highest); err != nil {
+		return nil, nil, err
+	}
+	return &highest, stat, nil
+}
+
+// highestRequest returns the request that stores bid as the highest bid on
+// its auction. It fails the transaction if the highest bid changed since it
+// was read with stat, or creates the znode if stat is nil.
+func (z *ZKStore) highestRequest(bid auction.Bid, stat *zk.Stat) (interface{}, error) {
+	data, err := json.Marshal(bid)
+	if err != nil {
+		return nil, err
+	}
+	highestPath := z.highestPath(bid.AuctionItemID)
+	if stat == nil {
+		return &zk.CreateRequest{Path: highestPath, Data: data, Acl: zk.WorldACL(zk.PermAll)}, nil
+	}
+	return &zk.SetDataRequest{Path: highestPath, Data: data, Version: stat.Version}, nil
+}
+
+// highestChecks returns the operations that make a transaction fail if a
+// bid is placed after this call. Bids do not take the auction lock, so a
+// write that depends on the bids must include them. Auctions from before
+// the highest bid znode have nothing to check.
+func (z *ZKStore) highestChecks(ctx context.Context, auctionID string) ([]interface{}, error) {
+	_, stat, err := z.getHighestWithStat(ctx, auctionID)
+	if err != nil || stat == nil {
+		return nil, err
+	}
+	return []interface{}{&zk.CheckVersionRequest{Path: z.highestPath(auctionID), Version: stat.Version}}, nil
+}
+
+// GetBidHistory returns all bids for an auction
+func (z *ZKStore) GetBidHistory(ctx context.Context, auctionID string) ([]auction.Bid, error) {
+	bidsPath := path.Join(z.basePath, "bids", auctionID)
+
+	// Check if the auction exists
+	exists, _, err := z.conn.Exists(bidsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sync the path with ZooKeeper to ensure we have the latest view
+	if exists {
+		_, err = z.conn.Sync(bidsPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !exists {
+		return nil, ErrAuctionNotFound
+	}
+
+	children, _, err := z.conn.Children(bidsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort the children by sequence number to get chronological order
+	sort.Strings(children)
+
+	bids := make([]auction.Bid, 0, len(children))
+	sealed := false
+	for _, child := range children {
+		sealed = sealed || strings.HasPrefix(child, "sealed-")
+		bidPath := path.Join(bidsPath, child)
+		data, _, err := z.conn.Get(bidPath)
+		if err != nil {
+			continue // Skip bids we can't retrieve
+		}
+
+		var bid auction.Bid
+		if err := json.Unmarshal(data, &bid); err != nil {
+			continue // Skip bids we can't unmarshal
+		}
+		if sequence, ok := bidSequence(child); ok {
+			bid.Sequence = sequence
+		}
+
+		bids = append(bids, bid)
+	}
+
+	// Sealed bid znodes are named by participant, so order them by time instead
+	if sealed {
+		sort.SliceStable(bids, func(i, j int) bool {
+			return bids[i].Timestamp.Before(bids[j].Timestamp)
+		})
+	}
+
+	return bids, nil
+}
+
+// CloseAuction settles an expired auction. The settlement znode is created
+// under the auction lock, so when several servers race to close the same
+// auction only the first one writes it and the others return that result.
+// Bids take no lock, so a bid committed while the auction is settled fails
+// the transaction, and the settlement is worked out again.
+func (z *ZKStore) CloseAuction(ctx context.Context, id string) (auction.Settlement, error) {
+	if _, err := z.GetAuction(ctx, id); err != nil {
+		return auction.Settlement{}, err
+	}
+
+	lock, err := z.lockAuction(ctx, id)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+	defer lock.Unlock()
+
+	if err := z.ensureEventsPath(id); err != nil {
+		return auction.Settlement{}, err
+	}
+	return retryConflicts(ctx, func() (auction.Settlement, error) {
+		return z.closeAuction(ctx, lock, id)
+	})
+}
+
+// closeAuction reads the auction and its bids and settles it, in a
+// transaction that fails with a conflict if a bid was placed since. The
+// caller must hold the auction lock.
+func (z *ZKStore) closeAuction(ctx context.Context, lock *zkLock, id string) (auction.Settlement, error) {
+	// Another server may already have closed the auction
+	if settlement, err := z.GetSettlement(ctx, id); err == nil {
+		return settlement, nil
+	} else if !errors.Is(err, ErrAuctionNotClosed) {
+		return auction.Settlement{}, err
+	}
+
+	item, stat, err := z.getAuctionWithStat(id)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	now := time.Now()
+	if now.Before(item.ExpiryTime) {
+		return auction.Settlement{}, ErrAuctionNotExpired
+	}
+
+	checks, err := z.highestChecks(ctx, id)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+	bids, err := z.GetBidHistory(ctx, id)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	settlement := settle(item, bids, now)
+	settlementData, err := json.Marshal(settlement)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	item.ClosedAt = &now
+	itemData, err := json.Marshal(item)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	event, err := z.eventRequest(closedEvent(settlement))
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	// Write the settlement and mark the auction closed atomically, unless a
+	// bid was placed after the history was read
+	ops := append([]interface{}{
+		&zk.CreateRequest{
+			Path:  path.Join(z.basePath, "settlements", id),
+			Data:  settlementData,
+			Acl:   zk.WorldACL(zk.PermAll),
+			Flags: 0,
+		},
+		&zk.SetDataRequest{
+			Path:    path.Join(z.basePath, "auctions", id),
+			Data:    itemData,
+			Version: stat.Version,
+		},
+		event,
+	}, checks...)
+	if err := lock.held(ctx); err != nil {
+		return auction.Settlement{}, err
+	}
+	resps, err := z.conn.Multi(ops...)
+	if settlementExists(resps, 0) {
+		return z.GetSettlement(ctx, id)
+	}
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+	z.auctions.invalidate(id)
+
+	return settlement, nil
+}
+
+// AcceptPrice accepts the current price of a Dutch auction, which closes
+// the auction immediately with the participant as the winner. The auction
+// lock and the settlement znode ensure that when acceptances race through
+// different servers exactly one of them wins.
+func (z *ZKStore) AcceptPrice(ctx context.Context, auctionID, participantID string) (auction.Settlement, error) {
+	item, err := z.GetAuction(ctx, auctionID)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+	if item.Type() != auction.TypeDutch {
+		return auction.Settlement{}, ErrNotDutch
+	}
+	if err := checkBidder(item, participantID); err != nil {
+		return auction.Settlement{}, err
+	}
+
+	lock, err := z.lockAuction(ctx, auctionID)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+	defer lock.Unlock()
+
+	if err := z.ensureEventsPath(auctionID); err != nil {
+		return auction.Settlement{}, err
+	}
+	return retryConflicts(ctx, func() (auction.Settlement, error) {
+		item, stat, err := z.getAuctionWithStat(auctionID)
+		if err != nil {
+			return auction.Settlement{}, err
+		}
+		if item.ClosedAt != nil {
+			return auction.Settlement{}, ErrAuctionClosed
+		}
+
+		now := time.Now()
+		if now.After(item.ExpiryTime) {
+			return auction.Settlement{}, ErrAuctionExpired
+		}
+
+		return z.sellAt(ctx, lock, item, stat, participantID, item.CurrentPrice(now), now)
+	})
+}
+
+// BuyNow buys an English auction at its buy-now price, which closes the
+// auction immediately with the participant as the winner. Every bid
+// changes the auction znode, whose version the sale checks, so a buy and a
+// racing bid cannot both succeed: the buy reads the auction again and
+// fails with ErrBuyNowReached if the bid reached the buy-now price.
+func (z *ZKStore) BuyNow(ctx context.Context, auctionID, participantID string) (auction.Settlement, error) {
+	item, err := z.GetAuction(ctx, auctionID)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+	if item.BuyNowPrice.IsZero() {
+		return auction.Settlement{}, ErrNoBuyNow
+	}
+	if err := checkBidder(item, participantID); err != nil {
+		return auction.Settlement{}, err
+	}
+
+	lock, err := z.lockAuction(ctx, auctionID)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+	defer lock.Unlock()
+
+	if err := z.ensureEventsPath(auctionID); err != nil {
+		return auction.Settlement{}, err
+	}
+	return retryConflicts(ctx, func() (auction.Settlement, error) {
+		item, stat, err := z.getAuctionWithStat(auctionID)
+		if err != nil {
+			return auction.Settlement{}, err
+		}
+		if item.ClosedAt != nil {
+			return auction.Settlement{}, ErrAuctionClosed
+		}
+
+		now := time.Now()
+		if now.After(item.ExpiryTime) {
+			return auction.Settlement{}, ErrAuctionExpired
+		}
+
+		bids, err := z.GetBidHistory(ctx, auctionID)
+		if err != nil {
+			return auction.Settlement{}, err
+		}
+		if highest, ok := highestBid(bids); ok && !highest.BidPrice.Less(item.BuyNowPrice) {
+			return auction.Settlement{}, ErrBuyNowReached
+		}
+
+		return z.sellAt(ctx, lock, item, stat, participantID, item.BuyNowPrice, now)
+	})
+}
+
+// sellAt closes the auction with a winning bid by the participant at price.
+// The caller must hold the auction lock, which is checked before the sale
+// is committed, and stat must be the version of the auction it checked. A
+// bid committed since fails the sale with a conflict, for the caller to
+// check the auction again.
+func (z *ZKStore) sellAt(ctx context.Context, lock *zkLock, item auction.AuctionItem, stat *zk.Stat, participantID string, price auction.Money, now time.Time) (auction.Settlement, error) {
+	bid := auction.Bid{
+		ID:            uuid.New().String(),
+		ParticipantID: participantID,
+		AuctionItemID: item.ID,
+		BidPrice:      price,
+		Timestamp:     now,
+	}
+	// The sale ends bidding, and no bid has reached its price
+	_, highestStat, err := z.getHighestWithStat(ctx, item.ID)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	sequence, err := z.nextBidSequence(item.ID)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+	bid.Sequence = sequence
+	bidData, err := json.Marshal(bid)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	highestRequest, err := z.highestRequest(bid, highestStat)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	settlement := settle(item, []auction.Bid{bid}, now)
+	settlementData, err := json.Marshal(settlement)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	item.CurrentBid = price
+	item.ClosedAt = &now
+	itemData, err := json.Marshal(item)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	bidEvent, err := z.eventRequest(bidPlacedEvent(bid))
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+	closeEvent, err := z.eventRequest(closedEvent(settlement))
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	// Record the winning bid, the settlement, the closed auction and the
+	// highest bid together
+	if err := lock.held(ctx); err != nil {
+		return auction.Settlement{}, err
+	}
+	resps, err := z.conn.Multi(
+		&zk.CreateRequest{
+			Path:  path.Join(z.basePath, "bids", item.ID, "bid-"),
+			Data:  bidData,
+			Acl:   zk.WorldACL(zk.PermAll),
+			Flags: zk.FlagSequence,
+		},
+		&zk.CreateRequest{
+			Path: path.Join(z.basePath, "settlements", item.ID),
+			Data: settlementData,
+			Acl:  zk.WorldACL(zk.PermAll),
+		},
+		&zk.SetDataRequest{
+			Path:    path.Join(z.basePath, "auctions", item.ID),
+			Data:    itemData,
+			Version: stat.Version,
+		},
+		highestRequest,
+		bidEvent,
+		closeEvent,
+	)
+	if settlementExists(resps, 1) {
+		return auction.Settlement{}, ErrAuctionClosed
+	}
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+	z.auctions.invalidate(item.ID)
+
+	return settlement, nil
+}
+
+// GetSettlement returns the outcome of a closed auction
+func (z *ZKStore) GetSettlement(ctx context.Context, id string) (auction.Settlement, error) {
+	settlementPath := path.Join(z.basePath, "settlements", id)
+	_, err := z.conn.Sync(settlementPath)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	data, _, err := z.conn.Get(settlementPath)
+	if err == zk.ErrNoNode {
+		if _, err := z.GetAuction(ctx, id); err != nil {
+			return auction.Settlement{}, err
+		}
+		return auction.Settlement{}, ErrAuctionNotClosed
+	}
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	var settlement auction.Settlement
+	if err := json.Unmarshal(data, &settlement); err != nil {
+		return auction.Settlement{}, err
+	}
+
+	return settlement, nil
+}
+
+// UpdateAuction applies a seller's update to an auction that has no bids
+// yet. The update checks the version of the highest bid znode, so a bid
+// cannot slip in between the check for bids and the update; if one does,
+// the update is checked again and fails with ErrAuctionHasBids.
+func (z *ZKStore) UpdateAuction(ctx context.Context, id, sellerID string, update auction.AuctionUpdate) (auction.AuctionItem, error) {
+	item, err := z.GetAuction(ctx, id)
+	if err != nil {
+		return auction.AuctionItem{}, err
+	}
+	if err := checkSeller(item, sellerID); err != nil {
+		return auction.AuctionItem{}, err
+	}
+
+	lock, err := z.lockAuction(ctx, id)
+	if err != nil {
+		return auction.AuctionItem{}, err
+	}
+	defer lock.Unlock()
+
+	if err := z.ensureEventsPath(id); err != nil {
+		return auction.AuctionItem{}, err
+	}
+	return retryConflicts(ctx, func() (auction.AuctionItem, error) {
+		return z.updateAuction(ctx, lock, id, sellerID, update)
+	})
+}
+
+// updateAuction reads the auction and its bids and applies the update, in
+// a transaction that fails with a conflict if a bid was placed since. The
+// caller must hold the auction lock.
+func (z *ZKStore) updateAuction(ctx context.Context, lock *zkLock, id, sellerID string, update auction.AuctionUpdate) (auction.AuctionItem, error) {
+	item, stat, err := z.getAuctionWithStat(id)
+	if err != nil {
+		return auction.AuctionItem{}, err
+	}
+	checks, err := z.highestChecks(ctx, id)
+	if err != nil {
+		return auction.AuctionItem{}, err
+	}
+	bids, err := z.GetBidHistory(ctx, id)
+	if err != nil {
+		return auction.AuctionItem{}, err
+	}
+
+	now := time.Now()
+	if err := checkUpdate(item, sellerID, update, len(bids), now); err != nil {
+		return auction.AuctionItem{}, err
+	}
+
+	item = update.Apply(item)
+	itemData, err := json.Marshal(item)
+	if err != nil {
+		return auction.AuctionItem{}, err
+	}
+
+	event, err := z.eventRequest(updatedEvent(item, now))
+	if err != nil {
+		return auction.AuctionItem{}, err
+	}
+
+	// Fail if a bid was placed after the history was read
+	ops := append([]interface{}{
+		&zk.SetDataRequest{
+			Path:    path.Join(z.basePath, "auctions", id),
+			Data:    itemData,
+			Version: stat.Version,
+		},
+		event,
+	}, checks...)
+	if err := lock.held(ctx); err != nil {
+		return auction.AuctionItem{}, err
+	}
+	if _, err := z.conn.Multi(ops...); err != nil {
+		return auction.AuctionItem{}, err
+	}
+	z.auctions.invalidate(id)
+
+	return item, nil
+}
+
+// CancelAuction withdraws an open auction, closing it without a sale. Like
+// CloseAuction it writes the settlement znode under the auction lock, so a
+// cancellation cannot race a sale or the closer.
+func (z *ZKStore) CancelAuction(ctx context.Context, id, sellerID, reason string) (auction.Settlement, error) {
+	item, err := z.GetAuction(ctx, id)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+	if err := checkSeller(item, sellerID); err != nil {
+		return auction.Settlement{}, err
+	}
+
+	lock, err := z.lockAuction(ctx, id)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+	defer lock.Unlock()
+
+	if err := z.ensureEventsPath(id); err != nil {
+		return auction.Settlement{}, err
+	}
+	return retryConflicts(ctx, func() (auction.Settlement, error) {
+		return z.cancelAuction(ctx, lock, id, sellerID, reason)
+	})
+}
+
+// cancelAuction reads the auction and closes it without a sale, in a
+// transaction that fails with a conflict if a bid changed the auction
+// since. The caller must hold the auction lock.
+func (z *ZKStore) cancelAuction(ctx context.Context, lock *zkLock, id, sellerID, reason string) (auction.Settlement, error) {
+	item, stat, err := z.getAuctionWithStat(id)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	now := time.Now()
+	if err := checkCancel(item, sellerID, now); err != nil {
+		return auction.Settlement{}, err
+	}
+
+	settlement := cancelSettlement(item, reason, now)
+	settlementData, err := json.Marshal(settlement)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	item.ClosedAt = &now
+	itemData, err := json.Marshal(item)
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	event, err := z.eventRequest(closedEvent(settlement))
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+
+	// Write the settlement and mark the auction closed atomically
+	if err := lock.held(ctx); err != nil {
+		return auction.Settlement{}, err
+	}
+	resps, err := z.conn.Multi(
+		&zk.CreateRequest{
+			Path: path.Join(z.basePath, "settlements", id),
+			Data: settlementData,
+			Acl:  zk.WorldACL(zk.PermAll),
+		},
+		&zk.SetDataRequest{
+			Path:    path.Join(z.basePath, "auctions", id),
+			Data:    itemData,
+			Version: stat.Version,
+		},
+		event,
+	)
+	if settlementExists(resps, 0) {
+		return auction.Settlement{}, ErrAuctionClosed
+	}
+	if err != nil {
+		return auction.Settlement{}, err
+	}
+	z.auctions.invalidate(id)
+
+	return settlement, nil
+}
+
+// ensureEventsPath creates the znode holding an auction's events. Auctions
+// created before events were recorded do not have one yet.
+func (z *ZKStore) ensureEventsPath(auctionID string) error {
+	_, err := z.conn.Create(path.Join(z.basePath, "events", auctionID), []byte{}, 0, zk.WorldACL(zk.PermAll))
+	if err != nil && err != zk.ErrNodeExists {
+		return err
+	}
+	return nil
+}
+
+// eventRequest returns the request that records an event. Events are
+// sequential znodes, so ZooKeeper numbers them in commit order and every
+// server reads the same sequence numbers.
+func (z *ZKStore) eventRequest(event auction.Event) (*zk.CreateRequest, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return &zk.CreateRequest{
+		Path:  path.Join(z.basePath, "events", event.AuctionID, "event-"),
+		Data:  data,
+		Acl:   zk.WorldACL(zk.PermAll),
+		Flags: zk.FlagSequence,
+	}, nil
+}
+
+// Subscribe streams the events of an auction with a sequence number above
+// after. A child watch on the auction's events znode wakes the subscription,
+// so events recorded through any server reach subscribers on every server.
+func (z *ZKStore) Subscribe(ctx context.Context, auctionID string, after uint64) (<-chan auction.Event, error) {
+	if _, err := z.GetAuction(ctx, auctionID); err != nil {
+		return nil, err
+	}
+	if err := z.ensureEventsPath(auctionID); err != nil {
+		return nil, err
+	}
+
+	return followEvents(ctx, auctionID, after, func(after uint64) ([]auction.Event, <-chan zk.Event, error) {
+		return z.eventsAfter(auctionID, after)
+	}), nil
+}
+
+// eventsAfter reads the events of an auction with a sequence number above
+// after, and sets a watch that fires when another event is recorded
+func (z *ZKStore) eventsAfter(auctionID string, after uint64) ([]auction.Event, <-chan zk.Event, error) {
+	eventsPath := path.Join(z.basePath, "events", auctionID)
+	children, _, watch, err := z.conn.ChildrenW(eventsPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The zero padded sequence suffix sorts in commit order
+	sort.Strings(children)
+
+	var events []auction.Event
+	for _, child := range children {
+		// ZooKeeper numbers sequential znodes from 0, events are numbered from 1
+		suffix, err := strconv.ParseUint(strings.TrimPrefix(child, "event-"), 10, 64)
+		if err != nil || suffix+1 <= after {
+			continue
+		}
+
+		data, _, err := z.conn.Get(path.Join(eventsPath, child))
+		if err != nil {
+			return nil, nil, err
+		}
+		var event auction.Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, nil, err
+		}
+		event.Sequence = suffix + 1
+		events = append(events, event)
+	}
+
+	return events, watch, nil
+}
+
+// RegisterParticipant adds a participant to the registry. Creating the
+// znode fails if it exists, so two servers cannot register the same ID.
+func (z *ZKStore) RegisterParticipant(ctx context.Context, participant auction.Participant) (auction.Participant, error) {
+	participant.CreatedAt = time.Now()
+	data, err := json.Marshal(participant)
+	if err != nil {
+		return auction.Participant{}, err
+	}
+
+	participantPath := path.Join(z.basePath, "participants", participant.ID)
+	_, err = z.conn.Create(participantPath, data, 0, zk.WorldACL(zk.PermAll))
+	if err == zk.ErrNodeExists {
+		return auction.Participant{}, ErrParticipantExists
+	}
+	if err != nil {
+		return auction.Participant{}, err
+	}
+	return participant, nil
+}
+
+// GetParticipant retrieves a participant by ID
+func (z *ZKStore) GetParticipant(ctx context.Context, id string) (auction.Participant, error) {
+	participantPath := path.Join(z.basePath, "participants", id)
+	if _, err := z.conn.Sync(participantPath); err != nil && err != zk.ErrNoNode {
+		return auction.Participant{}, err
+	}
+	data, _, err := z.conn.Get(participantPath)
+	if err == zk.ErrNoNode {
+		return auction.Participant{}, ErrParticipantNotFound
+	}
+	if err != nil {
+		return auction.Participant{}, err
+	}
+
+	var participant auction.Participant
+	if err := json.Unmarshal(data, &participant); err != nil {
+		return auction.Participant{}, err
+	}
+	return participant, nil
+}
+
+// isConflict reports whether a transaction failed because a node it checks
+// changed since it was read, so it can be read again and retried
+func isConflict(err error) bool {
+	return err == zk.ErrBadVersion || err == zk.ErrNodeExists || err == zk.ErrNoNode
+}
+
+// retryConflicts runs a write until its transaction commits without a
+// conflict. Bids take no lock, so even a write that holds the auction lock
+// fails when a bid commits between its reads and its transaction; it then
+// reads everything again and re-runs its checks. After maxBidAttempts it
+// gives up with ErrBidConflict.
+func retryConflicts[T any](ctx context.Context, write func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := write()
+		if !isConflict(err) {
+			return result, err
+		}
+		var zero T
+		if attempt == maxBidAttempts {
+			return zero, ErrBidConflict
+		}
+		select {
+		case <-time.After(time.Duration(rand.Int63n(int64(attempt) * int64(time.Millisecond)))):
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// settlementExists reports whether a transaction failed because the
+// settlement znode it creates, at index op, was written first. Other
+// conflicts mean a bid committed since the transaction's reads.
+func settlementExists(resps []zk.MultiResponse, op int) bool {
+	return op < len(resps) && resps[op].Error == zk.ErrNodeExists
+}
+
+// idempotencyPath returns the znode recording the result of a key
+func (z *ZKStore) idempotencyPath(key IdempotencyKey) string {
+	return path.Join(z.basePath, "idempotency", key.name())
+}
+
+// recordedResult reads the result recorded for a key, if any
+func (z *ZKStore) recordedResult(key IdempotencyKey) (idempotentResult, bool, error) {
+	if key.IsZero() {
+		return idempotentResult{}, false, nil
+	}
+
+	data, _, err := z.conn.Get(z.idempotencyPath(key))
+	if err == zk.ErrNoNode {
+		return idempotentResult{}, false, nil
+	}
+	if err != nil {
+		return idempotentResult{}, false, err
+	}
+
+	var result idempotentResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return idempotentResult{}, false, err
+	}
+	return result, true, nil
+}
+
+// multiIdempotent runs the operations of a write in a transaction that also
+// creates the record of its key. If a write with the same key committed
+// first, the transaction fails on the record and replayed is true, with
+// the result recorded by that write.
+func (z *ZKStore) multiIdempotent(key IdempotencyKey, result idempotentResult, ops ...interface{}) (recorded idempotentResult, replayed bool, err error) {
+	if key.IsZero() {
+		_, err := z.conn.Multi(ops...)
+		return idempotentResult{}, false, err
+	}
+
+	result.Fingerprint = key.Fingerprint
+	result.CreatedAt = time.Now()
+	data, err := json.Marshal(result)
+	if err != nil {
+		return idempotentResult{}, false, err
+	}
+	record := &zk.CreateRequest{Path: z.idempotencyPath(key), Data: data, Acl: zk.WorldACL(zk.PermAll)}
+
+	resps, err := z.conn.Multi(append([]interface{}{record}, ops...)...)
+	if err != nil && len(resps) > 0 && resps[0].Error == zk.ErrNodeExists {
+		recorded, ok, readErr := z.recordedResult(key)
+		if readErr != nil || !ok {
+			return idempotentResult{}, false, err
+		}
+		return recorded, true, nil
+	}
+	return idempotentResult{}, false, err
+}
+
+// SweepIdempotencyKeys removes the records of keys first used before the
+// given time, going by the creation time of their znodes. A record that
+// another server removes first is skipped.
+func (z *ZKStore) SweepIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	recordsPath := path.Join(z.basePath, "idempotency")
+	names, _, err := z.conn.Children(recordsPath)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		recordPath := path.Join(recordsPath, name)
+		exists, stat, err := z.conn.Exists(recordPath)
+		if err != nil {
+			return removed, err
+		}
+		if !exists || !time.UnixMilli(stat.Ctime).Before(before) {
+			continue
+		}
+		err = z.conn.Delete(recordPath, stat.Version)
+		if err == zk.ErrNoNode || err == zk.ErrBadVersion {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
 }
@@ -0,0 +1,459 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/go-zookeeper/zk"
+)
+
+// zkBidModes are the ways ZKStore serializes bids
+var zkBidModes = []struct {
+	name string
+	opts []ZKOption
+}{
+	{"optimistic", nil},
+	{"locked", []ZKOption{WithLockedBids()}},
+}
+
+// creates reports whether a transaction creates a znode under the given
+// directory of the store, such as "bids" or "settlements"
+func creates(ops []interface{}, dir string) bool {
+	for _, op := range ops {
+		if create, ok := op.(*zk.CreateRequest); ok && strings.Contains(create.Path, "/auction/"+dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// raceOnce runs write before the next transaction that matches, once. The
+// transactions of write itself do not trigger it again.
+func raceOnce(f *fakeZK, match func(ops []interface{}) bool, write func()) {
+	var armed atomic.Bool
+	armed.Store(true)
+	f.setBeforeMulti(func(ops []interface{}) {
+		if match(ops) && armed.CompareAndSwap(true, false) {
+			write()
+		}
+	})
+}
+
+func createZKAuction(t *testing.T, store *ZKStore, item auction.AuctionItem) auction.AuctionItem {
+	t.Helper()
+	if item.SellerID == "" {
+		item.SellerID = "sam"
+	}
+	if item.MinimumBid.IsZero() {
+		item.MinimumBid = usd(10)
+	}
+	if item.ExpiryTime.IsZero() {
+		item.ExpiryTime = time.Now().Add(time.Hour)
+	}
+	created, err := store.CreateAuction(context.Background(), item)
+	if err != nil {
+		t.Fatalf("Failed to create auction: %v", err)
+	}
+	return created
+}
+
+// checkHistory checks that the bids on an auction rise in sequence order,
+// and that the highest bid and the current bid are the last of them
+func checkHistory(t *testing.T, store *ZKStore, auctionID string) []auction.Bid {
+	t.Helper()
+	ctx := context.Background()
+	bids, err := store.GetBidHistory(ctx, auctionID)
+	if err != nil {
+		t.Fatalf("Failed to get bid history: %v", err)
+	}
+	for i := 1; i < len(bids); i++ {
+		if bids[i].Sequence <= bids[i-1].Sequence || !bids[i-1].BidPrice.Less(bids[i].BidPrice) {
+			t.Fatalf("Bid %d (%s, #%d) does not follow bid %d (%s, #%d)", i, bids[i].BidPrice, bids[i].Sequence, i-1, bids[i-1].BidPrice, bids[i-1].Sequence)
+		}
+	}
+	if len(bids) == 0 {
+		return bids
+	}
+
+	last := bids[len(bids)-1]
+	highest, err := store.GetHighestBid(ctx, auctionID)
+	if err != nil || highest.ID != last.ID {
+		t.Fatalf("Expected the highest bid to be the last one %s, got %s: %v", last.ID, highest.ID, err)
+	}
+	item, _ := store.GetAuction(ctx, auctionID)
+	if item.CurrentBid != last.BidPrice {
+		t.Fatalf("Expected the current bid to be %s, got %s", last.BidPrice, item.CurrentBid)
+	}
+	return bids
+}
+
+func TestZKConcurrentBids(t *testing.T) {
+	for _, mode := range zkBidModes {
+		t.Run(mode.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := newFakeZK()
+			servers := []*ZKStore{fake.newStore(t, mode.opts...), fake.newStore(t, mode.opts...)}
+			item := createZKAuction(t, servers[0], auction.AuctionItem{Name: "Lamp"})
+
+			// Bidders on both servers race with rising bids
+			var wg sync.WaitGroup
+			var placed sync.Map
+			for b := 0; b < 6; b++ {
+				wg.Add(1)
+				go func(b int) {
+					defer wg.Done()
+					store := servers[b%2]
+					for i := 1; i <= 8; i++ {
+						bid, err := store.PlaceBid(ctx, auction.Bid{
+							AuctionItemID: item.ID,
+							ParticipantID: fmt.Sprintf("bidder%d", b),
+							BidPrice:      usd(float64(10*i + b)),
+						})
+						var tooLow *BidTooLowError
+						switch {
+						case err == nil:
+							placed.Store(bid.ID, bid)
+						case !errors.As(err, &tooLow) && !errors.Is(err, ErrBidConflict):
+							t.Errorf("Failed to place bid: %v", err)
+							return
+						}
+					}
+				}(b)
+			}
+			wg.Wait()
+
+			// Every accepted bid is in the history, which only rises
+			bids := checkHistory(t, servers[1], item.ID)
+			inHistory := make(map[string]bool)
+			for _, bid := range bids {
+				inHistory[bid.ID] = true
+			}
+			accepted := 0
+			placed.Range(func(id, _ any) bool {
+				accepted++
+				if !inHistory[id.(string)] {
+					t.Errorf("Accepted bid %s is missing from the history", id)
+				}
+				return true
+			})
+			if accepted == 0 || accepted != len(bids) {
+				t.Errorf("Expected the %d accepted bids in the history, got %d", accepted, len(bids))
+			}
+		})
+	}
+}
+
+func TestZKBidRetriesAfterConflict(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeZK()
+	store, other := fake.newStore(t), fake.newStore(t)
+	item := createZKAuction(t, store, auction.AuctionItem{Name: "Lamp"})
+
+	// Another server commits a bid between the reads of this one and its
+	// transaction, which then fails on the highest bid and is retried
+	raceOnce(fake, func(ops []interface{}) bool { return creates(ops, "bids") }, func() {
+		if _, err := other.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "alice", BidPrice: usd(20)}); err != nil {
+			t.Errorf("Failed to place racing bid: %v", err)
+		}
+	})
+	bid, err := store.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "bob", BidPrice: usd(30)})
+	if err != nil {
+		t.Fatalf("Expected the bid to be retried, got %v", err)
+	}
+	bids := checkHistory(t, store, item.ID)
+	if len(bids) != 2 || bids[0].ParticipantID != "alice" || bids[1].ID != bid.ID || bid.Sequence != bids[1].Sequence {
+		t.Fatalf("Expected alice's bid and then bob's, got %+v", bids)
+	}
+
+	// A retry re-runs the checks, so a bid overtaken by the racing one fails
+	raceOnce(fake, func(ops []interface{}) bool { return creates(ops, "bids") }, func() {
+		other.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "alice", BidPrice: usd(50)})
+	})
+	_, err = store.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "bob", BidPrice: usd(40)})
+	var tooLow *BidTooLowError
+	if !errors.As(err, &tooLow) || tooLow.NextMinimumBid != usd(50.01) {
+		t.Fatalf("Expected the retried bid to be too low, got %v", err)
+	}
+
+	// A bid that keeps losing the race gives up
+	fake.setBeforeMulti(func(ops []interface{}) {
+		if creates(ops, "bids") {
+			touch(t, fake, item.ID)
+		}
+	})
+	if _, err := store.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "bob", BidPrice: usd(60)}); !errors.Is(err, ErrBidConflict) {
+		t.Fatalf("Expected ErrBidConflict after %d attempts, got %v", maxBidAttempts, err)
+	}
+}
+
+// touch rewrites an auction znode unchanged, bumping its version as a
+// racing write would
+func touch(t *testing.T, fake *fakeZK, auctionID string) {
+	conn := fake.connect()
+	p := "/auction/auctions/" + auctionID
+	data, _, err := conn.Get(p)
+	if err == nil {
+		_, err = conn.Multi(&zk.SetDataRequest{Path: p, Data: data, Version: -1})
+	}
+	if err != nil {
+		t.Errorf("Failed to touch auction %s: %v", auctionID, err)
+	}
+}
+
+func TestZKBuyNowRetriesAfterBid(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeZK()
+	store, other := fake.newStore(t), fake.newStore(t)
+	isSale := func(ops []interface{}) bool { return creates(ops, "settlements") }
+
+	// A bid below the buy-now price commits first: the buy reads the
+	// auction again and still goes through
+	below := createZKAuction(t, store, auction.AuctionItem{Name: "Bike", BuyNowPrice: usd(100)})
+	raceOnce(fake, isSale, func() {
+		if _, err := other.PlaceBid(ctx, auction.Bid{AuctionItemID: below.ID, ParticipantID: "alice", BidPrice: usd(50)}); err != nil {
+			t.Errorf("Failed to place racing bid: %v", err)
+		}
+	})
+	settlement, err := store.BuyNow(ctx, below.ID, "bob")
+	if err != nil {
+		t.Fatalf("Expected the buy to be retried, got %v", err)
+	}
+	if settlement.WinnerID != "bob" || settlement.ClearingPrice != usd(100) {
+		t.Fatalf("Expected bob to buy at 100, got %+v", settlement)
+	}
+	bids := checkHistory(t, store, below.ID)
+	if len(bids) != 2 || bids[0].ParticipantID != "alice" || bids[1].ParticipantID != "bob" {
+		t.Fatalf("Expected alice's bid and then bob's purchase, got %+v", bids)
+	}
+
+	// A bid that reaches the buy-now price commits first: the buy fails as
+	// if it had come after the bid
+	reached := createZKAuction(t, store, auction.AuctionItem{Name: "Bike", BuyNowPrice: usd(100)})
+	raceOnce(fake, isSale, func() {
+		other.PlaceBid(ctx, auction.Bid{AuctionItemID: reached.ID, ParticipantID: "alice", BidPrice: usd(100)})
+	})
+	if _, err := store.BuyNow(ctx, reached.ID, "bob"); !errors.Is(err, ErrBuyNowReached) {
+		t.Fatalf("Expected ErrBuyNowReached, got %v", err)
+	}
+	if _, err := store.GetSettlement(ctx, reached.ID); !errors.Is(err, ErrAuctionNotClosed) {
+		t.Fatalf("Expected the auction to stay open, got %v", err)
+	}
+
+	// Another buyer who settles first wins, and this one finds it closed
+	closed := createZKAuction(t, store, auction.AuctionItem{Name: "Bike", BuyNowPrice: usd(100)})
+	raceOnce(fake, isSale, func() {
+		fake.connect().Create("/auction/settlements/"+closed.ID, []byte("{}"), 0, zk.WorldACL(zk.PermAll))
+	})
+	if _, err := store.BuyNow(ctx, closed.ID, "bob"); !errors.Is(err, ErrAuctionClosed) {
+		t.Fatalf("Expected ErrAuctionClosed, got %v", err)
+	}
+}
+
+func TestZKBidsRaceBuyNow(t *testing.T) {
+	for _, mode := range zkBidModes {
+		t.Run(mode.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := newFakeZK()
+			bidders, buyer := fake.newStore(t, mode.opts...), fake.newStore(t, mode.opts...)
+			item := createZKAuction(t, bidders, auction.AuctionItem{Name: "Bike", BuyNowPrice: usd(100)})
+
+			var wg sync.WaitGroup
+			for b := 0; b < 4; b++ {
+				wg.Add(1)
+				go func(b int) {
+					defer wg.Done()
+					for i := 1; i <= 12; i++ {
+						_, err := bidders.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: fmt.Sprintf("bidder%d", b), BidPrice: usd(float64(10*i + b))})
+						if errors.Is(err, ErrAuctionClosed) {
+							return
+						}
+					}
+				}(b)
+			}
+			var settlement auction.Settlement
+			var buyErr error
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				time.Sleep(time.Millisecond)
+				settlement, buyErr = buyer.BuyNow(ctx, item.ID, "buyer")
+			}()
+			wg.Wait()
+
+			// Either the buyer won at the buy-now price, ending the bidding,
+			// or bidding reached that price first
+			bids := checkHistory(t, buyer, item.ID)
+			last := bids[len(bids)-1]
+			switch {
+			case buyErr == nil:
+				if settlement.WinnerID != "buyer" || last.ParticipantID != "buyer" || last.BidPrice != usd(100) {
+					t.Fatalf("Expected the buyer's purchase to be the last bid, got %+v and %+v", settlement, last)
+				}
+				recorded, err := bidders.GetSettlement(ctx, item.ID)
+				if err != nil || recorded.WinningBidID != last.ID {
+					t.Fatalf("Expected the settlement of the purchase, got %+v: %v", recorded, err)
+				}
+			case errors.Is(buyErr, ErrBuyNowReached):
+				if last.BidPrice.Less(usd(100)) {
+					t.Fatalf("Buy-now was rejected below its price, at %s", last.BidPrice)
+				}
+			default:
+				t.Fatalf("Failed to buy now: %v", buyErr)
+			}
+		})
+	}
+}
+
+func TestZKCloseAuctionRetriesAfterBid(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeZK()
+	closer, other := fake.newStore(t), fake.newStore(t)
+	item := createZKAuction(t, closer, auction.AuctionItem{Name: "Lamp", ExpiryTime: time.Now().Add(100 * time.Millisecond)})
+	if _, err := closer.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "alice", BidPrice: usd(20)}); err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+
+	// A bid checked against the expiry just in time commits only after the
+	// closer has read the bids
+	bidRead, release := make(chan struct{}), make(chan struct{})
+	var bobBid atomic.Bool
+	fake.setBeforeMulti(func(ops []interface{}) {
+		switch {
+		case creates(ops, "settlements"):
+			close(release)
+			for !bobBid.Load() {
+				time.Sleep(time.Millisecond)
+			}
+			fake.setBeforeMulti(nil)
+		case creates(ops, "bids"):
+			close(bidRead)
+			<-release
+		}
+	})
+	go func() {
+		if _, err := other.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: "bob", BidPrice: usd(30)}); err != nil {
+			t.Errorf("Failed to place late bid: %v", err)
+		}
+		bobBid.Store(true)
+	}()
+	<-bidRead
+	time.Sleep(time.Until(item.ExpiryTime) + time.Millisecond)
+
+	settlement, err := closer.CloseAuction(ctx, item.ID)
+	if err != nil {
+		t.Fatalf("Expected the close to be retried, got %v", err)
+	}
+	bids := checkHistory(t, closer, item.ID)
+	if settlement.WinnerID != "bob" || settlement.WinningBidID != bids[len(bids)-1].ID {
+		t.Fatalf("Expected bob's late bid to win, got %+v", settlement)
+	}
+	if recorded, _ := other.GetSettlement(ctx, item.ID); recorded.WinningBidID != settlement.WinningBidID {
+		t.Fatalf("Expected every server to see the same winner, got %+v", recorded)
+	}
+}
+
+func TestZKBidsRaceClose(t *testing.T) {
+	for _, mode := range zkBidModes {
+		t.Run(mode.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := newFakeZK()
+			bidders, closer := fake.newStore(t, mode.opts...), fake.newStore(t, mode.opts...)
+			item := createZKAuction(t, bidders, auction.AuctionItem{Name: "Lamp", ExpiryTime: time.Now().Add(20 * time.Millisecond)})
+
+			var wg sync.WaitGroup
+			var placed sync.Map
+			for b := 0; b < 4; b++ {
+				wg.Add(1)
+				go func(b int) {
+					defer wg.Done()
+					for i := 1; ; i++ {
+						bid, err := bidders.PlaceBid(ctx, auction.Bid{AuctionItemID: item.ID, ParticipantID: fmt.Sprintf("bidder%d", b), BidPrice: usd(float64(10*i + b))})
+						if errors.Is(err, ErrAuctionExpired) || errors.Is(err, ErrAuctionClosed) {
+							return
+						}
+						if err == nil {
+							placed.Store(bid.ID, bid)
+						}
+					}
+				}(b)
+			}
+			var settlement auction.Settlement
+			var closeErr error
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					settlement, closeErr = closer.CloseAuction(ctx, item.ID)
+					if !errors.Is(closeErr, ErrAuctionNotExpired) {
+						return
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}()
+			wg.Wait()
+			if closeErr != nil {
+				t.Fatalf("Failed to close auction: %v", closeErr)
+			}
+
+			// The winner is the highest bid, and no accepted bid is missing
+			bids := checkHistory(t, closer, item.ID)
+			if len(bids) == 0 {
+				t.Fatalf("Expected bids before the auction expired")
+			}
+			if last := bids[len(bids)-1]; settlement.WinningBidID != last.ID {
+				t.Fatalf("Expected the highest bid %s to win, got %+v", last.ID, settlement)
+			}
+			placed.Range(func(_, value any) bool {
+				if bid := value.(auction.Bid); settlement.ClearingPrice.Less(bid.BidPrice) {
+					t.Errorf("Accepted bid of %s is above the clearing price %s", bid.BidPrice, settlement.ClearingPrice)
+				}
+				return true
+			})
+		})
+	}
+}
+
+func TestZKSellerWritesRetryAfterBid(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeZK()
+	store, other := fake.newStore(t), fake.newStore(t)
+	isAuctionWrite := func(ops []interface{}) bool { return !creates(ops, "bids") }
+	later := time.Now().Add(2 * time.Hour)
+	update := auction.AuctionUpdate{ExpiryTime: &later}
+
+	// An update that loses the race to a bid is checked again
+	bidOn := createZKAuction(t, store, auction.AuctionItem{Name: "Lamp"})
+	raceOnce(fake, isAuctionWrite, func() {
+		other.PlaceBid(ctx, auction.Bid{AuctionItemID: bidOn.ID, ParticipantID: "alice", BidPrice: usd(20)})
+	})
+	if _, err := store.UpdateAuction(ctx, bidOn.ID, "sam", update); !errors.Is(err, ErrAuctionHasBids) {
+		t.Fatalf("Expected ErrAuctionHasBids, got %v", err)
+	}
+
+	// A change that is not a bid only makes it retry
+	touched := createZKAuction(t, store, auction.AuctionItem{Name: "Lamp"})
+	raceOnce(fake, isAuctionWrite, func() { touch(t, fake, touched.ID) })
+	if updated, err := store.UpdateAuction(ctx, touched.ID, "sam", update); err != nil || !updated.ExpiryTime.Equal(later) {
+		t.Fatalf("Expected the update to be retried, got %+v: %v", updated, err)
+	}
+
+	// A cancellation is retried past a bid
+	cancelled := createZKAuction(t, store, auction.AuctionItem{Name: "Lamp"})
+	raceOnce(fake, isAuctionWrite, func() {
+		other.PlaceBid(ctx, auction.Bid{AuctionItemID: cancelled.ID, ParticipantID: "alice", BidPrice: usd(20)})
+	})
+	settlement, err := store.CancelAuction(ctx, cancelled.ID, "sam", "damaged")
+	if err != nil || settlement.Outcome != auction.OutcomeCancelled {
+		t.Fatalf("Expected the cancellation to be retried, got %+v: %v", settlement, err)
+	}
+	if _, err := other.PlaceBid(ctx, auction.Bid{AuctionItemID: cancelled.ID, ParticipantID: "alice", BidPrice: usd(30)}); !errors.Is(err, ErrAuctionClosed) {
+		t.Fatalf("Expected bids on the cancelled auction to fail, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/storage"
+)
+
+// zkHosts are the ZooKeeper nodes started by docker compose
+var zkHosts = []string{"localhost:2181", "localhost:2182", "localhost:2183"}
+
+// BenchmarkZKPlaceBidOptimistic measures bids that take no lock and retry
+// when another bid commits first
+func BenchmarkZKPlaceBidOptimistic(b *testing.B) {
+	benchmarkZKPlaceBid(b, "/auction-bench-optimistic")
+}
+
+// BenchmarkZKPlaceBidLocked measures bids that take the auction lock
+func BenchmarkZKPlaceBidLocked(b *testing.B) {
+	benchmarkZKPlaceBid(b, "/auction-bench-locked", storage.WithLockedBids())
+}
+
+// benchmarkZKPlaceBid places rising bids on a single auction from parallel
+// goroutines, so that every bid contends with the others. Bids overtaken by
+// a higher one before they commit are rejected as too low, and bids that
+// lose every retry are reported as conflicts.
+func benchmarkZKPlaceBid(b *testing.B, basePath string, opts ...storage.ZKOption) {
+	conn, err := net.DialTimeout("tcp", zkHosts[0], time.Second)
+	if err != nil {
+		b.Skipf("ZooKeeper not reachable at %s: %v", zkHosts[0], err)
+	}
+	conn.Close()
+
+	store, err := storage.NewZKStore(zkHosts, basePath, opts...)
+	if err != nil {
+		b.Fatalf("Failed to connect to ZooKeeper: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	item, err := store.CreateAuction(ctx, auction.AuctionItem{
+		Name:       "Benchmark lamp",
+		SellerID:   "bench-seller",
+		MinimumBid: auction.NewMoney(100, "USD"),
+		ExpiryTime: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		b.Fatalf("Failed to create auction: %v", err)
+	}
+
+	var price, bidders, conflicts int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		bidder := fmt.Sprintf("bench-bidder-%d", atomic.AddInt64(&bidders, 1))
+		for pb.Next() {
+			_, err := store.PlaceBid(ctx, auction.Bid{
+				AuctionItemID: item.ID,
+				ParticipantID: bidder,
+				BidPrice:      auction.NewMoney(100*atomic.AddInt64(&price, 1), "USD"),
+			})
+			var tooLow *storage.BidTooLowError
+			switch {
+			case errors.Is(err, storage.ErrBidConflict):
+				atomic.AddInt64(&conflicts, 1)
+			case err != nil && !errors.As(err, &tooLow):
+				b.Errorf("Failed to place bid: %v", err)
+				return
+			}
+		}
+	})
+	b.ReportMetric(float64(conflicts)/float64(b.N), "conflicts/op")
+}
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
+	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/client"
+)
+
+// Server URLs for the three different servers, replaced by the servers that
+// the seed reports once a test requires the cluster
+var serverURLs = []string{
+	"http://localhost:8080",
+	"http://localhost:8081",
+	"http://localhost:8082",
+}
+
+// Helper function to get a random server URL
+func getRandomServerURL() string {
+	randomIdx := rand.Intn(len(serverURLs))
+	return serverURLs[randomIdx]
+}
+
+// discoverOnce asks the seed server for the live servers a single time
+var discoverOnce sync.Once
+
+// discoverServers replaces serverURLs with the members of the cluster of the
+// seed server, $AUCTION_SEED or the first of serverURLs. The defaults stay
+// when the seed cannot be reached or only knows itself.
+func discoverServers() {
+	seed := os.Getenv("AUCTION_SEED")
+	if seed == "" {
+		seed = serverURLs[0]
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	members, err := client.New([]string{seed}).Cluster(ctx)
+	if err != nil || len(members) < 2 {
+		return
+	}
+	urls := make([]string, 0, len(members))
+	for _, member := range members {
+		urls = append(urls, member.URL)
+	}
+	serverURLs = urls
+}
+
+// requireCluster skips the test unless every server is reachable, since
+// these tests run against the docker compose cluster
+func requireCluster(t *testing.T) {
+	t.Helper()
+	discoverOnce.Do(discoverServers)
+	for _, url := range serverURLs {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_, err := client.New([]string{url}).ListAuctions(ctx, auction.ListQuery{Limit: 1})
+		cancel()
+		if err != nil {
+			t.Skipf("Cluster not reachable at %s: %v", url, err)
+		}
+	}
+}
+
+// newServerClient returns a client that only talks to the server at url
+func newServerClient(url string) *client.Client {
+	return client.New([]string{url})
+}
+
+// newParticipantClient registers a participant through the server at url
+// and returns a client that only talks to that server, authenticated as them
+func newParticipantClient(ctx context.Context, url, participantID string) (*client.Client, error) {
+	registration, err := newServerClient(url).Register(ctx, participantID)
+	if err != nil {
+		return nil, err
+	}
+	return client.New([]string{url}, client.WithAPIKey(registration.APIKey)), nil
+}
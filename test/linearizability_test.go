@@ -1,12 +1,9 @@
 package test
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"math/rand"
-	"net/http"
 	"sort"
 	"sync"
 	"testing"
@@ -15,207 +12,26 @@ import (
 	"github.com/PranavGrandhi/Distributed-Auction-System/pkg/auction"
 )
 
-// Server URLs for the three different servers
-var serverURLs = []string{
-	"http://localhost:8080",
-	"http://localhost:8081",
-	"http://localhost:8082",
-}
-
-// Helper function to get bid history for an auction
-func getBidHistory(t *testing.T, serverURL, auctionID string) ([]auction.Bid, error) {
-	// Create a unique request to prevent caching
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/auctions/%s/history", serverURL, auctionID), nil)
+// getBidHistory returns the bid history of an auction from one server,
+// sorted by timestamp for consistent comparison
+func getBidHistory(ctx context.Context, serverURL, auctionID string) ([]auction.Bid, error) {
+	bids, err := newServerClient(serverURL).BidHistory(ctx, auctionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-
-	// Add a unique header to prevent request caching
-	req.Header.Add("Cache-Control", "no-cache")
-	req.Header.Add("X-Test-ID", fmt.Sprintf("%d", time.Now().UnixNano()))
-
-	// Execute the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get bid history: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	var bids []auction.Bid
-	if err := json.NewDecoder(resp.Body).Decode(&bids); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %v", err)
+		return nil, err
 	}
 
-	// Sort bids by timestamp for consistent comparison
 	sort.Slice(bids, func(i, j int) bool {
 		return bids[i].Timestamp.Before(bids[j].Timestamp)
 	})
-
 	return bids, nil
 }
 
-// Helper function to get a random server URL
-func getRandomServerURL() string {
-	randomIdx := rand.Intn(len(serverURLs))
-	return serverURLs[randomIdx]
-}
-
-// Helper function to get all auctions
-func getAuctions(t *testing.T, serverURL string) (*AuctionList, error) {
-	// Create a unique request to prevent caching
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/auctions", serverURL), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-
-	// Add a unique header to prevent request caching
-	req.Header.Add("Cache-Control", "no-cache")
-	req.Header.Add("X-Test-ID", fmt.Sprintf("%d", time.Now().UnixNano()))
-
-	// Execute the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get auctions: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	// Read the response body
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	// Direct list of auctions
-	var directAuctions []auction.AuctionItem
-	if err := json.Unmarshal(bodyBytes, &directAuctions); err == nil {
-		t.Log("Direct auction list parsed successfully")
-		return &AuctionList{Auctions: directAuctions}, nil
-	}
-
-	return nil, fmt.Errorf("failed to parse response as auction data: %s", string(bodyBytes))
-}
-
-// Helper function to get a specific auction
-func getAuctionStatus(t *testing.T, serverURL, auctionID string) (*AuctionStatus, error) {
-	// Create a unique request to prevent caching
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/auctions/%s/status", serverURL, auctionID), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-
-	// Add a unique header to prevent request caching
-	req.Header.Add("Cache-Control", "no-cache")
-	req.Header.Add("X-Test-ID", fmt.Sprintf("%d", time.Now().UnixNano()))
-
-	// Execute the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get auction: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	var auctionItem AuctionStatus
-	if err := json.NewDecoder(resp.Body).Decode(&auctionItem); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %v", err)
-	}
-
-	return &auctionItem, nil
-}
-
-// Helper function to place a bid
-func placeBid(t *testing.T, serverURL, auctionID string, amount float64) (*auction.Bid, error) {
-	bidRequest := PlaceBidRequest{
-		BidPrice:      amount,
-		AuctionItemId: auctionID,
-		ParticipantId: fmt.Sprintf("test-participant-%d", time.Now().UnixNano()),
-		Timestamp:     time.Now(),
-	}
-
-	requestBody, err := json.Marshal(bidRequest)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal bid request: %v", err)
-	}
-
-	// Create a unique request to prevent caching
-	req, err := http.NewRequest("POST",
-		fmt.Sprintf("%s/auctions/%s/bids", serverURL, auctionID),
-		bytes.NewBuffer(requestBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-
-	// Add content-type and cache-busting headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Add("Cache-Control", "no-cache")
-	req.Header.Add("X-Test-ID", fmt.Sprintf("%d", time.Now().UnixNano()))
-
-	// Execute the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to place bid: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response body for error messages
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("bid failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var bid auction.Bid
-	if err := json.Unmarshal(body, &bid); err != nil {
-		// If we can't parse the response as a Bid, it might be a success message
-		return nil, nil
-	}
-
-	return &bid, nil
-}
-
-// Also need to add these missing struct types
-type AuctionList struct {
-	Auctions []auction.AuctionItem `json:"auctions"`
-}
-
-type PlaceBidRequest struct {
-	BidPrice      float64   `json:"bid_price"`
-	AuctionItemId string    `json:"auction_item_id"`
-	ParticipantId string    `json:"participant_id"`
-	Timestamp     time.Time `json:"timestamp"`
-}
-
-type AuctionStatus struct {
-	Auction       auction.AuctionItem `json:"auction"`
-	HighestBid    *auction.Bid        `json:"highest_bid,omitempty"`
-	Status        string              `json:"status"`
-	TimeRemaining string              `json:"time_remaining,omitempty"`
-}
-
 // TestLinearizabilityAcrossServers tests that all servers maintain consistent bid histories
 // with concurrent clients placing bids
 func TestLinearizabilityAcrossServers(t *testing.T) {
+	requireCluster(t)
+	ctx := context.Background()
+
 	// Generate a unique test ID to prevent test caching
 	testID := time.Now().UnixNano()
 	rand.Seed(testID)
@@ -232,16 +48,17 @@ func TestLinearizabilityAcrossServers(t *testing.T) {
 	randomServerURL := getRandomServerURL()
 	t.Logf("Using server at %s for initial auctions list", randomServerURL)
 
-	auctions, err := getAuctions(t, randomServerURL)
+	page, err := newServerClient(randomServerURL).ListAuctions(ctx, auction.ListQuery{Status: auction.StatusActive})
 	if err != nil {
 		t.Fatalf("Failed to get auctions: %v", err)
 	}
+	auctions := page.Auctions
 
-	if len(auctions.Auctions) == 0 {
+	if len(auctions) == 0 {
 		t.Fatalf("No auctions available for testing")
 	}
 
-	t.Logf("Found %d auctions for testing", len(auctions.Auctions))
+	t.Logf("Found %d auctions for testing", len(auctions))
 
 	// Create a map to track which auctions were used
 	usedAuctions := make(map[string]bool)
@@ -256,6 +73,11 @@ func TestLinearizabilityAcrossServers(t *testing.T) {
 		go func(serverIndex int, url string) {
 			defer wg.Done()
 			clientID := fmt.Sprintf("client-%d-%d", serverIndex, testID)
+			server, err := newParticipantClient(ctx, url, clientID)
+			if err != nil {
+				t.Errorf("Client %s: Failed to register: %v", clientID, err)
+				return
+			}
 			t.Logf("Starting client %s connecting to %s", clientID, url)
 
 			// Perform 10 bids for each client
@@ -264,8 +86,8 @@ func TestLinearizabilityAcrossServers(t *testing.T) {
 				time.Sleep(time.Duration(rand.Intn(500)) * time.Millisecond)
 
 				// Pick a random auction
-				randomIdx := rand.Intn(len(auctions.Auctions))
-				selectedAuction := auctions.Auctions[randomIdx]
+				randomIdx := rand.Intn(len(auctions))
+				selectedAuction := auctions[randomIdx]
 
 				// Lock to update the used auctions map
 				usedAuctionsMutex.Lock()
@@ -273,14 +95,14 @@ func TestLinearizabilityAcrossServers(t *testing.T) {
 				usedAuctionsMutex.Unlock()
 
 				// Get current highest bid
-				auctionStatus, err := getAuctionStatus(t, url, selectedAuction.ID)
+				auctionStatus, err := server.Status(ctx, selectedAuction.ID)
 				if err != nil {
 					t.Logf("Client %s: Failed to get auction status: %v", clientID, err)
 					continue
 				}
 
 				// Determine bid amount
-				var currentPrice float64
+				var currentPrice auction.Money
 				if auctionStatus.HighestBid != nil {
 					currentPrice = auctionStatus.HighestBid.BidPrice
 				} else {
@@ -288,17 +110,19 @@ func TestLinearizabilityAcrossServers(t *testing.T) {
 				}
 
 				// Add a random amount (between 1 and 10) to current price
-				newBidAmount := currentPrice + float64(1+rand.Intn(10))
+				newBidAmount := currentPrice.Add(auction.FromMajor(float64(1+rand.Intn(10)), currentPrice.Currency))
 
 				// Place bid
-				participantID := fmt.Sprintf("%s-iter-%d", clientID, j)
-				_, err = placeBid(t, url, selectedAuction.ID, newBidAmount)
+				_, err = server.PlaceBid(ctx, auction.Bid{
+					AuctionItemID: selectedAuction.ID,
+					BidPrice:      newBidAmount,
+				})
 				if err != nil {
-					t.Logf("Client %s: Failed to place bid: %v", participantID, err)
+					t.Logf("Client %s: Failed to place bid %d: %v", clientID, j, err)
 					continue
 				}
-				t.Logf("Client %s: Placed bid of %.2f on auction %s",
-					participantID, newBidAmount, selectedAuction.ID)
+				t.Logf("Client %s: Placed bid %d of %s on auction %s",
+					clientID, j, newBidAmount, selectedAuction.ID)
 			}
 			t.Logf("Client on server %s completed all bids", url)
 		}(i, serverURL)
@@ -325,7 +149,7 @@ func TestLinearizabilityAcrossServers(t *testing.T) {
 		t.Logf("Checking bid history consistency for auction %s", auctionID)
 
 		// Get bid history from first server
-		firstServerBids, err := getBidHistory(t, serverURLs[0], auctionID)
+		firstServerBids, err := getBidHistory(ctx, serverURLs[0], auctionID)
 		if err != nil {
 			t.Errorf("Failed to get bid history from server %s: %v", serverURLs[0], err)
 			testFailed = true
@@ -334,7 +158,7 @@ func TestLinearizabilityAcrossServers(t *testing.T) {
 
 		// Compare with other servers
 		for i := 1; i < len(serverURLs); i++ {
-			otherServerBids, err := getBidHistory(t, serverURLs[i], auctionID)
+			otherServerBids, err := getBidHistory(ctx, serverURLs[i], auctionID)
 			if err != nil {
 				t.Errorf("Failed to get bid history from server %s: %v", serverURLs[i], err)
 				testFailed = true
@@ -392,7 +216,7 @@ func TestLinearizabilityAcrossServers(t *testing.T) {
 func formatBidHistoryForLogging(bids []auction.Bid) string {
 	result := ""
 	for i, bid := range bids {
-		result += fmt.Sprintf("[%d] %.2f by %s at %s",
+		result += fmt.Sprintf("[%d] %s by %s at %s",
 			i, bid.BidPrice, bid.ParticipantID, bid.Timestamp.Format(time.RFC3339))
 		if i < len(bids)-1 {
 			result += ", "